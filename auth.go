@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MenschMachine/gommit/internal/cli"
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/ui"
+)
+
+func authCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "auth",
+		Synopsis:    []string{"login <provider>"},
+		Summary:     "store an API key in the system keyring",
+		Description: "Prompts for the API key of provider and stores it in the keyring.",
+		Args: func(prev []string) []string {
+			switch len(prev) {
+			case 0:
+				return []string{"login"}
+			case 1:
+				return providers
+			}
+			return nil
+		},
+		Run: func(args []string) error {
+			if len(args) != 2 || args[0] != "login" {
+				return cli.ErrUsage
+			}
+			runAuth(args[1])
+			return nil
+		},
+	}
+}
+
+func runAuth(provider string) {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if provider == "" {
+		fatal("provider is required")
+	}
+
+	key, err := ui.PromptSecret(fmt.Sprintf("API key for %s", provider))
+	if err != nil {
+		fatal(err.Error())
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		fatal("empty API key")
+	}
+	ctx := interruptContext()
+	if err := config.StoreAPIKey(ctx, provider, key); err != nil {
+		if isInterrupt(ctx, err) {
+			interrupted("waiting for the keyring", "the API key was not stored")
+		}
+		fatal(err.Error())
+	}
+	fmt.Printf("Stored API key for %s in the keyring.\n", provider)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/MenschMachine/gommit/internal/cache"
+	"github.com/MenschMachine/gommit/internal/cli"
+	"github.com/MenschMachine/gommit/internal/config"
+)
+
+// openCache returns the response cache, or nil when it is disabled.
+func openCache(cfg config.Config) (*cache.Cache, error) {
+	if cfg.Cache.TTL <= 0 {
+		return nil, nil
+	}
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	return cache.New(dir, time.Duration(cfg.Cache.TTL)*time.Second, cfg.Cache.MaxEntries), nil
+}
+
+// responseCacheKey includes the base URL, as OpenAI-compatible endpoints
+// may serve different models under the same name.
+func responseCacheKey(cfg config.Config, systemPrompt, userPrompt string) string {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = config.DefaultBaseURL(cfg.Provider)
+	}
+	return cache.Key(cfg.Provider, baseURL, cfg.Model, systemPrompt, userPrompt)
+}
+
+func cacheCommand() *cli.Command {
+	return &cli.Command{
+		Name:     "cache",
+		Synopsis: []string{"clear"},
+		Summary:  "clear the response cache",
+		Args: func(prev []string) []string {
+			if len(prev) == 0 {
+				return []string{"clear"}
+			}
+			return nil
+		},
+		Run: func(args []string) error {
+			if len(args) != 1 || args[0] != "clear" {
+				return cli.ErrUsage
+			}
+			clearCache()
+			return nil
+		},
+	}
+}
+
+func clearCache() {
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		fatal(err.Error())
+	}
+	if err := cache.New(dir, 0, 0).Clear(); err != nil {
+		fatal(err.Error())
+	}
+	fmt.Println("Cleared", dir)
+}
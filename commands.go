@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/MenschMachine/gommit/internal/cli"
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/prompt"
+	"github.com/MenschMachine/gommit/pkg/gommit"
+)
+
+var (
+	providers     = []string{"openai", "openrouter", "anthropic"}
+	outputFormats = []string{"text", "json"}
+)
+
+// newApp defines every gommit command. Help, completions and the man page
+// are generated from these definitions, so a new flag only needs adding here.
+func newApp() *cli.App {
+	app := &cli.App{
+		Name:    "gommit",
+		Version: version,
+		Summary: "generate git commit messages with an LLM",
+		Description: "gommit collects the changes of a git repository, asks a language model for a commit message " +
+			"in the configured style and commits it after review.\n\n" +
+			"Without a command gommit runs commit. Configuration is read from ~/.config/gommit/config.toml " +
+			"and GOMMIT_* environment variables; flags override both.",
+		Default: "commit",
+	}
+	app.Commands = []*cli.Command{
+		commitCommand(),
+		lintCommand(),
+		historyCommand(),
+		lastCommand(),
+		usageCommand(),
+		cacheCommand(),
+		templateCommand(),
+		authCommand(),
+		serveCommand(),
+		mcpCommand(),
+		completionCommand(app),
+		manCommand(app),
+	}
+	return app
+}
+
+func configOption(p *string) *cli.Flag {
+	path, err := config.DefaultConfigPath()
+	if err != nil {
+		path = "~/.config/gommit/config.toml"
+	}
+	return cli.String(p, "config", "c", "file", "", "path to config file").ShowDefault(path)
+}
+
+func providerOption(p *string) *cli.Flag {
+	usage := "llm provider (" + strings.Join(providers, ", ") + ")"
+	return cli.String(p, "provider", "p", "string", "", usage).
+		ShowDefault(config.DefaultConfig().Provider).
+		Complete(func() []string { return providers })
+}
+
+func modelOption(p *string) *cli.Flag {
+	return cli.String(p, "model", "m", "string", "", "model name (required unless set in config/env)").
+		Complete(configuredModels)
+}
+
+func baseURLOption(p *string) *cli.Flag {
+	return cli.String(p, "base-url", "b", "url", "", "base url for openai-compatible api").
+		ShowDefault(fmt.Sprintf("%s (openai), %s (openrouter)", config.DefaultBaseURL("openai"), config.DefaultBaseURL("openrouter")))
+}
+
+func styleOption(p *string) *cli.Flag {
+	return cli.String(p, "style", "", "string", "", "commit style ("+strings.Join(prompt.StyleNames(), ", ")+")").
+		ShowDefault(config.DefaultConfig().Style).
+		Complete(prompt.StyleNames)
+}
+
+// configuredModels lists the model from the config and every model with a
+// price, for completing --model.
+func configuredModels() []string {
+	cfg, err := gommit.LoadConfig("")
+	if err != nil {
+		return nil
+	}
+	var models []string
+	if cfg.Model != "" {
+		models = append(models, cfg.Model)
+	}
+	for key := range cfg.Prices {
+		if provider, model, ok := strings.Cut(key, "/"); ok && slices.Contains(providers, provider) {
+			key = model
+		}
+		models = append(models, key)
+	}
+	sort.Strings(models)
+	return slices.Compact(models)
+}
+
+// coAuthorAliases lists the --co-author aliases from the config.
+func coAuthorAliases() []string {
+	cfg, err := gommit.LoadConfig("")
+	if err != nil {
+		return nil
+	}
+	var aliases []string
+	for alias := range cfg.CoAuthors {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+func completionCommand(app *cli.App) *cli.Command {
+	return &cli.Command{
+		Name:     "completion",
+		Synopsis: []string{strings.Join(cli.Shells, "|")},
+		Summary:  "print a shell completion script",
+		Description: "Bash:  gommit completion bash > /etc/bash_completion.d/gommit\n" +
+			"Zsh:   gommit completion zsh > \"${fpath[1]}/_gommit\"\n" +
+			"Fish:  gommit completion fish > ~/.config/fish/completions/gommit.fish",
+		Args: func(prev []string) []string {
+			if len(prev) == 0 {
+				return cli.Shells
+			}
+			return nil
+		},
+		Run: func(args []string) error {
+			if len(args) != 1 {
+				return cli.ErrUsage
+			}
+			return app.WriteCompletion(os.Stdout, args[0])
+		},
+	}
+}
+
+func manCommand(app *cli.App) *cli.Command {
+	return &cli.Command{
+		Name:    "man",
+		Summary: "print the man page",
+		Description: "Install with:\n" +
+			"  gommit man | gzip > /usr/local/share/man/man1/gommit.1.gz",
+		Run: func(args []string) error {
+			if len(args) > 0 {
+				return cli.ErrUsage
+			}
+			app.WriteMan(os.Stdout)
+			return nil
+		},
+	}
+}
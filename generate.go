@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/MenschMachine/gommit/internal/cache"
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/history"
+	"github.com/MenschMachine/gommit/internal/llm"
+	"github.com/MenschMachine/gommit/internal/prompt"
+	"github.com/MenschMachine/gommit/internal/ui"
+	"github.com/MenschMachine/gommit/pkg/gommit"
+)
+
+// errCancelled is returned when the user declines to send a request.
+var errCancelled = errors.New("cancelled")
+
+// codedError attaches one of the errCode constants to an error.
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+func withCode(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// errorCode returns the code attached with withCode, or the one matching
+// the failed gommit.Generate step, or errCodeError.
+func errorCode(err error) string {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	if errors.Is(err, gommit.ErrNoChanges) {
+		return errCodeNoChanges
+	}
+	var genErr *gommit.Error
+	if errors.As(err, &genErr) {
+		switch genErr.Step {
+		case gommit.StepSetup, gommit.StepPrompt, gommit.StepIssue:
+			return errCodeConfig
+		case gommit.StepDiff, gommit.StepContext, gommit.StepTrailers:
+			return errCodeGit
+		case gommit.StepProvider:
+			return errCodeLLM
+		}
+	}
+	return errCodeError
+}
+
+// generator runs gommit.Generate with the CLI's response cache, request
+// guards, usage meter and message history. It is shared by the interactive
+// CLI, `gommit serve` and `gommit mcp`.
+type generator struct {
+	cfg     config.Config
+	tmpl    *gommit.Template
+	client  *llm.Client
+	meter   *usageMeter
+	cache   *cache.Cache   // nil disables the response cache
+	history *history.Store // nil disables the message history
+}
+
+type generateRequest struct {
+	Hint string
+	// ReadCache allows answering from the response cache. Responses are
+	// stored regardless, unless Hint is set.
+	ReadCache bool
+	// Interactive asks before exceeding a guard instead of failing.
+	Interactive bool
+	Trailers    []string
+	Tag         string
+	// Progress receives the spinners; OnDelta, if set, streams the first
+	// completion.
+	Progress io.Writer
+	OnDelta  func(string)
+}
+
+type generateResult struct {
+	Message    string
+	Violations []prompt.Violation
+	Style      string
+	Cached     bool
+}
+
+func (g *generator) options(diff gommit.Diff, hint string) gommit.Options {
+	return gommit.Options{
+		Config:   g.cfg,
+		Template: g.tmpl,
+		Diff:     gommit.StaticDiff(diff),
+		Provider: gommit.ClientProvider{Client: g.client},
+		Hint:     hint,
+		OnWarning: func(err error) {
+			fmt.Fprintln(os.Stderr, "gommit:", err)
+		},
+	}
+}
+
+// prompt renders the prompt generate would send for diff.
+func (g *generator) prompt(ctx context.Context, diff gommit.Diff, hint string) (gommit.Prompt, error) {
+	return gommit.RenderPrompt(ctx, g.options(diff, hint))
+}
+
+// generate asks the model for a message for diff and records it in the
+// history. The usage of the run is left in g.meter.
+func (g *generator) generate(ctx context.Context, diff gommit.Diff, req generateRequest) (generateResult, error) {
+	progress := req.Progress
+	if progress == nil {
+		progress = io.Discard
+	}
+	g.meter.reset()
+	provider := &cliProvider{gen: g, req: req, progress: progress}
+	opts := g.options(diff, req.Hint)
+	opts.Provider = provider
+	opts.Trailers = req.Trailers
+	opts.Tag = req.Tag
+	opts.OnDelta = req.OnDelta
+	msg, err := gommit.Generate(ctx, opts)
+	if err != nil {
+		return generateResult{}, err
+	}
+	recordHistory(g.history, diff.Root, history.HashDiff(diff.Text), history.SourceGenerated, msg.Text)
+	return generateResult{Message: msg.Text, Violations: msg.Violations, Style: msg.Style, Cached: provider.cached}, nil
+}
+
+// cliProvider answers from the response cache, or checks the request guards
+// before asking the model. Style repairs are never cached and are skipped
+// when they would exceed a guard.
+type cliProvider struct {
+	gen      *generator
+	req      generateRequest
+	progress io.Writer
+	cached   bool
+}
+
+func (p *cliProvider) Complete(ctx context.Context, r gommit.Request) (string, error) {
+	g := p.gen
+	model := gommit.ClientProvider{Client: g.client}
+	if r.Repair {
+		if _, err := guardRequest(g.cfg.Guard, g.meter, r.System, r.User, false); err != nil {
+			fmt.Fprintln(os.Stderr, "gommit: style violations left unrepaired:", err)
+			return "", gommit.ErrSkipRepair
+		}
+		spinner := ui.StartSpinner(ctx, p.progress, "Fixing style violations")
+		defer spinner.Stop()
+		return model.Complete(ctx, r)
+	}
+
+	// Hinted prompts ask for something different and are never cached.
+	useCache := g.cache != nil && p.req.Hint == ""
+	cacheKey := responseCacheKey(g.cfg, r.System, r.User)
+	if useCache && p.req.ReadCache {
+		if message, ok := g.cache.Get(cacheKey); ok {
+			p.cached = true
+			if r.OnDelta != nil {
+				r.OnDelta(message)
+			}
+			return message, nil
+		}
+	}
+	send, err := guardRequest(g.cfg.Guard, g.meter, r.System, r.User, p.req.Interactive)
+	if err != nil {
+		return "", withCode(errCodeGuard, err)
+	}
+	if !send {
+		return "", errCancelled
+	}
+	spinner := ui.StartSpinner(ctx, p.progress, "Generating commit message")
+	message, err := model.Complete(ctx, r)
+	spinner.Stop()
+	if err != nil {
+		return "", err
+	}
+	if useCache {
+		if err := g.cache.Put(cacheKey, message); err != nil {
+			fmt.Fprintln(os.Stderr, "gommit: could not cache response:", err)
+		}
+	}
+	return message, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/llm"
+	"github.com/MenschMachine/gommit/internal/ui"
+	"github.com/MenschMachine/gommit/internal/usage"
+)
+
+// requestEstimate is the expected size and cost of a request before it is
+// sent. Cost and SpentToday are only known when the model has a price.
+type requestEstimate struct {
+	Tokens     int
+	Cost       float64
+	Priced     bool
+	SpentToday float64
+}
+
+func (e requestEstimate) String() string {
+	s := fmt.Sprintf("~%d prompt tokens", e.Tokens)
+	if e.Priced {
+		s += fmt.Sprintf(", ~$%.4f (spent today: $%.4f)", e.Cost, e.SpentToday)
+	} else {
+		s += ", cost unknown (no [prices] entry for the model)"
+	}
+	return s
+}
+
+func (m *usageMeter) estimate(systemPrompt, userPrompt string) requestEstimate {
+	e := requestEstimate{Tokens: usage.EstimateTokens(systemPrompt, userPrompt)}
+	price, ok := usage.LookupPrice(m.prices, m.provider, m.model)
+	if !ok {
+		return e
+	}
+	e.Priced = true
+	e.Cost = usage.Cost(llm.Usage{PromptTokens: e.Tokens, CompletionTokens: usage.EstimatedCompletionTokens}, price)
+	if m.ledger != nil {
+		spent, err := m.ledger.Spent(usage.Today(), m.prices)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "gommit: could not read usage ledger:", err)
+		}
+		e.SpentToday = spent
+	}
+	return e
+}
+
+// guardViolations lists the limits e exceeds. Cost limits cannot be checked
+// for models without a price.
+func guardViolations(guard config.GuardConfig, e requestEstimate) []string {
+	var out []string
+	if guard.MaxTokens > 0 && e.Tokens > guard.MaxTokens {
+		out = append(out, fmt.Sprintf("prompt is ~%d tokens, limit is %d (guard.max_tokens)", e.Tokens, guard.MaxTokens))
+	}
+	if !e.Priced {
+		return out
+	}
+	if guard.MaxCost > 0 && e.Cost > guard.MaxCost {
+		out = append(out, fmt.Sprintf("request costs ~$%.4f, limit is $%.4f (guard.max_cost)", e.Cost, guard.MaxCost))
+	}
+	if guard.DailyBudget > 0 && e.SpentToday+e.Cost > guard.DailyBudget {
+		out = append(out, fmt.Sprintf("today's spend would reach ~$%.4f, budget is $%.4f (guard.daily_budget)", e.SpentToday+e.Cost, guard.DailyBudget))
+	}
+	return out
+}
+
+// guardRequest reports whether the request may be sent. Interactively the
+// user can send it anyway; otherwise exceeding a limit is an error.
+func guardRequest(guard config.GuardConfig, meter *usageMeter, systemPrompt, userPrompt string, interactive bool) (bool, error) {
+	e := meter.estimate(systemPrompt, userPrompt)
+	problems := guardViolations(guard, e)
+	if len(problems) == 0 {
+		return true, nil
+	}
+	if !interactive {
+		return false, fmt.Errorf("request not sent: %s; raise the [guard] limits or reduce the diff (--max-prompt-chars)", strings.Join(problems, "; "))
+	}
+	fmt.Println("Request exceeds the configured limits:")
+	for _, p := range problems {
+		fmt.Println(" ! " + p)
+	}
+	fmt.Println("Estimate: " + e.String())
+	action, err := ui.SelectOption("Send the request anyway?", []string{"Send", "Cancel"})
+	if err != nil {
+		return false, err
+	}
+	return action == "Send", nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/MenschMachine/gommit/internal/cli"
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/git"
+	"github.com/MenschMachine/gommit/internal/history"
+)
+
+func openHistory() (*history.Store, error) {
+	dir, err := config.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	return history.Open(dir), nil
+}
+
+// recordHistory stores message without failing the run; losing a history
+// entry is not worth aborting a commit over.
+func recordHistory(store *history.Store, root, diffHash, source, message string) {
+	if store == nil || strings.TrimSpace(message) == "" {
+		return
+	}
+	err := store.Add(history.Entry{Repo: root, DiffHash: diffHash, Source: source, Message: message})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gommit: could not record message history:", err)
+	}
+}
+
+func historyCommand() *cli.Command {
+	var limit int
+	var all bool
+	return &cli.Command{
+		Name:        "history",
+		Synopsis:    []string{"[options]", "show <n>"},
+		Summary:     "list messages generated in this repository",
+		Description: "Lists messages generated in this repository, newest first.",
+		Flags: []*cli.Flag{
+			cli.Int(&limit, "limit", "n", "n", 20, "number of entries to list (0 = all)"),
+			cli.Bool(&all, "all", "", "list entries from every repository"),
+		},
+		Args: func(prev []string) []string {
+			if len(prev) == 0 {
+				return []string{"show"}
+			}
+			return nil
+		},
+		Run: func(args []string) error {
+			return runHistory(args, limit, all)
+		},
+	}
+}
+
+func runHistory(args []string, limit int, all bool) error {
+	switch {
+	case len(args) == 0:
+		entries := historyEntries(all)
+		for i, e := range entries {
+			if limit > 0 && i >= limit {
+				break
+			}
+			line := fmt.Sprintf("%3d  %s  %-9s  %s", i+1, e.Time.Local().Format("2006-01-02 15:04"), e.Source, e.Subject())
+			if all {
+				line += "  (" + e.Repo + ")"
+			}
+			fmt.Println(line)
+		}
+	case len(args) == 2 && args[0] == "show":
+		entries := historyEntries(all)
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > len(entries) {
+			fatal(fmt.Sprintf("no history entry %q (1-%d)", args[1], len(entries)))
+		}
+		fmt.Println(entries[n-1].Message)
+	default:
+		return cli.ErrUsage
+	}
+	return nil
+}
+
+func lastCommand() *cli.Command {
+	return &cli.Command{
+		Name:        "last",
+		Summary:     "print the newest generated message",
+		Description: "Prints the newest message for this repository, e.g. for `gommit last | git commit -F -`.",
+		Run: func(args []string) error {
+			if len(args) > 0 {
+				return cli.ErrUsage
+			}
+			runLast()
+			return nil
+		},
+	}
+}
+
+// runLast prints the newest message for the repository, so it can be
+// piped into `git commit -F -`.
+func runLast() {
+	entries := historyEntries(false)
+	if len(entries) == 0 {
+		fatal("no message history for this repository")
+	}
+	fmt.Println(entries[0].Message)
+}
+
+func historyEntries(all bool) []history.Entry {
+	store, err := openHistory()
+	if err != nil {
+		fatal(err.Error())
+	}
+	repo := ""
+	if !all {
+		repo, err = git.RepoRoot()
+		if err != nil {
+			fatal(err.Error())
+		}
+	}
+	entries, err := store.List(repo)
+	if err != nil {
+		fatal(err.Error())
+	}
+	return entries
+}
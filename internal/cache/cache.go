@@ -0,0 +1,146 @@
+// Package cache stores LLM responses on disk so repeated runs over the same
+// changes do not pay for another request.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cache keeps one JSON file per key in dir. Entries older than TTL are
+// ignored and removed; beyond MaxEntries the oldest are removed.
+type Cache struct {
+	dir        string
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+}
+
+type entry struct {
+	Created  time.Time `json:"created"`
+	Response string    `json:"response"`
+}
+
+// DefaultDir returns the gommit directory under the user cache dir
+// ($XDG_CACHE_HOME or ~/.cache on Linux).
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gommit", "responses"), nil
+}
+
+// New returns a cache in dir. A maxEntries of 0 means no limit.
+func New(dir string, ttl time.Duration, maxEntries int) *Cache {
+	return &Cache{dir: dir, ttl: ttl, maxEntries: maxEntries, now: time.Now}
+}
+
+// Key hashes parts into a cache key. Parts are length-prefixed so that
+// moving text between them changes the key.
+func Key(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		_ = json.NewEncoder(h).Encode(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the response stored under key if it has not expired.
+func (c *Cache) Get(key string) (string, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return "", false
+	}
+	var e entry
+	if json.Unmarshal(data, &e) != nil || c.expired(e.Created) {
+		_ = os.Remove(c.path(key))
+		return "", false
+	}
+	return e.Response, true
+}
+
+// Put stores response under key and prunes expired and excess entries.
+func (c *Cache) Put(key, response string) error {
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry{Created: c.now(), Response: response})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		return err
+	}
+	return c.prune()
+}
+
+// Clear removes every cached response.
+func (c *Cache) Clear() error {
+	err := os.RemoveAll(c.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *Cache) expired(created time.Time) bool {
+	return c.ttl > 0 && c.now().Sub(created) > c.ttl
+}
+
+func (c *Cache) prune() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	type file struct {
+		name    string
+		modTime time.Time
+	}
+	var files []file
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if c.expired(info.ModTime()) {
+			_ = os.Remove(filepath.Join(c.dir, e.Name()))
+			continue
+		}
+		files = append(files, file{name: e.Name(), modTime: info.ModTime()})
+	}
+	if c.maxEntries <= 0 || len(files) <= c.maxEntries {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	for _, f := range files[c.maxEntries:] {
+		_ = os.Remove(filepath.Join(c.dir, f.name))
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New(t.TempDir(), time.Hour, 0)
+	c.now = func() time.Time { return now }
+
+	key := Key("openai", "gpt", "system", "user")
+	if _, ok := c.Get(key); ok {
+		t.Fatal("Get on empty cache returned a hit")
+	}
+	if err := c.Put(key, "feat: add cache"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got, ok := c.Get(key); !ok || got != "feat: add cache" {
+		t.Fatalf("Get = %q, %t", got, ok)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, ok := c.Get(key); ok {
+		t.Fatal("Get returned an expired entry")
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Fatalf("Clear: %v", err)
+	}
+	if err := c.Clear(); err != nil {
+		t.Fatalf("Clear on missing dir: %v", err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key("ab", "c") == Key("a", "bc") {
+		t.Fatal("Key does not separate parts")
+	}
+	if Key("a", "b") != Key("a", "b") {
+		t.Fatal("Key is not stable")
+	}
+}
+
+func TestCachePrunesOldest(t *testing.T) {
+	dir := t.TempDir()
+	c := New(dir, 0, 2)
+	base := time.Now().Add(-time.Hour)
+	for i, key := range []string{"a", "b", "c"} {
+		if err := c.Put(key, key); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		// Spread modification times so pruning order is deterministic.
+		mod := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, key+".json"), mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Put("d", "d"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	for key, want := range map[string]bool{"a": false, "b": false, "c": true, "d": true} {
+		if _, ok := c.Get(key); ok != want {
+			t.Errorf("Get(%q) hit = %t, want %t", key, ok, want)
+		}
+	}
+}
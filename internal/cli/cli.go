@@ -0,0 +1,316 @@
+// Package cli defines commands and their flags once and derives parsing,
+// help, shell completions and the man page from the definitions.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// ErrUsage makes App.Run print the command's help and exit with status 2.
+var ErrUsage = errors.New("usage")
+
+// Flag is one option. Every flag has a long name and may have a one-letter
+// short name; both set the same Value.
+type Flag struct {
+	Name  string
+	Short string
+	// Arg names the value in help, e.g. "string" or "key=value". Flags
+	// without one are switches. An Arg of "file" completes file names.
+	Arg   string
+	Usage string
+	Value flag.Value
+	// Default is shown in help instead of the initial value of Value.
+	Default string
+	// Values lists completions for the value.
+	Values func() []string
+}
+
+// IsSwitch reports whether the flag takes no value.
+func (f *Flag) IsSwitch() bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// Complete sets the completion source of f's value and returns f.
+func (f *Flag) Complete(values func() []string) *Flag {
+	f.Values = values
+	return f
+}
+
+// ShowDefault sets the default shown in help and returns f.
+func (f *Flag) ShowDefault(def string) *Flag {
+	f.Default = def
+	return f
+}
+
+func newFlag(name, short, arg, usage string, register func(fs *flag.FlagSet)) *Flag {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	register(fs)
+	f := fs.Lookup(name)
+	def := f.DefValue
+	if def == "false" || def == "0" || def == "[]" {
+		def = ""
+	}
+	return &Flag{Name: name, Short: short, Arg: arg, Usage: usage, Value: f.Value, Default: def}
+}
+
+func Bool(p *bool, name, short, usage string) *Flag {
+	return newFlag(name, short, "", usage, func(fs *flag.FlagSet) { fs.BoolVar(p, name, false, usage) })
+}
+
+func String(p *string, name, short, arg, def, usage string) *Flag {
+	return newFlag(name, short, arg, usage, func(fs *flag.FlagSet) { fs.StringVar(p, name, def, usage) })
+}
+
+func Int(p *int, name, short, arg string, def int, usage string) *Flag {
+	return newFlag(name, short, arg, usage, func(fs *flag.FlagSet) { fs.IntVar(p, name, def, usage) })
+}
+
+// Var defines a flag with a custom value. Values that implement
+// IsBoolFlag() bool are switches.
+func Var(v flag.Value, name, short, arg, usage string) *Flag {
+	return &Flag{Name: name, Short: short, Arg: arg, Usage: usage, Value: v}
+}
+
+// Command is a subcommand such as `gommit lint`.
+type Command struct {
+	Name string
+	// Synopsis holds the usage lines after the command name.
+	Synopsis []string
+	// Summary is the one-line description used in command lists.
+	Summary string
+	// Description is printed under the usage lines in help.
+	Description string
+	Flags       []*Flag
+	// Args completes positional arguments given the ones before them.
+	Args func(prev []string) []string
+	// Run gets the arguments left after the flags. Returning ErrUsage
+	// prints the help.
+	Run func(args []string) error
+	// Hidden commands are left out of help, completions and the man page.
+	Hidden bool
+}
+
+func (c *Command) flag(name string) *Flag {
+	for _, f := range c.Flags {
+		if f.Name == name || (f.Short != "" && f.Short == name) {
+			return f
+		}
+	}
+	return nil
+}
+
+// App is a program made of commands.
+type App struct {
+	Name    string
+	Version string
+	// Summary is the one-line description for the man page.
+	Summary string
+	// Description is the man page's DESCRIPTION.
+	Description string
+	// Default is the command run when the first argument is not a command.
+	Default  string
+	Commands []*Command
+}
+
+// Lookup returns the command called name, or nil.
+func (a *App) Lookup(name string) *Command {
+	for _, c := range a.Commands {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (a *App) visible() []*Command {
+	var out []*Command
+	for _, c := range a.Commands {
+		if !c.Hidden {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Run dispatches args to a command. Flag errors and ErrUsage exit with
+// status 2; other errors are returned.
+func (a *App) Run(args []string) error {
+	cmd := a.Lookup(a.Default)
+	if len(args) > 0 {
+		switch args[0] {
+		case "__complete":
+			a.complete(os.Stdout, args[1:])
+			return nil
+		case "help":
+			return a.help(args[1:])
+		}
+		if c := a.Lookup(args[0]); c != nil {
+			cmd, args = c, args[1:]
+		}
+	}
+	if cmd == nil {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	fs := a.FlagSet(cmd)
+	args, _ = Parse(fs, args)
+	if err := cmd.Run(args); err != nil {
+		if errors.Is(err, ErrUsage) {
+			fs.Usage()
+			os.Exit(2)
+		}
+		return err
+	}
+	return nil
+}
+
+// Parse parses the flags in args, which may come before, between or after
+// the positional arguments, and returns the positional ones. Everything
+// after "--" is positional.
+func Parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		// flag.FlagSet stops at the first positional argument or after "--".
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+func (a *App) help(args []string) error {
+	cmd := a.Lookup(a.Default)
+	if len(args) > 0 {
+		if cmd = a.Lookup(args[0]); cmd == nil {
+			return fmt.Errorf("unknown command %q", args[0])
+		}
+	}
+	a.WriteHelp(os.Stdout, cmd)
+	return nil
+}
+
+// FlagSet returns a flag set for cmd that prints WriteHelp on -h.
+func (a *App) FlagSet(cmd *Command) *flag.FlagSet {
+	fs := flag.NewFlagSet(a.Name+" "+cmd.Name, flag.ExitOnError)
+	for _, f := range cmd.Flags {
+		fs.Var(f.Value, f.Name, f.Usage)
+		if f.Short != "" {
+			fs.Var(f.Value, f.Short, f.Usage)
+		}
+	}
+	fs.Usage = func() { a.WriteHelp(fs.Output(), cmd) }
+	return fs
+}
+
+// WriteHelp prints the usage of cmd. The default command's help also lists
+// the other commands.
+func (a *App) WriteHelp(w io.Writer, cmd *Command) {
+	for i, line := range a.synopsis(cmd) {
+		prefix := "Usage: "
+		if i > 0 {
+			prefix = "       "
+		}
+		fmt.Fprintln(w, prefix+line)
+	}
+	if cmd.Description != "" {
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, cmd.Description)
+	}
+	if cmd.Name == a.Default {
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "Commands:")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, c := range a.visible() {
+			fmt.Fprintf(tw, "  %s\t%s\n", c.Name, c.Summary)
+		}
+		tw.Flush()
+	}
+	if len(cmd.Flags) > 0 {
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "Options:")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, f := range cmd.Flags {
+			fmt.Fprintf(tw, "  %s\t%s\n", flagSpec(f), flagUsage(f))
+		}
+		tw.Flush()
+	}
+	if cmd.Name == a.Default {
+		fmt.Fprintln(w, "")
+		fmt.Fprintf(w, "Run '%s help <command>' for the options of a command.\n", a.Name)
+	}
+}
+
+func (a *App) synopsis(cmd *Command) []string {
+	prefix := a.Name + " " + cmd.Name
+	if cmd.Name == a.Default {
+		prefix = a.Name
+	}
+	if len(cmd.Synopsis) == 0 {
+		return []string{prefix}
+	}
+	lines := make([]string, len(cmd.Synopsis))
+	for i, s := range cmd.Synopsis {
+		lines[i] = prefix + " " + s
+	}
+	return lines
+}
+
+// flagSpec renders f as "-m, --model string".
+func flagSpec(f *Flag) string {
+	spec := "    --" + f.Name
+	if f.Short != "" {
+		spec = "-" + f.Short + ", --" + f.Name
+	}
+	if f.Arg != "" {
+		if f.IsSwitch() {
+			spec += "[=" + f.Arg + "]"
+		} else {
+			spec += " " + f.Arg
+		}
+	}
+	return spec
+}
+
+func flagUsage(f *Flag) string {
+	if f.Default == "" {
+		return f.Usage
+	}
+	return fmt.Sprintf("%s (default: %s)", f.Usage, f.Default)
+}
+
+// complete prints the completions for a flag value (`__complete <command>
+// <flag>`) or a positional argument (`__complete <command> "" <args>...`).
+func (a *App) complete(w io.Writer, args []string) {
+	if len(args) < 2 {
+		return
+	}
+	cmd := a.Lookup(args[0])
+	if cmd == nil {
+		return
+	}
+	var values []string
+	if name := strings.TrimLeft(args[1], "-"); name != "" {
+		if f := cmd.flag(name); f != nil && f.Values != nil {
+			values = f.Values()
+		}
+	} else if cmd.Args != nil {
+		values = cmd.Args(args[2:])
+	}
+	for _, v := range values {
+		fmt.Fprintln(w, v)
+	}
+}
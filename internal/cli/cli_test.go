@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testApp() (*App, *string, *bool) {
+	var model string
+	var all bool
+	app := &App{Name: "tool", Version: "1.0", Summary: "does things", Default: "run"}
+	app.Commands = []*Command{
+		{
+			Name:    "run",
+			Summary: "run it",
+			Flags: []*Flag{
+				String(&model, "model", "m", "string", "", "model name").Complete(func() []string { return []string{"a", "b"} }),
+				Bool(&all, "all", "A", "include everything"),
+			},
+			Run: func([]string) error { return nil },
+		},
+		{
+			Name:     "show",
+			Synopsis: []string{"<name>"},
+			Summary:  "show one",
+			Args: func(prev []string) []string {
+				if len(prev) == 0 {
+					return []string{"x", "y"}
+				}
+				return nil
+			},
+			Run: func([]string) error { return nil },
+		},
+	}
+	return app, &model, &all
+}
+
+func TestFlagSet(t *testing.T) {
+	tests := []struct {
+		args  []string
+		model string
+		all   bool
+	}{
+		{[]string{"-m", "gpt", "-A"}, "gpt", true},
+		{[]string{"--model=gpt", "--all"}, "gpt", true},
+		{nil, "", false},
+	}
+	for _, tt := range tests {
+		app, model, all := testApp()
+		if err := app.FlagSet(app.Lookup("run")).Parse(tt.args); err != nil {
+			t.Fatalf("Parse(%q): %v", tt.args, err)
+		}
+		if *model != tt.model || *all != tt.all {
+			t.Errorf("Parse(%q) = %q, %v", tt.args, *model, *all)
+		}
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	tests := []struct {
+		args, want []string
+		model      string
+	}{
+		{[]string{"x", "-m", "gpt", "y"}, []string{"x", "y"}, "gpt"},
+		{[]string{"x", "--", "-m", "gpt"}, []string{"x", "-m", "gpt"}, ""},
+		{[]string{"-m", "gpt"}, nil, "gpt"},
+	}
+	for _, tt := range tests {
+		app, model, _ := testApp()
+		got, err := Parse(app.FlagSet(app.Lookup("run")), tt.args)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.args, err)
+		}
+		if !slices.Equal(got, tt.want) || *model != tt.model {
+			t.Errorf("Parse(%q) = %q, model %q; want %q, model %q", tt.args, got, *model, tt.want, tt.model)
+		}
+	}
+}
+
+func TestWriteHelp(t *testing.T) {
+	app, _, _ := testApp()
+	var buf bytes.Buffer
+	app.WriteHelp(&buf, app.Lookup("run"))
+	for _, want := range []string{"Usage: tool\n", "show  show one", "-m, --model string  model name", "-A, --all"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("help does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"run", "--model"}, "a\nb\n"},
+		{[]string{"run", "-m"}, "a\nb\n"},
+		{[]string{"run", "--all"}, ""},
+		{[]string{"show", ""}, "x\ny\n"},
+		{[]string{"show", "", "x"}, ""},
+		{[]string{"nope", ""}, ""},
+	}
+	for _, tt := range tests {
+		app, _, _ := testApp()
+		var buf bytes.Buffer
+		app.complete(&buf, tt.args)
+		if buf.String() != tt.want {
+			t.Errorf("complete(%q) = %q, want %q", tt.args, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestWriteCompletion(t *testing.T) {
+	tests := []struct {
+		shell string
+		want  []string
+	}{
+		{"bash", []string{"complete -o default -F _tool tool", `flags="-m --model -A --all"`}},
+		{"zsh", []string{"#compdef tool", "'(-m --model)'{-m,--model}'[model name]:model:{_tool_values run model}'"}},
+		{"fish", []string{"complete -c tool -n '__tool_using run' -s m -l model -x -a '(tool __complete run model)'"}},
+	}
+	for _, tt := range tests {
+		app, _, _ := testApp()
+		var buf bytes.Buffer
+		if err := app.WriteCompletion(&buf, tt.shell); err != nil {
+			t.Fatalf("%s: %v", tt.shell, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s completion does not contain %q:\n%s", tt.shell, want, buf.String())
+			}
+		}
+	}
+	app, _, _ := testApp()
+	if err := app.WriteCompletion(&bytes.Buffer{}, "csh"); err == nil {
+		t.Error("expected an error for an unknown shell")
+	}
+}
+
+func TestWriteMan(t *testing.T) {
+	app, _, _ := testApp()
+	var buf bytes.Buffer
+	app.WriteMan(&buf)
+	for _, want := range []string{".TH TOOL 1", "tool \\- does things", ".SH OPTIONS", `\fB\-m\fR, \fB\-\-model\fR \fIstring\fR`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("man page does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
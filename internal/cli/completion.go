@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Shells lists the shells WriteCompletion supports.
+var Shells = []string{"bash", "zsh", "fish"}
+
+// WriteCompletion writes the completion script for shell. Flag values and
+// positional arguments are completed by calling the program with the
+// hidden __complete command, so they follow the user's config.
+func (a *App) WriteCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		a.writeBash(w)
+	case "zsh":
+		a.writeZsh(w)
+	case "fish":
+		a.writeFish(w)
+	default:
+		return fmt.Errorf("unknown shell %q (%s)", shell, strings.Join(Shells, ", "))
+	}
+	return nil
+}
+
+func (a *App) commandNames() []string {
+	var names []string
+	for _, c := range a.visible() {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// ident turns a program name into a shell function name.
+func ident(name string) string {
+	return strings.NewReplacer("-", "_", ".", "_").Replace(name)
+}
+
+func flagWords(c *Command) []string {
+	var words []string
+	for _, f := range c.Flags {
+		if f.Short != "" {
+			words = append(words, "-"+f.Short)
+		}
+		words = append(words, "--"+f.Name)
+	}
+	return words
+}
+
+func (a *App) writeBash(w io.Writer) {
+	fn := "_" + ident(a.Name)
+	fmt.Fprintf(w, "# bash completion for %s\n\n", a.Name)
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintln(w, `	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintf(w, "\tlocal cmd=%s start=1\n", a.Default)
+	fmt.Fprintf(w, "\tcase \"${COMP_WORDS[1]}\" in\n")
+	fmt.Fprintf(w, "\t%s)\n\t\tif [[ $COMP_CWORD -gt 1 ]]; then cmd=\"${COMP_WORDS[1]}\" start=2; fi ;;\n", strings.Join(a.commandNames(), "|"))
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "\tlocal flags valued dynamic")
+	fmt.Fprintln(w, `	case "$cmd" in`)
+	for _, c := range a.visible() {
+		var valued, dynamic []string
+		for _, f := range c.Flags {
+			if f.IsSwitch() {
+				continue
+			}
+			names := []string{"--" + f.Name}
+			if f.Short != "" {
+				names = append(names, "-"+f.Short)
+			}
+			valued = append(valued, names...)
+			if f.Values != nil {
+				dynamic = append(dynamic, names...)
+			}
+		}
+		fmt.Fprintf(w, "\t%s)\n", c.Name)
+		fmt.Fprintf(w, "\t\tflags=%q\n", strings.Join(flagWords(c), " "))
+		fmt.Fprintf(w, "\t\tvalued=%q\n", " "+strings.Join(valued, " ")+" ")
+		fmt.Fprintf(w, "\t\tdynamic=%q\n", " "+strings.Join(dynamic, " ")+" ")
+		fmt.Fprintln(w, "\t\t;;")
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, `	if [[ "$dynamic" == *" $prev "* ]]; then`)
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W \"$(%s __complete \"$cmd\" \"$prev\" 2>/dev/null)\" -- \"$cur\"))\n", a.Name)
+	fmt.Fprintln(w, "\t\treturn")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, `	if [[ "$valued" == *" $prev "* ]]; then`)
+	fmt.Fprintln(w, `		COMPREPLY=($(compgen -f -- "$cur"))`)
+	fmt.Fprintln(w, "\t\treturn")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, `	if [[ "$cur" == -* ]]; then`)
+	fmt.Fprintln(w, `		COMPREPLY=($(compgen -W "$flags" -- "$cur"))`)
+	fmt.Fprintln(w, "\t\treturn")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, `	if [[ $COMP_CWORD -eq 1 ]]; then`)
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(a.commandNames(), " "))
+	fmt.Fprintln(w, "\t\treturn")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "\tlocal args=() i")
+	fmt.Fprintln(w, `	for ((i = start; i < COMP_CWORD; i++)); do`)
+	fmt.Fprintln(w, `		[[ "${COMP_WORDS[i]}" == -* ]] || args+=("${COMP_WORDS[i]}")`)
+	fmt.Fprintln(w, "\tdone")
+	fmt.Fprintf(w, "\tCOMPREPLY=($(compgen -W \"$(%s __complete \"$cmd\" \"\" \"${args[@]}\" 2>/dev/null)\" -- \"$cur\"))\n", a.Name)
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "")
+	fmt.Fprintf(w, "complete -o default -F %s %s\n", fn, a.Name)
+}
+
+// zshQuote escapes text for use inside a single-quoted _arguments spec.
+func zshQuote(text string) string {
+	return strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(text)
+}
+
+func (a *App) writeZsh(w io.Writer) {
+	fn := "_" + ident(a.Name)
+	fmt.Fprintf(w, "#compdef %s\n\n", a.Name)
+	fmt.Fprintf(w, "%s_values() {\n", fn)
+	fmt.Fprintln(w, "\tlocal -a values")
+	fmt.Fprintf(w, "\tvalues=(${(f)\"$(%s __complete \"$@\" 2>/dev/null)\"})\n", a.Name)
+	fmt.Fprintln(w, "\tcompadd -a values")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "")
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintf(w, "\tlocal cmd=%s\n", a.Default)
+	fmt.Fprintln(w, "\tlocal -a commands")
+	fmt.Fprintln(w, "\tcommands=(")
+	for _, c := range a.visible() {
+		fmt.Fprintf(w, "\t\t'%s:%s'\n", c.Name, zshQuote(c.Summary))
+	}
+	fmt.Fprintln(w, "\t)")
+	fmt.Fprintf(w, "\tif (( CURRENT > 2 )) && [[ \" %s \" == *\" $words[2] \"* ]]; then\n", strings.Join(a.commandNames(), " "))
+	fmt.Fprintln(w, "\t\tcmd=$words[2]")
+	fmt.Fprintln(w, "\t\tshift words")
+	fmt.Fprintln(w, "\t\t(( CURRENT-- ))")
+	fmt.Fprintln(w, "\telif (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then")
+	fmt.Fprintln(w, "\t\t_describe 'command' commands")
+	fmt.Fprintln(w, "\t\treturn")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "\tcase $cmd in")
+	for _, c := range a.visible() {
+		fmt.Fprintf(w, "\t%s)\n", c.Name)
+		fmt.Fprint(w, "\t\t_arguments -s")
+		for _, f := range c.Flags {
+			fmt.Fprint(w, " \\\n\t\t\t"+zshSpec(a, c, f))
+		}
+		if c.Args != nil {
+			fmt.Fprintf(w, " \\\n\t\t\t'*:arg:{%s_values %s \"\" ${words[2,CURRENT-1]:#-*}}'", fn, c.Name)
+		}
+		fmt.Fprintln(w, "\n\t\t;;")
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "")
+	fmt.Fprintf(w, "if [[ \"$funcstack[1]\" == %q ]]; then\n", fn)
+	fmt.Fprintf(w, "\t%s \"$@\"\n", fn)
+	fmt.Fprintln(w, "else")
+	fmt.Fprintf(w, "\tcompdef %s %s\n", fn, a.Name)
+	fmt.Fprintln(w, "fi")
+}
+
+func zshSpec(a *App, c *Command, f *Flag) string {
+	usage := zshQuote(f.Usage)
+	var action string
+	switch {
+	case f.IsSwitch():
+	case f.Values != nil:
+		action = fmt.Sprintf(":%s:{_%s_values %s %s}", f.Name, ident(a.Name), c.Name, f.Name)
+	case f.Arg == "file":
+		action = ":file:_files"
+	default:
+		action = fmt.Sprintf(":%s: ", zshQuote(f.Arg))
+	}
+	if f.Short == "" {
+		return fmt.Sprintf("'--%s[%s]%s'", f.Name, usage, action)
+	}
+	return fmt.Sprintf("'(-%s --%s)'{-%s,--%s}'[%s]%s'", f.Short, f.Name, f.Short, f.Name, usage, action)
+}
+
+// fishQuote escapes text for a single-quoted fish string.
+func fishQuote(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(text)
+}
+
+func (a *App) writeFish(w io.Writer) {
+	fn := "__" + ident(a.Name)
+	names := strings.Join(a.commandNames(), " ")
+	fmt.Fprintf(w, "# fish completion for %s\n\n", a.Name)
+	fmt.Fprintf(w, "function %s_command\n", fn)
+	fmt.Fprintln(w, "\tset -l words (commandline -opc)")
+	fmt.Fprintln(w, "\tif test (count $words) -gt 1")
+	fmt.Fprintf(w, "\t\tand contains -- $words[2] %s\n", names)
+	fmt.Fprintln(w, "\t\techo $words[2]")
+	fmt.Fprintln(w, "\telse")
+	fmt.Fprintf(w, "\t\techo %s\n", a.Default)
+	fmt.Fprintln(w, "\tend")
+	fmt.Fprintln(w, "end")
+	fmt.Fprintln(w, "")
+	fmt.Fprintf(w, "function %s_using\n", fn)
+	fmt.Fprintf(w, "\ttest (%s_command) = $argv[1]\n", fn)
+	fmt.Fprintln(w, "end")
+	fmt.Fprintln(w, "")
+	fmt.Fprintf(w, "function %s_args\n", fn)
+	fmt.Fprintln(w, "\tfor word in (commandline -opc)[3..-1]")
+	fmt.Fprintln(w, "\t\tstring match -qv -- '-*' $word; and echo $word")
+	fmt.Fprintln(w, "\tend")
+	fmt.Fprintln(w, "end")
+	fmt.Fprintln(w, "")
+	fmt.Fprintf(w, "complete -c %s -f\n", a.Name)
+	for _, c := range a.visible() {
+		fmt.Fprintf(w, "complete -c %s -n '__fish_use_subcommand' -a %s -d '%s'\n", a.Name, c.Name, fishQuote(c.Summary))
+	}
+	for _, c := range a.visible() {
+		cond := fmt.Sprintf("%s_using %s", fn, c.Name)
+		for _, f := range c.Flags {
+			line := fmt.Sprintf("complete -c %s -n '%s'", a.Name, cond)
+			if f.Short != "" {
+				line += " -s " + f.Short
+			}
+			line += " -l " + f.Name
+			switch {
+			case f.IsSwitch():
+			case f.Values != nil:
+				line += fmt.Sprintf(" -x -a '(%s __complete %s %s)'", a.Name, c.Name, f.Name)
+			case f.Arg == "file":
+				line += " -r -F"
+			default:
+				line += " -x"
+			}
+			fmt.Fprintf(w, "%s -d '%s'\n", line, fishQuote(f.Usage))
+		}
+		if c.Args != nil {
+			fmt.Fprintf(w, "complete -c %s -n '%s' -a '(%s __complete %s \"\" (%s_args))'\n", a.Name, cond, a.Name, c.Name, fn)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// roff escapes text for a man page line.
+func roff(text string) string {
+	text = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(text)
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = `\&` + text
+	}
+	return text
+}
+
+// WriteMan writes a man page in section 1 covering every visible command.
+func (a *App) WriteMan(w io.Writer) {
+	upper := strings.ToUpper(a.Name)
+	fmt.Fprintf(w, ".TH %s 1 \"\" \"%s %s\" \"User Commands\"\n", upper, a.Name, roff(a.Version))
+	fmt.Fprintln(w, ".SH NAME")
+	fmt.Fprintf(w, "%s \\- %s\n", a.Name, roff(a.Summary))
+
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	for i, c := range a.visible() {
+		if i > 0 {
+			fmt.Fprintln(w, ".br")
+		}
+		for j, line := range a.synopsis(c) {
+			if j > 0 {
+				fmt.Fprintln(w, ".br")
+			}
+			name, rest, _ := strings.Cut(line, " ")
+			fmt.Fprintf(w, ".B %s\n", name)
+			if rest != "" {
+				fmt.Fprintln(w, roff(rest))
+			}
+		}
+	}
+
+	if a.Description != "" {
+		fmt.Fprintln(w, ".SH DESCRIPTION")
+		for _, para := range strings.Split(a.Description, "\n\n") {
+			fmt.Fprintln(w, ".PP")
+			fmt.Fprintln(w, roff(para))
+		}
+	}
+
+	fmt.Fprintln(w, ".SH COMMANDS")
+	for _, c := range a.visible() {
+		fmt.Fprintln(w, ".TP")
+		fmt.Fprintf(w, ".B %s\n", c.Name)
+		fmt.Fprintln(w, roff(c.Summary))
+	}
+
+	for _, c := range a.visible() {
+		if len(c.Flags) == 0 {
+			continue
+		}
+		if c.Name == a.Default {
+			fmt.Fprintln(w, ".SH OPTIONS")
+		} else {
+			fmt.Fprintf(w, ".SH \"%s OPTIONS\"\n", strings.ToUpper(c.Name))
+		}
+		for _, f := range c.Flags {
+			fmt.Fprintln(w, ".TP")
+			fmt.Fprintln(w, manFlag(f))
+			fmt.Fprintln(w, roff(flagUsage(f)))
+		}
+	}
+}
+
+func manFlag(f *Flag) string {
+	spec := `\fB\-\-` + roff(f.Name) + `\fR`
+	if f.Short != "" {
+		spec = `\fB\-` + f.Short + `\fR, ` + spec
+	}
+	if f.Arg != "" {
+		if f.IsSwitch() {
+			spec += `[=\fI` + roff(f.Arg) + `\fR]`
+		} else {
+			spec += ` \fI` + roff(f.Arg) + `\fR`
+		}
+	}
+	return spec
+}
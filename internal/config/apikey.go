@@ -0,0 +1,151 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/MenschMachine/gommit/internal/keyring"
+)
+
+// KeyringService is the Secret Service "service" attribute gommit stores keys under.
+const KeyringService = "gommit"
+
+var apiKeyCache = struct {
+	sync.Mutex
+	keys map[string]string
+}{keys: map[string]string{}}
+
+// ResolveAPIKey looks up the API key for provider from, in order, the
+// environment, api_key_cmd, api_key_file and the OS keyring. Resolved keys
+// are cached for the lifetime of the process. Cancelling ctx stops waiting
+// for the keyring to be unlocked.
+func ResolveAPIKey(ctx context.Context, cfg Config, provider string) (string, error) {
+	provider = strings.ToLower(provider)
+	cacheKey := strings.Join([]string{provider, cfg.APIKeyCmd, cfg.APIKeyFile}, "\x00")
+
+	apiKeyCache.Lock()
+	defer apiKeyCache.Unlock()
+	if key, ok := apiKeyCache.keys[cacheKey]; ok {
+		return key, nil
+	}
+	key, err := resolveAPIKey(ctx, cfg, provider)
+	if err != nil {
+		return "", err
+	}
+	apiKeyCache.keys[cacheKey] = key
+	return key, nil
+}
+
+func resolveAPIKey(ctx context.Context, cfg Config, provider string) (string, error) {
+	envKeys := apiKeyEnvVars(provider)
+	for _, key := range envKeys {
+		val := strings.TrimSpace(os.Getenv(key))
+		if val != "" {
+			return val, nil
+		}
+	}
+
+	if cmd := strings.TrimSpace(cfg.APIKeyCmd); cmd != "" {
+		return apiKeyFromCommand(cmd)
+	}
+	if path := strings.TrimSpace(cfg.APIKeyFile); path != "" {
+		return apiKeyFromFile(path)
+	}
+
+	val, err := keyring.Get(ctx, KeyringService, provider)
+	if err == nil && strings.TrimSpace(val) != "" {
+		return strings.TrimSpace(val), nil
+	}
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) && !errors.Is(err, keyring.ErrUnavailable) {
+		return "", fmt.Errorf("reading API key for provider %q from keyring: %w", provider, err)
+	}
+
+	return "", fmt.Errorf("missing API key for provider %q; set %s, api_key_cmd or api_key_file, or run `gommit auth login %s`", provider, envKeys[0], provider)
+}
+
+func apiKeyEnvVars(provider string) []string {
+	keys := []string{"GOMMIT_API_KEY"}
+	switch provider {
+	case "openai":
+		keys = append([]string{"OPENAI_API_KEY"}, keys...)
+	case "openrouter":
+		keys = append([]string{"OPENROUTER_API_KEY"}, keys...)
+	case "anthropic":
+		keys = append([]string{"ANTHROPIC_API_KEY"}, keys...)
+	}
+	return keys
+}
+
+// apiKeyFromCommand runs cmd through the shell and uses the first line of
+// its output, matching the convention of password managers like pass.
+func apiKeyFromCommand(cmd string) (string, error) {
+	c := exec.Command("sh", "-c", cmd)
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	c.Stdin = os.Stdin
+	if err := c.Run(); err != nil {
+		errMsg := strings.TrimSpace(stderr.String())
+		if errMsg == "" {
+			errMsg = err.Error()
+		}
+		return "", fmt.Errorf("api_key_cmd %q failed: %s", cmd, errMsg)
+	}
+	key := firstLine(stdout.String())
+	if key == "" {
+		return "", fmt.Errorf("api_key_cmd %q printed no key", cmd)
+	}
+	return key, nil
+}
+
+func apiKeyFromFile(path string) (string, error) {
+	path, err := ExpandHome(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading api_key_file: %w", err)
+	}
+	key := firstLine(string(data))
+	if key == "" {
+		return "", fmt.Errorf("api_key_file %s is empty", path)
+	}
+	return key, nil
+}
+
+// StoreAPIKey saves key for provider in the OS keyring.
+func StoreAPIKey(ctx context.Context, provider, key string) error {
+	provider = strings.ToLower(provider)
+	label := fmt.Sprintf("gommit API key (%s)", provider)
+	return keyring.Set(ctx, KeyringService, provider, label, key)
+}
+
+func firstLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// ExpandHome replaces a leading ~ in path with the user home directory.
+func ExpandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MenschMachine/gommit/internal/keyring"
+)
+
+func clearAPIKeyEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{"OPENAI_API_KEY", "OPENROUTER_API_KEY", "ANTHROPIC_API_KEY", "GOMMIT_API_KEY"} {
+		t.Setenv(key, "")
+	}
+}
+
+func TestResolveAPIKeyFromCommand(t *testing.T) {
+	clearAPIKeyEnv(t)
+	cfg := DefaultConfig()
+	cfg.APIKeyCmd = "printf 'sk-cmd\\nurl: example\\n'"
+
+	key, err := ResolveAPIKey(context.Background(), cfg, "openai")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if key != "sk-cmd" {
+		t.Fatalf("expected first line of command output, got %q", key)
+	}
+}
+
+func TestResolveAPIKeyCommandFailure(t *testing.T) {
+	clearAPIKeyEnv(t)
+	cfg := DefaultConfig()
+	cfg.APIKeyCmd = "echo locked >&2; exit 3"
+
+	_, err := ResolveAPIKey(context.Background(), cfg, "openai")
+	if err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("expected command stderr in error, got %v", err)
+	}
+}
+
+func TestResolveAPIKeyFromFile(t *testing.T) {
+	clearAPIKeyEnv(t)
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte("\nsk-file\n"), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.APIKeyFile = path
+
+	key, err := ResolveAPIKey(context.Background(), cfg, "openrouter")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if key != "sk-file" {
+		t.Fatalf("expected key from file, got %q", key)
+	}
+}
+
+func TestResolveAPIKeyPrefersEnv(t *testing.T) {
+	clearAPIKeyEnv(t)
+	t.Setenv("ANTHROPIC_API_KEY", "sk-env")
+	cfg := DefaultConfig()
+	cfg.APIKeyCmd = "exit 1"
+
+	key, err := ResolveAPIKey(context.Background(), cfg, "anthropic")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if key != "sk-env" {
+		t.Fatalf("expected env key, got %q", key)
+	}
+}
+
+func TestAPIKeyWithoutKeyring(t *testing.T) {
+	clearAPIKeyEnv(t)
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path=/nonexistent/bus")
+
+	// An unreachable keyring is skipped, leaving the missing key error.
+	_, err := ResolveAPIKey(context.Background(), DefaultConfig(), "openai")
+	if err == nil || !strings.Contains(err.Error(), "gommit auth login openai") {
+		t.Fatalf("expected missing key error, got %v", err)
+	}
+	if err := StoreAPIKey(context.Background(), "openai", "sk-new"); !errors.Is(err, keyring.ErrUnavailable) {
+		t.Fatalf("StoreAPIKey = %v, want keyring.ErrUnavailable", err)
+	}
+}
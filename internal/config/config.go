@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/BurntSushi/toml"
+
+	"github.com/MenschMachine/gommit/internal/issue"
+	"github.com/MenschMachine/gommit/internal/scopes"
 )
 
 type Config struct {
@@ -21,6 +25,107 @@ type Config struct {
 	Timeout         int    `toml:"timeout"`
 	OpenRouterRef   string `toml:"openrouter_referer"`
 	OpenRouterTitle string `toml:"openrouter_title"`
+	APIKeyCmd       string `toml:"api_key_cmd"`
+	APIKeyFile      string `toml:"api_key_file"`
+	LintRetries     int    `toml:"lint_retries"`
+
+	// ImportantFiles are globs of files whose diffs keep the most detail
+	// when max_prompt_chars forces the diff to be reduced.
+	ImportantFiles []string `toml:"important_files"`
+
+	SystemTemplate     string `toml:"system_template"`
+	SystemTemplateFile string `toml:"system_template_file"`
+	UserTemplate       string `toml:"user_template"`
+	UserTemplateFile   string `toml:"user_template_file"`
+
+	Lint    LintConfig    `toml:"lint"`
+	Scopes  ScopesConfig  `toml:"scopes"`
+	Symbols SymbolsConfig `toml:"symbols"`
+	Issue   IssueConfig   `toml:"issue"`
+	Cache   CacheConfig   `toml:"cache"`
+	Guard   GuardConfig   `toml:"guard"`
+
+	// Prices maps model names to their price per million tokens in USD.
+	Prices map[string]Price `toml:"prices"`
+
+	// CoAuthors maps --co-author aliases to "Name <email>".
+	CoAuthors map[string]string `toml:"co_authors"`
+}
+
+// LintConfig overrides the rules of the selected style for generated
+// messages and `gommit lint`.
+type LintConfig struct {
+	Types            []string `toml:"types"`
+	Scopes           []string `toml:"scopes"`
+	RequireScope     bool     `toml:"require_scope"`
+	SubjectMax       int      `toml:"subject_max"`
+	BodyWrap         int      `toml:"body_wrap"`
+	RequiredTrailers []string `toml:"required_trailers"`
+}
+
+// ScopesConfig controls how Conventional Commit scopes are inferred from
+// the changed files. Rules are tried in order; Derive ("dir", "go-package"
+// or "package-json") applies to files no rule matches.
+type ScopesConfig struct {
+	Derive string      `toml:"derive"`
+	Rules  []ScopeRule `toml:"rules"`
+}
+
+// SymbolsConfig controls the summary of functions, types and methods a
+// change adds, removes or modifies. Go files are parsed; in other files
+// declarations are found line by line with Rules, which are tried before
+// the built-in ones.
+type SymbolsConfig struct {
+	Disable bool         `toml:"disable"`
+	Rules   []SymbolRule `toml:"rules"`
+}
+
+// SymbolRule finds declarations of Kind in files matching Glob. The first
+// capture group of Pattern, or the group named "name", is the symbol name.
+type SymbolRule struct {
+	Glob    string `toml:"glob"`
+	Kind    string `toml:"kind"`
+	Pattern string `toml:"pattern"`
+}
+
+// IssueConfig extracts issue keys from the branch name with Pattern and
+// adds them to the message as a subject prefix, body footer or git trailer.
+// Template is rendered with .Key and .Keys.
+type IssueConfig struct {
+	Pattern   string `toml:"pattern"`
+	Placement string `toml:"placement"`
+	Template  string `toml:"template"`
+}
+
+// CacheConfig limits the on-disk response cache. TTL is in seconds; a TTL
+// of 0 disables the cache.
+type CacheConfig struct {
+	TTL        int `toml:"ttl"`
+	MaxEntries int `toml:"max_entries"`
+}
+
+// GuardConfig stops oversized or expensive requests before they are sent.
+// MaxTokens is the estimated prompt size, MaxCost the estimated cost of one
+// request and DailyBudget the spend per day, all in USD. Zero disables a
+// limit.
+type GuardConfig struct {
+	MaxTokens   int     `toml:"max_tokens"`
+	MaxCost     float64 `toml:"max_cost"`
+	DailyBudget float64 `toml:"daily_budget"`
+}
+
+// Price is the cost of a model in USD per million tokens. CachedInput
+// applies to prompt tokens served from the provider's cache and defaults
+// to Input.
+type Price struct {
+	Input       float64 `toml:"input"`
+	CachedInput float64 `toml:"cached_input"`
+	Output      float64 `toml:"output"`
+}
+
+type ScopeRule struct {
+	Glob  string `toml:"glob"`
+	Scope string `toml:"scope"`
 }
 
 func DefaultConfig() Config {
@@ -34,6 +139,16 @@ func DefaultConfig() Config {
 		Timeout:         120,
 		OpenRouterRef:   "",
 		OpenRouterTitle: "",
+		APIKeyCmd:       "",
+		APIKeyFile:      "",
+		LintRetries:     2,
+		Cache: CacheConfig{
+			TTL:        24 * 60 * 60,
+			MaxEntries: 200,
+		},
+		Guard: GuardConfig{
+			MaxTokens: 100000,
+		},
 	}
 }
 
@@ -45,6 +160,19 @@ func DefaultConfigPath() (string, error) {
 	return filepath.Join(home, ".config", "gommit", "config.toml"), nil
 }
 
+// StateDir returns $XDG_STATE_HOME/gommit, falling back to
+// ~/.local/state/gommit.
+func StateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "gommit"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "gommit"), nil
+}
+
 func Load(path string) (Config, error) {
 	cfg := DefaultConfig()
 	if path == "" {
@@ -63,6 +191,12 @@ func Load(path string) (Config, error) {
 	if _, err := toml.DecodeFile(path, &cfg); err != nil {
 		return cfg, err
 	}
+	if d := cfg.Scopes.Derive; d != scopes.DeriveNone && !slices.Contains(scopes.DeriveModes, d) {
+		return cfg, fmt.Errorf("%s: unknown scopes.derive %q (valid: %s)", path, d, strings.Join(scopes.DeriveModes, ", "))
+	}
+	if err := issue.CheckPlacement(cfg.Issue.Placement); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
 	return cfg, nil
 }
 
@@ -78,6 +212,14 @@ func ApplyEnvOverrides(cfg *Config) {
 	setStringEnv(&cfg.OpenRouterTitle, "GOMMIT_OPENROUTER_TITLE")
 	setStringEnv(&cfg.OpenRouterRef, "OPENROUTER_REFERER")
 	setStringEnv(&cfg.OpenRouterTitle, "OPENROUTER_TITLE")
+	setStringEnv(&cfg.APIKeyCmd, "GOMMIT_API_KEY_CMD")
+	setStringEnv(&cfg.APIKeyFile, "GOMMIT_API_KEY_FILE")
+	setIntEnv(&cfg.LintRetries, "GOMMIT_LINT_RETRIES")
+	setStringEnv(&cfg.Issue.Pattern, "GOMMIT_ISSUE_PATTERN")
+	setIntEnv(&cfg.Cache.TTL, "GOMMIT_CACHE_TTL")
+	setIntEnv(&cfg.Guard.MaxTokens, "GOMMIT_MAX_TOKENS")
+	setStringEnv(&cfg.SystemTemplateFile, "GOMMIT_SYSTEM_TEMPLATE_FILE")
+	setStringEnv(&cfg.UserTemplateFile, "GOMMIT_USER_TEMPLATE_FILE")
 }
 
 func setStringEnv(target *string, key string) {
@@ -99,25 +241,6 @@ func setIntEnv(target *int, key string) {
 	*target = parsed
 }
 
-func ResolveAPIKey(provider string) (string, error) {
-	keys := []string{"GOMMIT_API_KEY"}
-	switch strings.ToLower(provider) {
-	case "openai":
-		keys = append([]string{"OPENAI_API_KEY"}, keys...)
-	case "openrouter":
-		keys = append([]string{"OPENROUTER_API_KEY"}, keys...)
-	case "anthropic":
-		keys = append([]string{"ANTHROPIC_API_KEY"}, keys...)
-	}
-	for _, key := range keys {
-		val := strings.TrimSpace(os.Getenv(key))
-		if val != "" {
-			return val, nil
-		}
-	}
-	return "", fmt.Errorf("missing API key for provider %q", provider)
-}
-
 func DefaultBaseURL(provider string) string {
 	switch strings.ToLower(provider) {
 	case "openai":
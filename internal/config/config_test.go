@@ -0,0 +1,35 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadRejectsUnknownModes(t *testing.T) {
+	tests := []struct {
+		toml string
+		// wantErr lists the valid values the error must name; empty for no error.
+		wantErr string
+	}{
+		{"[scopes]\nderive = \"\"\n", ""},
+		{"[scopes]\nderive = \"go-package\"\n", ""},
+		{"[scopes]\nderive = \"go-pkg\"\n", "dir, go-package, package-json"},
+		{"[issue]\nplacement = \"trailer\"\n", ""},
+		{"[issue]\nplacement = \"suffix\"\n", "prefix, footer, trailer"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tt.toml), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(path)
+		if (err != nil) != (tt.wantErr != "") {
+			t.Fatalf("Load(%q): err = %v, want error %v", tt.toml, err, tt.wantErr != "")
+		}
+		if err != nil && !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("error does not list the valid values: %v", err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadBlobs returns the content of the named blobs, read with a single
+// git cat-file. Names git cannot resolve to a blob are left out.
+func ReadBlobs(ctx context.Context, root string, names []string) (map[string][]byte, error) {
+	blobs := map[string][]byte{}
+	if len(names) == 0 {
+		return blobs, nil
+	}
+	cmd := gitCommand{dir: root, stdin: strings.NewReader(strings.Join(names, "\n") + "\n")}
+	err := cmd.stream(ctx, func(r io.Reader) error {
+		br := bufio.NewReader(r)
+		for _, name := range names {
+			header, err := br.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			// "<oid> <type> <size>", or "<name> missing" and the like.
+			fields := strings.Fields(header)
+			if len(fields) != 3 {
+				continue
+			}
+			size, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return fmt.Errorf("git cat-file: bad header %q", strings.TrimSpace(header))
+			}
+			content := make([]byte, size+1)
+			if _, err := io.ReadFull(br, content); err != nil {
+				return err
+			}
+			if fields[1] == "blob" {
+				blobs[name] = content[:size]
+			}
+		}
+		return nil
+	}, "cat-file", "--batch")
+	return blobs, err
+}
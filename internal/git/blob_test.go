@@ -0,0 +1,22 @@
+package git
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestReadBlobs(t *testing.T) {
+	dir := initRepo(t, map[string]string{"a.txt": "alpha\n", "empty": ""})
+	git(t, dir, "add", ".")
+	a := strings.TrimSpace(git(t, dir, "rev-parse", "--short", ":a.txt"))
+	empty := strings.TrimSpace(git(t, dir, "rev-parse", ":empty"))
+
+	blobs, err := ReadBlobs(context.Background(), dir, []string{a, "0123456", empty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 2 || string(blobs[a]) != "alpha\n" || blobs[empty] == nil || len(blobs[empty]) != 0 {
+		t.Fatalf("blobs = %q", blobs)
+	}
+}
@@ -0,0 +1,85 @@
+package git
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type Commit struct {
+	Hash    string
+	Message string
+}
+
+// CommitMessages returns the commits selected by rev, newest first. A
+// revision naming one commit selects just that commit; anything else, such
+// as main..HEAD, HEAD^! or --since=1.week, is passed to git log as is.
+func CommitMessages(root, rev string) ([]Commit, error) {
+	args := []string{"log", "--format=%H%x00%B%x00"}
+	if _, err := runGit(root, "rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}"); err == nil {
+		args = append(args, "-n", "1")
+	}
+	args = append(args, rev, "--")
+	out, err := runGit(root, args...)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Split(out, "\x00")
+	var commits []Commit
+	for i := 0; i+1 < len(fields); i += 2 {
+		hash := strings.TrimSpace(fields[i])
+		if hash == "" {
+			continue
+		}
+		commits = append(commits, Commit{Hash: hash, Message: strings.TrimSpace(fields[i+1])})
+	}
+	return commits, nil
+}
+
+// CollectCommitDiff returns the changes introduced by a single commit,
+// processed like CollectDiff.
+func CollectCommitDiff(ctx context.Context, root, rev string, perFileLimit int) (DiffResult, error) {
+	p, err := readPatch(ctx, gitCommand{dir: root}, "", perFileLimit,
+		patchArgs("show", "--format=", "--patch", rev)...)
+	if err != nil {
+		return DiffResult{}, err
+	}
+	return mergePatches([]patch{p}), nil
+}
+
+// HooksDir returns the directory git runs hooks from for the repository.
+func HooksDir(root string) (string, error) {
+	out, err := runGit(root, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	dir := strings.TrimSpace(out)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	return dir, nil
+}
+
+// RecentAuthors returns the distinct "Name <email>" identities of the last
+// n commit authors, newest first, with .mailmap applied.
+func RecentAuthors(root string, n int) ([]string, error) {
+	out, err := runGitAllowExitCodes(root, []int{0, 128}, "log", "-n", strconv.Itoa(n), "--format=%aN <%aE>")
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]struct{}{}
+	var authors []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if _, ok := seen[line]; ok {
+			continue
+		}
+		seen[line] = struct{}{}
+		authors = append(authors, line)
+	}
+	return authors, nil
+}
@@ -0,0 +1,40 @@
+package git
+
+import (
+	"os/exec"
+	"slices"
+	"testing"
+)
+
+func TestCommitMessagesSelection(t *testing.T) {
+	dir := initRepo(t, nil)
+	for _, msg := range []string{"one", "two", "three"} {
+		if out, err := exec.Command("git", "-C", dir, "commit", "-q", "--allow-empty", "-m", msg).CombinedOutput(); err != nil {
+			t.Fatalf("git commit: %v\n%s", err, out)
+		}
+	}
+	tests := []struct {
+		rev  string
+		want []string
+	}{
+		{"HEAD", []string{"three"}},
+		{"HEAD~1", []string{"two"}},
+		{"HEAD~2..HEAD", []string{"three", "two"}},
+		{"HEAD^!", []string{"three"}},
+		{"HEAD^@", []string{"two", "one"}},
+		{"--since=1970-01-02", []string{"three", "two", "one"}},
+	}
+	for _, tt := range tests {
+		commits, err := CommitMessages(dir, tt.rev)
+		if err != nil {
+			t.Fatalf("CommitMessages(%q): %v", tt.rev, err)
+		}
+		var got []string
+		for _, c := range commits {
+			got = append(got, c.Message)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("CommitMessages(%q) = %q, want %q", tt.rev, got, tt.want)
+		}
+	}
+}
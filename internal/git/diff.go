@@ -1,13 +1,14 @@
 package git
 
 import (
-	"bufio"
-	"fmt"
+	"context"
+	"errors"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
-	"unicode/utf8"
+	"sync"
 )
 
 type DiffScope int
@@ -21,6 +22,9 @@ const (
 type BinaryFile struct {
 	Path string
 	Size int64
+	// Status and OldPath describe the change as for a FileDiff.
+	Status  FileStatus
+	OldPath string
 }
 
 type DiffResult struct {
@@ -30,211 +34,183 @@ type DiffResult struct {
 	TotalOriginalLen int
 }
 
-func CollectDiff(root string, scope DiffScope, perFileLimit int) (DiffResult, error) {
-	var combined []string
-	binaryFiles := map[string]BinaryFile{}
-	var truncated []string
-	totalOriginal := 0
+// CollectDiff returns the changes of scope. Cancelling ctx stops the git
+// processes and returns ctx's error.
+func CollectDiff(ctx context.Context, root string, scope DiffScope, perFileLimit int) (DiffResult, error) {
+	return CollectDiffPaths(ctx, root, scope, perFileLimit, nil)
+}
 
-	if scope >= ScopeStaged {
-		bins, err := collectBinaryFiles(root, true)
-		if err != nil {
-			return DiffResult{}, err
-		}
-		for _, bf := range bins {
-			binaryFiles[bf.Path] = bf
-		}
-		out, err := runGitAllowExitCodes(root, []int{0, 1}, "diff", "--cached")
-		if err != nil {
-			return DiffResult{}, err
-		}
-		diffText, origLen, trunc := processDiff(out, perFileLimit)
-		totalOriginal += origLen
-		truncated = append(truncated, trunc...)
-		if diffText != "" {
-			combined = append(combined, diffText)
-		}
+// CollectDiffPaths is CollectDiff limited to the given pathspecs.
+//
+// Each part of the scope is read in a single git process, and the staged
+// and working tree parts run concurrently. Untracked files are diffed in the
+// same pass as unstaged changes by marking them intent-to-add in a copy of
+// the index, rather than with one `git diff --no-index` per file.
+func CollectDiffPaths(ctx context.Context, root string, scope DiffScope, perFileLimit int, pathspecs []string) (DiffResult, error) {
+	passes := []func(context.Context) (patch, error){
+		func(ctx context.Context) (patch, error) {
+			return readPatch(ctx, gitCommand{dir: root, allowed: []int{1}}, root, perFileLimit,
+				withPathspecs(diffArgs("--cached"), pathspecs)...)
+		},
 	}
-
 	if scope >= ScopeStagedUnstaged {
-		bins, err := collectBinaryFiles(root, false)
-		if err != nil {
-			return DiffResult{}, err
-		}
-		for _, bf := range bins {
-			binaryFiles[bf.Path] = bf
-		}
-		out, err := runGitAllowExitCodes(root, []int{0, 1}, "diff")
-		if err != nil {
-			return DiffResult{}, err
-		}
-		diffText, origLen, trunc := processDiff(out, perFileLimit)
-		totalOriginal += origLen
-		truncated = append(truncated, trunc...)
-		if diffText != "" {
-			combined = append(combined, diffText)
+		passes = append(passes, func(ctx context.Context) (patch, error) {
+			return worktreePatch(ctx, root, scope == ScopeAll, perFileLimit, pathspecs)
+		})
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	patches := make([]patch, len(passes))
+	errs := make([]error, len(passes))
+	var wg sync.WaitGroup
+	for i, pass := range passes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			patches[i], errs[i] = pass(ctx)
+			if errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		// The first failure cancels the other passes; report its cause.
+		for _, err := range errs {
+			if err != nil && !errors.Is(err, context.Canceled) {
+				return DiffResult{}, err
+			}
 		}
+		return DiffResult{}, err
 	}
+	return mergePatches(patches), nil
+}
 
-	if scope == ScopeAll {
-		files, err := listUntracked(root)
+// patchArgs returns the arguments for a git command printing a plain patch
+// that ParseDiff understands, independent of the user's diff configuration.
+// Non-ASCII paths are printed as they are rather than octal-escaped, and
+// renames and copies are detected so a moved file is not a delete plus add.
+func patchArgs(command string, extra ...string) []string {
+	args := []string{"-c", "core.quotePath=false", command, "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/",
+		"--find-renames", "--find-copies"}
+	return append(args, extra...)
+}
+
+func diffArgs(extra ...string) []string {
+	return patchArgs("diff", extra...)
+}
+
+// worktreePatch diffs the working tree against the index. With untracked,
+// files git does not know yet are added intent-to-add to a temporary copy
+// of the index first, so they show up as new files in the same pass.
+func worktreePatch(ctx context.Context, root string, untracked bool, perFileLimit int, pathspecs []string) (patch, error) {
+	cmd := gitCommand{dir: root, allowed: []int{1}}
+	if untracked {
+		files, err := listUntracked(ctx, root, pathspecs)
 		if err != nil {
-			return DiffResult{}, err
+			return patch{}, err
 		}
-		for _, file := range files {
-			abs := filepath.Join(root, file)
-			if isBinaryFile(abs) {
-				bf := BinaryFile{Path: file, Size: fileSize(abs)}
-				binaryFiles[bf.Path] = bf
-				continue
-			}
-			out, err := runGitAllowExitCodes(root, []int{0, 1}, "diff", "--no-index", "/dev/null", file)
+		if len(files) > 0 {
+			index, cleanup, err := untrackedIndex(ctx, root, files)
 			if err != nil {
-				return DiffResult{}, err
-			}
-			diffText, origLen, trunc := processDiff(out, perFileLimit)
-			totalOriginal += origLen
-			truncated = append(truncated, trunc...)
-			if diffText != "" {
-				combined = append(combined, diffText)
+				return patch{}, err
 			}
+			defer cleanup()
+			cmd.env = []string{"GIT_INDEX_FILE=" + index}
 		}
 	}
+	return readPatch(ctx, cmd, root, perFileLimit, withPathspecs(diffArgs(), pathspecs)...)
+}
 
-	binaryList := make([]BinaryFile, 0, len(binaryFiles))
-	for _, bf := range binaryFiles {
-		binaryList = append(binaryList, bf)
+// untrackedIndex copies the repository's index and adds files to the copy
+// with --intent-to-add. The real index is not touched.
+func untrackedIndex(ctx context.Context, root string, files []string) (string, func(), error) {
+	src, err := GitPath(root, "index")
+	if err != nil {
+		return "", nil, err
 	}
-
-	return DiffResult{
-		Diff:             strings.Join(combined, "\n"),
-		Binary:           binaryList,
-		TruncatedFiles:   uniqueStrings(truncated),
-		TotalOriginalLen: totalOriginal,
-	}, nil
+	dir, err := os.MkdirTemp("", "gommit-index-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	index := filepath.Join(dir, "index")
+	if err := copyFile(src, index); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		cleanup()
+		return "", nil, err
+	}
+	add := gitCommand{
+		dir:   root,
+		env:   []string{"GIT_INDEX_FILE=" + index, "GIT_LITERAL_PATHSPECS=1"},
+		stdin: strings.NewReader(strings.Join(files, "\x00")),
+	}
+	if _, err := add.run(ctx, "add", "--intent-to-add", "--pathspec-from-file=-", "--pathspec-file-nul"); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return index, cleanup, nil
 }
 
-func collectBinaryFiles(root string, cached bool) ([]BinaryFile, error) {
-	args := []string{"diff", "--numstat"}
-	if cached {
-		args = append(args, "--cached")
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
 	}
-	out, err := runGitAllowExitCodes(root, []int{0, 1}, args...)
+	defer in.Close()
+	out, err := os.Create(dst)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var binaries []BinaryFile
-	scanner := bufio.NewScanner(strings.NewReader(out))
-	for scanner.Scan() {
-		line := scanner.Text()
-		parts := strings.Split(line, "\t")
-		if len(parts) < 3 {
-			continue
-		}
-		if parts[0] == "-" || parts[1] == "-" {
-			path := parts[2]
-			binaries = append(binaries, BinaryFile{Path: path, Size: fileSize(filepath.Join(root, path))})
-		}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
 	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	return out.Close()
+}
+
+// withPathspecs appends pathspecs to args after "--".
+func withPathspecs(args, pathspecs []string) []string {
+	if len(pathspecs) == 0 {
+		return args
 	}
-	return binaries, nil
+	return append(append(args, "--"), pathspecs...)
 }
 
-func listUntracked(root string) ([]string, error) {
-	out, err := runGit(root, "ls-files", "--others", "--exclude-standard")
+func listUntracked(ctx context.Context, root string, pathspecs []string) ([]string, error) {
+	out, err := runGitContext(ctx, root, nil, withPathspecs([]string{"ls-files", "-z", "--others", "--exclude-standard"}, pathspecs)...)
 	if err != nil {
 		return nil, err
 	}
-	lines := strings.Split(strings.TrimSpace(out), "\n")
 	var files []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+	for _, file := range strings.Split(out, "\x00") {
+		if file != "" {
+			files = append(files, file)
 		}
-		files = append(files, line)
 	}
 	return files, nil
 }
 
-func processDiff(diff string, perFileLimit int) (string, int, []string) {
-	diff = strings.TrimSpace(diff)
-	if diff == "" {
-		return "", 0, nil
-	}
-	chunks := splitDiffChunks(diff)
-	var kept []string
-	var truncated []string
-	totalOriginal := 0
-	for _, chunk := range chunks {
-		if isBinaryChunk(chunk) {
-			continue
-		}
-		origLen := len(chunk)
-		totalOriginal += origLen
-		if perFileLimit > 0 && origLen > perFileLimit {
-			path := parseDiffPath(chunk)
-			head := perFileLimit / 2
-			tail := perFileLimit - head
-			marker := fmt.Sprintf("\n[gommit] diff truncated for %s: showing first %d and last %d chars of %d total\n", path, head, tail, origLen)
-			chunk = chunk[:head] + marker + chunk[origLen-tail:]
-			if path != "" {
-				truncated = append(truncated, path)
-			}
-		}
-		kept = append(kept, chunk)
-	}
-	return strings.Join(kept, "\n"), totalOriginal, truncated
-}
-
-func SplitDiffChunks(diff string) []string {
-	return splitDiffChunks(diff)
-}
-
-func splitDiffChunks(diff string) []string {
-	if strings.HasPrefix(diff, "diff --git ") {
-		parts := strings.Split(diff, "\ndiff --git ")
-		chunks := make([]string, 0, len(parts))
-		for i, part := range parts {
-			if i == 0 {
-				chunks = append(chunks, part)
-				continue
+// mergePatches joins the passes of a scope in order. A file changed in
+// more than one pass keeps one binary entry.
+func mergePatches(patches []patch) DiffResult {
+	var res DiffResult
+	var chunks, truncated []string
+	seen := map[string]bool{}
+	for _, p := range patches {
+		chunks = append(chunks, p.chunks...)
+		truncated = append(truncated, p.truncated...)
+		res.TotalOriginalLen += p.total
+		for _, bf := range p.binaries {
+			if !seen[bf.Path] {
+				seen[bf.Path] = true
+				res.Binary = append(res.Binary, bf)
 			}
-			chunks = append(chunks, "diff --git "+part)
 		}
-		return chunks
-	}
-	return []string{diff}
-}
-
-func ParseDiffPath(chunk string) string {
-	return parseDiffPath(chunk)
-}
-
-func parseDiffPath(chunk string) string {
-	lines := strings.SplitN(chunk, "\n", 2)
-	if len(lines) == 0 {
-		return ""
-	}
-	line := strings.TrimSpace(lines[0])
-	if !strings.HasPrefix(line, "diff --git ") {
-		return ""
-	}
-	fields := strings.Fields(line)
-	if len(fields) < 4 {
-		return ""
 	}
-	bPath := strings.TrimPrefix(fields[3], "b/")
-	if bPath == "/dev/null" {
-		bPath = strings.TrimPrefix(fields[2], "a/")
-	}
-	return bPath
-}
-
-func isBinaryChunk(chunk string) bool {
-	return strings.Contains(chunk, "GIT binary patch") || strings.Contains(chunk, "Binary files ")
+	res.Diff = strings.Join(chunks, "\n")
+	res.TruncatedFiles = uniqueStrings(truncated)
+	return res
 }
 
 func fileSize(path string) int64 {
@@ -245,33 +221,6 @@ func fileSize(path string) int64 {
 	return info.Size()
 }
 
-func isBinaryFile(path string) bool {
-	f, err := os.Open(path)
-	if err != nil {
-		return false
-	}
-	defer f.Close()
-	buf := make([]byte, 8000)
-	n, err := f.Read(buf)
-	if err != nil && err != io.EOF {
-		return false
-	}
-	buf = buf[:n]
-	if bytesContainZero(buf) {
-		return true
-	}
-	return !utf8.Valid(buf)
-}
-
-func bytesContainZero(buf []byte) bool {
-	for _, b := range buf {
-		if b == 0 {
-			return true
-		}
-	}
-	return false
-}
-
 func uniqueStrings(items []string) []string {
 	seen := map[string]struct{}{}
 	var out []string
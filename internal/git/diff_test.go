@@ -1,43 +1,181 @@
 package git
 
 import (
+	"context"
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 )
 
-func TestProcessDiffTruncatesLargeFile(t *testing.T) {
+func TestScanPatchTruncatesLargeFile(t *testing.T) {
 	chunk := strings.Repeat("a", 50)
 	diff := "diff --git a/foo.txt b/foo.txt\n" + chunk
 
-	out, total, truncated := processDiff(diff, 20)
-	if total != len(diff) {
-		t.Fatalf("expected total %d, got %d", len(diff), total)
+	p, err := scanPatch(strings.NewReader(diff), "", 20)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if !strings.Contains(out, "diff truncated") {
+	if p.total != len(diff) {
+		t.Fatalf("expected total %d, got %d", len(diff), p.total)
+	}
+	if len(p.chunks) != 1 || !strings.Contains(p.chunks[0], "diff truncated") {
 		t.Fatalf("expected truncation marker in output")
 	}
-	if len(truncated) != 1 || truncated[0] != "foo.txt" {
-		t.Fatalf("expected truncated file foo.txt, got %v", truncated)
+	if !strings.HasPrefix(p.chunks[0], diff[:10]) || !strings.HasSuffix(p.chunks[0], diff[len(diff)-10:]) {
+		t.Fatalf("expected head and tail to be kept:\n%s", p.chunks[0])
+	}
+	if len(p.truncated) != 1 || p.truncated[0] != "foo.txt" {
+		t.Fatalf("expected truncated file foo.txt, got %v", p.truncated)
+	}
+}
+
+func TestScanPatch(t *testing.T) {
+	diff := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-x\n+diff --git a/b b/b\n+Binary files x\n" +
+		"diff --git a/logo.png b/logo.png\nnew file mode 100644\nBinary files /dev/null and b/logo.png differ\n" +
+		"diff --git a/c.txt b/c.txt\n--- a/c.txt\n+++ b/c.txt\n@@ -1 +1 @@\n-y\n+z\n"
+	p, err := scanPatch(strings.NewReader(diff), "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.chunks) != 2 || !strings.HasSuffix(p.chunks[0], "+Binary files x") || !strings.HasPrefix(p.chunks[1], "diff --git a/c.txt") {
+		t.Fatalf("chunks = %q", p.chunks)
+	}
+	if len(p.binaries) != 1 || p.binaries[0].Path != "logo.png" || p.binaries[0].Size != -1 {
+		t.Fatalf("binaries = %+v", p.binaries)
 	}
 }
 
-func TestIsBinaryFile(t *testing.T) {
+// initRepo creates a repository in a temporary directory with files
+// written but not added.
+func initRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
 	dir := t.TempDir()
-	binPath := filepath.Join(dir, "bin.dat")
-	if err := os.WriteFile(binPath, []byte{0x00, 0x01, 0x02}, 0o600); err != nil {
-		t.Fatalf("write binary: %v", err)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.name", "Test"},
+		{"config", "user.email", "test@example.com"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCollectDiffCancelled(t *testing.T) {
+	dir := initRepo(t, map[string]string{"a.txt": "a\n"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := CollectDiff(ctx, dir, ScopeAll, 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+
+	res, err := CollectDiff(context.Background(), dir, ScopeAll, 0)
+	if err != nil || !strings.Contains(res.Diff, "+a") {
+		t.Fatalf("CollectDiff = %q, %v", res.Diff, err)
+	}
+}
+
+func TestScanPatchStreamsLongChunks(t *testing.T) {
+	body := strings.Repeat("+"+strings.Repeat("x", 70000)+"\n", 30)
+	diff := "diff --git a/big.txt b/big.txt\n@@ -0,0 +1,30 @@\n" + body
+	p, err := scanPatch(strings.NewReader(diff), "", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := strings.TrimSuffix(diff, "\n")
+	want := text[:500] + "\n[gommit] diff truncated for big.txt: showing first 500 and last 500 chars of " +
+		strconv.Itoa(len(text)) + " total\n" + text[len(text)-500:]
+	if len(p.chunks) != 1 || p.chunks[0] != want {
+		t.Fatalf("chunk does not keep head and tail of %d chars", len(text))
+	}
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func TestCollectDiffScopes(t *testing.T) {
+	dir := initRepo(t, map[string]string{"tracked.txt": "one\n", "staged.txt": "a\n"})
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "init")
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("staged.txt", "a\nb\n")
+	git(t, dir, "add", "staged.txt")
+	write("tracked.txt", "one\ntwo\n")
+	write("new.txt", "fresh\n")
+	write("logo.png", "\x89PNG\x00\x01")
+	status := git(t, dir, "status", "--porcelain")
+
+	tests := []struct {
+		scope    DiffScope
+		want     []string
+		absent   []string
+		binaries int
+	}{
+		{ScopeStaged, []string{"+b"}, []string{"+two", "+fresh"}, 0},
+		{ScopeStagedUnstaged, []string{"+b", "+two"}, []string{"+fresh"}, 0},
+		{ScopeAll, []string{"+b", "+two", "diff --git a/new.txt b/new.txt\nnew file mode", "+fresh"}, nil, 1},
 	}
-	if !isBinaryFile(binPath) {
-		t.Fatalf("expected binary file detection")
+	for _, tt := range tests {
+		res, err := CollectDiff(context.Background(), dir, tt.scope, 0)
+		if err != nil {
+			t.Fatalf("scope %d: %v", tt.scope, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(res.Diff, want) {
+				t.Errorf("scope %d: diff does not contain %q:\n%s", tt.scope, want, res.Diff)
+			}
+		}
+		for _, absent := range tt.absent {
+			if strings.Contains(res.Diff, absent) {
+				t.Errorf("scope %d: diff contains %q", tt.scope, absent)
+			}
+		}
+		if len(res.Binary) != tt.binaries || (tt.binaries > 0 && res.Binary[0] != BinaryFile{Path: "logo.png", Size: 6, Status: StatusAdded}) {
+			t.Errorf("scope %d: binaries = %+v", tt.scope, res.Binary)
+		}
 	}
+	if got := git(t, dir, "status", "--porcelain"); got != status {
+		t.Fatalf("collecting changed the index:\n%s\nwant:\n%s", got, status)
+	}
+}
+
+func TestCollectDiffDetectsRenames(t *testing.T) {
+	dir := initRepo(t, map[string]string{"old.txt": strings.Repeat("line\n", 20)})
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "init")
+	git(t, dir, "mv", "old.txt", "new.txt")
 
-	textPath := filepath.Join(dir, "text.txt")
-	if err := os.WriteFile(textPath, []byte("hello world"), 0o600); err != nil {
-		t.Fatalf("write text: %v", err)
+	res, err := CollectDiff(context.Background(), dir, ScopeStaged, 0)
+	if err != nil {
+		t.Fatal(err)
 	}
-	if isBinaryFile(textPath) {
-		t.Fatalf("expected text file to not be binary")
+	files := ParseDiff(res.Diff)
+	if len(files) != 1 || files[0].Status != StatusRenamed || files[0].OldPath != "old.txt" || files[0].NewPath != "new.txt" || len(files[0].Hunks) != 0 {
+		t.Fatalf("files = %+v", files)
 	}
 }
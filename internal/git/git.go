@@ -2,13 +2,24 @@ package git
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 )
 
 func RepoRoot() (string, error) {
-	out, err := runGit("", "rev-parse", "--show-toplevel")
+	return RepoRootAt("")
+}
+
+// RepoRootAt returns the top level of the repository containing dir.
+func RepoRootAt(dir string) (string, error) {
+	out, err := runGit(dir, "rev-parse", "--show-toplevel")
 	if err != nil {
 		return "", fmt.Errorf("not a git repository: %w", err)
 	}
@@ -20,29 +31,195 @@ func runGit(dir string, args ...string) (string, error) {
 }
 
 func runGitAllowExitCodes(dir string, allowed []int, args ...string) (string, error) {
-	cmd := exec.Command("git", args...)
-	if dir != "" {
-		cmd.Dir = dir
+	return runGitContext(context.Background(), dir, allowed, args...)
+}
+
+// runGitContext is runGitAllowExitCodes for commands that should stop when
+// ctx is cancelled. The process is killed and ctx's error is returned.
+func runGitContext(ctx context.Context, dir string, allowed []int, args ...string) (string, error) {
+	return gitCommand{dir: dir, allowed: allowed}.run(ctx, args...)
+}
+
+// gitCommand holds how to run git: in which directory, with which extra
+// environment and input, and which exit codes besides 0 mean success.
+type gitCommand struct {
+	dir     string
+	env     []string
+	stdin   io.Reader
+	allowed []int
+}
+
+func (g gitCommand) run(ctx context.Context, args ...string) (string, error) {
+	var stdout bytes.Buffer
+	err := g.stream(ctx, func(r io.Reader) error {
+		_, err := stdout.ReadFrom(r)
+		return err
+	}, args...)
+	if err != nil {
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// stream runs git and hands its stdout to read while it runs, so large
+// output can be processed without holding all of it.
+func (g gitCommand) stream(ctx context.Context, read func(io.Reader) error, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = g.dir
+	if len(g.env) > 0 {
+		cmd.Env = append(os.Environ(), g.env...)
 	}
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
+	cmd.Stdin = g.stdin
+	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
-	err := cmd.Run()
-	if err == nil {
-		return stdout.String(), nil
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	readErr := read(stdout)
+	if readErr != nil {
+		// Let git finish instead of blocking on a full pipe.
+		_, _ = io.Copy(io.Discard, stdout)
+	}
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return fmt.Errorf("git %s: %w", strings.Join(args, " "), ctx.Err())
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && slices.Contains(g.allowed, exitErr.ExitCode()) {
+		err = nil
+	}
+	if err != nil {
+		errMsg := strings.TrimSpace(stderr.String())
+		if errMsg == "" {
+			errMsg = err.Error()
+		}
+		return fmt.Errorf("git %s failed: %s", strings.Join(args, " "), errMsg)
+	}
+	return readErr
+}
+
+// UnstagedFiles lists the files matching pathspecs whose work tree differs
+// from the index.
+func UnstagedFiles(root string, pathspecs []string) ([]string, error) {
+	args := append([]string{"-c", "core.quotePath=false", "diff", "--name-only", "-z", "--"}, pathspecs...)
+	out, err := runGit(root, args...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.FieldsFunc(out, func(r rune) bool { return r == 0 }), nil
+}
+
+func CurrentBranch(root string) (string, error) {
+	out, err := runGitAllowExitCodes(root, []int{0, 1}, "symbolic-ref", "--short", "-q", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// RecentCommits returns up to n commit subjects from HEAD, newest first.
+// A repository without commits yields no subjects rather than an error.
+func RecentCommits(root string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	out, err := runGitAllowExitCodes(root, []int{0, 128}, "log", "-n", strconv.Itoa(n), "--format=%s")
+	if err != nil {
+		return nil, err
+	}
+	var subjects []string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			subjects = append(subjects, line)
+		}
 	}
+	return subjects, nil
+}
 
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		for _, code := range allowed {
-			if exitErr.ExitCode() == code {
-				return stdout.String(), nil
-			}
+// Author returns the configured committer identity as "Name <email>", or
+// an empty string when user.name or user.email is unset.
+// CommentString returns core.commentString or core.commentChar, "#" when
+// neither is set. It may be "auto".
+func CommentString(root string) string {
+	for _, key := range []string{"core.commentString", "core.commentChar"} {
+		if out, err := runGitAllowExitCodes(root, []int{0, 1}, "config", key); err == nil && strings.TrimSpace(out) != "" {
+			return strings.TrimRight(out, "\n")
 		}
 	}
+	return "#"
+}
+
+func Author(root string) string {
+	name, err := runGitAllowExitCodes(root, []int{0, 1}, "config", "user.name")
+	if err != nil {
+		return ""
+	}
+	email, err := runGitAllowExitCodes(root, []int{0, 1}, "config", "user.email")
+	if err != nil {
+		return ""
+	}
+	name, email = strings.TrimSpace(name), strings.TrimSpace(email)
+	if name == "" || email == "" {
+		return ""
+	}
+	return name + " <" + email + ">"
+}
 
-	errMsg := strings.TrimSpace(stderr.String())
-	if errMsg == "" {
-		errMsg = err.Error()
+// InterpretTrailers adds trailers ("Key: value") to message using
+// `git interpret-trailers`, skipping ones already present.
+func InterpretTrailers(root, message string, trailers []string) (string, error) {
+	args := []string{"interpret-trailers", "--if-exists", "addIfDifferent"}
+	for _, trailer := range trailers {
+		args = append(args, "--trailer", trailer)
+	}
+	out, err := runGitInput(root, strings.TrimRight(message, "\n")+"\n", args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(out, "\n"), nil
+}
+
+func runGitInput(dir, input string, args ...string) (string, error) {
+	return gitCommand{dir: dir, stdin: strings.NewReader(input)}.run(context.Background(), args...)
+}
+
+// GitPath resolves name inside the repository's git directory, honouring
+// worktrees and GIT_DIR.
+func GitPath(root, name string) (string, error) {
+	out, err := runGit(root, "rev-parse", "--git-path", name)
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimSpace(out)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	return path, nil
+}
+
+// Trailer is one "Key: value" line of a message's trailer block.
+type Trailer struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ParseTrailers returns the trailers git recognises in message.
+func ParseTrailers(root, message string) ([]Trailer, error) {
+	out, err := runGitInput(root, strings.TrimRight(message, "\n")+"\n", "interpret-trailers", "--parse")
+	if err != nil {
+		return nil, err
+	}
+	var trailers []Trailer
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		trailers = append(trailers, Trailer{Key: strings.TrimSpace(key), Value: strings.TrimSpace(value)})
 	}
-	return "", fmt.Errorf("git %s failed: %s", strings.Join(args, " "), errMsg)
+	return trailers, nil
 }
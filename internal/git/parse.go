@@ -0,0 +1,273 @@
+package git
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FileStatus is how a file changed.
+type FileStatus int
+
+const (
+	StatusModified FileStatus = iota
+	StatusAdded
+	StatusDeleted
+	StatusRenamed
+	StatusCopied
+	// StatusTypeChanged is a path that changed between file, symlink and
+	// submodule.
+	StatusTypeChanged
+)
+
+func (s FileStatus) String() string {
+	switch s {
+	case StatusAdded:
+		return "added"
+	case StatusDeleted:
+		return "deleted"
+	case StatusRenamed:
+		return "renamed"
+	case StatusCopied:
+		return "copied"
+	case StatusTypeChanged:
+		return "type-changed"
+	default:
+		return "modified"
+	}
+}
+
+// FileDiff is one file's part of a unified diff as printed by git.
+type FileDiff struct {
+	// OldPath is empty for an added file, NewPath for a deleted one.
+	OldPath string
+	NewPath string
+	Status  FileStatus
+	// OldMode and NewMode are the file modes git reports, e.g. "100755",
+	// for a mode change or an added or deleted file.
+	OldMode string
+	NewMode string
+	// OldHash and NewHash are the abbreviated blob names of the "index"
+	// line; all zeros stand for a missing side.
+	OldHash string
+	NewHash string
+	// Similarity is the percentage of a rename or copy.
+	Similarity int
+	Binary     bool
+	// Truncated reports a gommit truncation marker in the file's hunks.
+	Truncated bool
+	Hunks     []Hunk
+	// Text is the file's part of the diff without the final newline.
+	Text string
+}
+
+// Hunk is one "@@" section of a file diff.
+type Hunk struct {
+	Header string
+	// Lines keep their " ", "+", "-" or "\" prefix.
+	Lines []string
+}
+
+// NullHash reports whether hash names no blob, as for the old side of an
+// added file.
+func NullHash(hash string) bool {
+	return strings.Trim(hash, "0") == ""
+}
+
+// Path returns the file's path after the change, or before it for a
+// deleted file.
+func (f FileDiff) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// ModeChanged reports whether an existing file's mode changed.
+func (f FileDiff) ModeChanged() bool {
+	return f.OldMode != "" && f.NewMode != "" && f.OldMode != f.NewMode
+}
+
+// truncatedPrefix starts the marker left by scanPatch in place of the
+// middle of a long file.
+const truncatedPrefix = "[gommit] diff truncated"
+
+// ParseDiff splits a diff printed by git diff or git show into files.
+// Paths are unquoted, and the extended header lines (rename, copy, mode,
+// new and deleted file) are preferred over the ambiguous "diff --git" line
+// when a path contains spaces. A type change, which git prints as the path
+// deleted and added again, is one file. Text before the first "diff --git"
+// line is kept as a file without paths.
+func ParseDiff(diff string) []FileDiff {
+	var files []FileDiff
+	var cur *diffParser
+	start := 0
+	finish := func(end int) {
+		if cur == nil {
+			return
+		}
+		f := cur.file
+		f.Text = strings.TrimSuffix(diff[start:end], "\n")
+		if n := len(files); n > 0 && isTypeChange(files[n-1], f) {
+			prev := &files[n-1]
+			prev.Status, prev.NewPath, prev.NewMode = StatusTypeChanged, f.NewPath, f.NewMode
+			prev.Binary = prev.Binary || f.Binary
+			prev.Truncated = prev.Truncated || f.Truncated
+			prev.Hunks = append(prev.Hunks, f.Hunks...)
+			prev.Text += "\n" + f.Text
+			return
+		}
+		files = append(files, f)
+	}
+	for pos := 0; pos < len(diff); {
+		end := strings.IndexByte(diff[pos:], '\n')
+		if end < 0 {
+			end = len(diff)
+		} else {
+			end += pos + 1
+		}
+		line := strings.TrimSuffix(diff[pos:end], "\n")
+		if cur == nil || (!cur.afterMarker && strings.HasPrefix(line, "diff --git ")) {
+			finish(pos)
+			cur = &diffParser{}
+			start = pos
+		}
+		cur.line(line)
+		pos = end
+	}
+	finish(len(diff))
+	return files
+}
+
+func isTypeChange(deleted, added FileDiff) bool {
+	return deleted.Status == StatusDeleted && added.Status == StatusAdded &&
+		deleted.OldPath != "" && deleted.OldPath == added.NewPath
+}
+
+// diffParser builds a FileDiff from its lines in order.
+type diffParser struct {
+	file   FileDiff
+	inHunk bool
+	// afterMarker is set after a truncation marker: the next line is the
+	// middle of a line and may look like anything.
+	afterMarker bool
+}
+
+func (p *diffParser) line(line string) {
+	f := &p.file
+	switch {
+	case p.afterMarker:
+		p.afterMarker = false
+		p.hunkLine(line)
+	case strings.HasPrefix(line, truncatedPrefix):
+		f.Truncated = true
+		p.afterMarker = true
+	case strings.HasPrefix(line, "@@"):
+		p.inHunk = true
+		f.Hunks = append(f.Hunks, Hunk{Header: line})
+	case p.inHunk:
+		p.hunkLine(line)
+	default:
+		p.header(line)
+	}
+}
+
+func (p *diffParser) hunkLine(line string) {
+	if n := len(p.file.Hunks); n > 0 {
+		p.file.Hunks[n-1].Lines = append(p.file.Hunks[n-1].Lines, line)
+	}
+}
+
+// header applies one line of the file header.
+func (p *diffParser) header(line string) {
+	f := &p.file
+	cut := func(prefix string) (string, bool) {
+		return strings.CutPrefix(line, prefix)
+	}
+	if rest, ok := cut("diff --git "); ok {
+		f.OldPath, f.NewPath = parseGitHeader(rest)
+	} else if rest, ok := cut("new file mode "); ok {
+		f.Status, f.NewMode, f.OldPath = StatusAdded, rest, ""
+	} else if rest, ok := cut("deleted file mode "); ok {
+		f.Status, f.OldMode, f.NewPath = StatusDeleted, rest, ""
+	} else if rest, ok := cut("old mode "); ok {
+		f.OldMode = rest
+	} else if rest, ok := cut("new mode "); ok {
+		f.NewMode = rest
+	} else if rest, ok := cut("rename from "); ok {
+		f.Status, f.OldPath = StatusRenamed, unquotePath(rest)
+	} else if rest, ok := cut("rename to "); ok {
+		f.Status, f.NewPath = StatusRenamed, unquotePath(rest)
+	} else if rest, ok := cut("copy from "); ok {
+		f.Status, f.OldPath = StatusCopied, unquotePath(rest)
+	} else if rest, ok := cut("copy to "); ok {
+		f.Status, f.NewPath = StatusCopied, unquotePath(rest)
+	} else if rest, ok := cut("index "); ok {
+		hashes, _, _ := strings.Cut(rest, " ")
+		f.OldHash, f.NewHash, _ = strings.Cut(hashes, "..")
+	} else if rest, ok := cut("similarity index "); ok {
+		f.Similarity, _ = strconv.Atoi(strings.TrimSuffix(rest, "%"))
+	} else if rest, ok := cut("--- "); ok {
+		f.OldPath = diffFilePath(rest, "a/")
+	} else if rest, ok := cut("+++ "); ok {
+		f.NewPath = diffFilePath(rest, "b/")
+	} else if strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch" {
+		f.Binary = true
+	}
+}
+
+// diffFilePath parses the path of a "---" or "+++" line. Git ends it with
+// a tab when the path contains a space.
+func diffFilePath(s, prefix string) string {
+	s = strings.TrimSuffix(s, "\t")
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(unquotePath(s), prefix)
+}
+
+// parseGitHeader returns the paths of a "diff --git" line. Unquoted paths
+// with spaces are only unambiguous when both sides are equal; for renames
+// and copies the extended header lines correct them afterwards.
+func parseGitHeader(s string) (string, string) {
+	var a, b string
+	if tok, rest, ok := cutQuoted(s); ok {
+		a, b = tok, unquotePath(strings.TrimPrefix(rest, " "))
+	} else if i := strings.LastIndex(s, ` "`); i >= 0 && strings.HasSuffix(s, `"`) {
+		a, b = s[:i], unquotePath(s[i+1:])
+	} else if n := (len(s) - 1) / 2; len(s)%2 == 1 && s[n] == ' ' && strings.TrimPrefix(s[:n], "a/") == strings.TrimPrefix(s[n+1:], "b/") {
+		a, b = s[:n], s[n+1:]
+	} else if i := strings.Index(s, " b/"); i >= 0 {
+		a, b = s[:i], s[i+1:]
+	} else {
+		a, b, _ = strings.Cut(s, " ")
+	}
+	return strings.TrimPrefix(a, "a/"), strings.TrimPrefix(b, "b/")
+}
+
+// cutQuoted splits a leading C-style quoted path, as git prints paths with
+// special characters, from the rest of s.
+func cutQuoted(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", s, false
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			tok, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", s, false
+			}
+			return tok, s[i+1:], true
+		}
+	}
+	return "", s, false
+}
+
+func unquotePath(s string) string {
+	if tok, rest, ok := cutQuoted(s); ok && rest == "" {
+		return tok
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want []FileDiff
+	}{
+		{
+			name: "quoted path",
+			diff: "diff --git \"a/qu\\\"ote.txt\" \"b/qu\\\"ote.txt\"\nindex 975fbec..ebf9bec 100644\n--- \"a/qu\\\"ote.txt\"\n+++ \"b/qu\\\"ote.txt\"\n@@ -1 +1,2 @@\n y\n+more\n",
+			want: []FileDiff{{
+				OldPath: `qu"ote.txt`, NewPath: `qu"ote.txt`, OldHash: "975fbec", NewHash: "ebf9bec",
+				Hunks: []Hunk{{Header: "@@ -1 +1,2 @@", Lines: []string{" y", "+more"}}},
+			}},
+		},
+		{
+			name: "rename with spaces",
+			diff: "diff --git a/sp ace.txt b/sp ace2.txt\nsimilarity index 100%\nrename from sp ace.txt\nrename to sp ace2.txt\n",
+			want: []FileDiff{{OldPath: "sp ace.txt", NewPath: "sp ace2.txt", Status: StatusRenamed, Similarity: 100}},
+		},
+		{
+			name: "escaped mode change",
+			diff: "diff --git \"a/\\303\\251.txt\" \"b/\\303\\251.txt\"\nold mode 100644\nnew mode 100755\n",
+			want: []FileDiff{{OldPath: "é.txt", NewPath: "é.txt", OldMode: "100644", NewMode: "100755"}},
+		},
+		{
+			name: "added and deleted",
+			diff: "diff --git a/sp ace.txt b/sp ace.txt\nnew file mode 100644\nindex 0000000..74b863c\n--- /dev/null\n+++ b/sp ace.txt\t\n@@ -0,0 +1 @@\n+x y\n" +
+				"diff --git a/logo.png b/logo.png\ndeleted file mode 100644\nBinary files a/logo.png and /dev/null differ\n",
+			want: []FileDiff{
+				{NewPath: "sp ace.txt", Status: StatusAdded, NewMode: "100644", OldHash: "0000000", NewHash: "74b863c", Hunks: []Hunk{{Header: "@@ -0,0 +1 @@", Lines: []string{"+x y"}}}},
+				{OldPath: "logo.png", Status: StatusDeleted, OldMode: "100644", Binary: true},
+			},
+		},
+		{
+			name: "type change",
+			diff: "diff --git a/f b/f\ndeleted file mode 100644\n--- a/f\n+++ /dev/null\n@@ -1 +0,0 @@\n-x\n" +
+				"diff --git a/f b/f\nnew file mode 120000\n--- /dev/null\n+++ b/f\n@@ -0,0 +1 @@\n+target\n",
+			want: []FileDiff{{
+				OldPath: "f", NewPath: "f", Status: StatusTypeChanged, OldMode: "100644", NewMode: "120000",
+				Hunks: []Hunk{{Header: "@@ -1 +0,0 @@", Lines: []string{"-x"}}, {Header: "@@ -0,0 +1 @@", Lines: []string{"+target"}}},
+			}},
+		},
+		{
+			name: "header text in content",
+			diff: "diff --git a/a.md b/a.md\n--- a/a.md\n+++ b/a.md\n@@ -1 +1 @@\n+diff --git a/x b/x\n+aaa\n[gommit] diff truncated for a.md: showing first 1 and last 1 chars of 3 total\ndiff --git a/y b/y\n",
+			want: []FileDiff{{
+				OldPath: "a.md", NewPath: "a.md", Truncated: true,
+				Hunks: []Hunk{{Header: "@@ -1 +1 @@", Lines: []string{"+diff --git a/x b/x", "+aaa", "diff --git a/y b/y"}}},
+			}},
+		},
+		{
+			name: "text before the first file",
+			diff: "stray\ndiff --git a/a b/a\n",
+			want: []FileDiff{{}, {OldPath: "a", NewPath: "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseDiff(tt.diff)
+			for i := range got {
+				got[i].Text = ""
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseDiff =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDiffText(t *testing.T) {
+	diff := "diff --git a/a b/a\n+x\ndiff --git a/b b/b\n+y\n"
+	files := ParseDiff(diff)
+	if len(files) != 2 || files[0].Text != "diff --git a/a b/a\n+x" || files[1].Text != "diff --git a/b b/b\n+y" {
+		t.Fatalf("files = %+v", files)
+	}
+	if files[1].Path() != "b" || (FileDiff{OldPath: "gone"}).Path() != "gone" {
+		t.Fatalf("Path() = %q", files[1].Path())
+	}
+}
@@ -0,0 +1,173 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// patch is the output of one git diff split into file chunks.
+type patch struct {
+	chunks    []string
+	binaries  []BinaryFile
+	truncated []string
+	// total is the length of the text chunks before truncation.
+	total int
+}
+
+// readPatch runs git and splits the patch it prints while reading it.
+// Binary files are listed with their size in root's working tree instead
+// of being kept as chunks; with root "" the size is unknown (-1).
+func readPatch(ctx context.Context, cmd gitCommand, root string, perFileLimit int, args ...string) (patch, error) {
+	var p patch
+	err := cmd.stream(ctx, func(r io.Reader) error {
+		var err error
+		p, err = scanPatch(r, root, perFileLimit)
+		return err
+	}, args...)
+	return p, err
+}
+
+// scanPatch splits a unified diff into file chunks, one per "diff --git"
+// line. Chunks longer than perFileLimit keep their first and last
+// perFileLimit/2 characters around a marker; the middle is dropped while
+// reading, so a huge file is never held in full.
+func scanPatch(r io.Reader, root string, perFileLimit int) (patch, error) {
+	var p patch
+	var cur *chunkBuffer
+	br := bufio.NewReaderSize(r, 64*1024)
+	lineStart := true
+	for {
+		line, err := br.ReadSlice('\n')
+		if len(line) > 0 {
+			if cur == nil || (lineStart && bytes.HasPrefix(line, []byte("diff --git "))) {
+				p.add(cur, root)
+				cur = &chunkBuffer{limit: perFileLimit}
+			}
+			cur.write(line)
+			lineStart = line[len(line)-1] == '\n'
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return patch{}, err
+		}
+	}
+	p.add(cur, root)
+	return p, nil
+}
+
+func (p *patch) add(c *chunkBuffer, root string) {
+	if c == nil {
+		return
+	}
+	f := c.file()
+	path := f.Path()
+	if f.Binary {
+		size := int64(-1)
+		if root != "" {
+			size = fileSize(filepath.Join(root, path))
+		}
+		bf := BinaryFile{Path: path, Size: size, Status: f.Status}
+		if f.OldPath != path {
+			bf.OldPath = f.OldPath
+		}
+		p.binaries = append(p.binaries, bf)
+		return
+	}
+	text, truncated := c.text(path)
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	p.total += c.n
+	p.chunks = append(p.chunks, text)
+	if truncated && path != "" {
+		p.truncated = append(p.truncated, path)
+	}
+}
+
+// chunkBuffer collects one file's part of a patch, keeping only the head
+// and tail when it exceeds limit.
+type chunkBuffer struct {
+	limit int
+	// header parses the lines before the first hunk; line holds the part
+	// of one read so far.
+	header diffParser
+	line   []byte
+	head   []byte
+	tail   []byte
+	// n counts every byte written, kept or not.
+	n int
+}
+
+func (c *chunkBuffer) write(b []byte) {
+	if !c.header.inHunk {
+		c.line = append(c.line, b...)
+		if b[len(b)-1] == '\n' {
+			c.flushLine()
+		}
+	}
+	c.n += len(b)
+	if c.limit <= 0 {
+		c.head = append(c.head, b...)
+		return
+	}
+	headLen, tailLen := c.split()
+	if room := headLen - len(c.head); room > 0 {
+		k := min(room, len(b))
+		c.head = append(c.head, b[:k]...)
+		b = b[k:]
+	}
+	c.tail = append(c.tail, b...)
+	// Keep one byte more than needed for the trailing newline text drops.
+	if keep := tailLen + 1; len(c.tail) > 2*keep+4096 {
+		c.tail = c.tail[:copy(c.tail, c.tail[len(c.tail)-keep:])]
+	}
+}
+
+func (c *chunkBuffer) flushLine() {
+	c.header.line(strings.TrimSuffix(string(c.line), "\n"))
+	c.line = c.line[:0]
+}
+
+// file returns the parsed header; hunks are not kept.
+func (c *chunkBuffer) file() FileDiff {
+	if len(c.line) > 0 {
+		c.flushLine()
+	}
+	return c.header.file
+}
+
+func (c *chunkBuffer) split() (head, tail int) {
+	head = c.limit / 2
+	return head, c.limit - head
+}
+
+// text returns the chunk without its final newline, truncated with a
+// marker naming path when it is longer than the limit.
+func (c *chunkBuffer) text(path string) (string, bool) {
+	if len(c.tail) > 0 {
+		if c.tail[len(c.tail)-1] == '\n' {
+			c.tail = c.tail[:len(c.tail)-1]
+			c.n--
+		}
+	} else if len(c.head) > 0 && c.head[len(c.head)-1] == '\n' {
+		c.head = c.head[:len(c.head)-1]
+		c.n--
+	}
+	if c.limit <= 0 || c.n <= c.limit {
+		return string(c.head) + string(c.tail), false
+	}
+	head, tail := c.split()
+	marker := fmt.Sprintf("\n[gommit] diff truncated for %s: showing first %d and last %d chars of %d total\n", path, head, tail, c.n)
+	return string(c.head) + marker + string(c.tail[len(c.tail)-tail:]), true
+}
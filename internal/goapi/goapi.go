@@ -0,0 +1,449 @@
+// Package goapi compares the exported API of Go packages, the requirements
+// of go.mod files and whether only tests changed.
+package goapi
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Actions of a Change or Dependency.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// File is a changed file's content before and after the change. Old or New
+// is nil when the file does not exist on that side.
+type File struct {
+	OldPath, NewPath string
+	Old, New         []byte
+}
+
+// Change is an exported identifier of a package that was added, removed or
+// changed.
+type Change struct {
+	Action string
+	// Package is the package's directory, or its name at the module root.
+	Package string
+	Kind    string
+	Name    string
+	// Old and New are the signatures before and after the change.
+	Old, New string
+	// Detail names the members of a changed struct or interface.
+	Detail string
+	// Breaking is set when callers of the old API may no longer compile.
+	Breaking bool
+}
+
+func (c Change) String() string {
+	s := c.Action + " " + c.Kind + " " + c.Package + "." + c.Name
+	switch {
+	case c.Detail != "":
+		s += ": " + c.Detail
+	case c.Action == Added && c.Kind != "struct" && c.Kind != "interface":
+		s += ": " + c.New
+	case c.Action == Changed:
+		s += ": " + c.Old + " -> " + c.New
+	}
+	if c.Breaking {
+		s += " (breaking)"
+	}
+	return s
+}
+
+// Dependency is a change to a go.mod requirement or go directive.
+type Dependency struct {
+	Action   string
+	Module   string
+	Old, New string
+	Indirect bool
+}
+
+func (d Dependency) String() string {
+	s := d.Action + " " + d.Module
+	switch d.Action {
+	case Added:
+		s += " " + d.New
+	case Removed:
+		s += " " + d.Old
+	default:
+		s += " " + d.Old + " -> " + d.New
+	}
+	if d.Indirect {
+		s += " (indirect)"
+	}
+	return s
+}
+
+// Report is the Go-specific summary of a change.
+type Report struct {
+	API          []Change
+	Dependencies []Dependency
+	// TestOnly is set when every changed file is a Go test or test data.
+	TestOnly bool
+}
+
+// Empty reports whether there is nothing to tell.
+func (r Report) Empty() bool {
+	return len(r.API) == 0 && len(r.Dependencies) == 0 && !r.TestOnly
+}
+
+// Breaking reports whether any API change may break callers.
+func (r Report) Breaking() bool {
+	return slices.ContainsFunc(r.API, func(c Change) bool { return c.Breaking })
+}
+
+// Wants reports whether Analyze looks into the content of file.
+func Wants(file string) bool {
+	return path.Base(file) == "go.mod" || (path.Ext(file) == ".go" && isPublic(file))
+}
+
+// Analyze compares files, as selected by Wants, and tells from all changed
+// paths whether only tests changed.
+func Analyze(paths []string, files []File) Report {
+	var r Report
+	r.TestOnly = len(paths) > 0 && !slices.ContainsFunc(paths, func(p string) bool { return !isTest(p) })
+	oldAPI, newAPI := newAPISet(), newAPISet()
+	for _, f := range files {
+		if path.Base(f.NewPath) == "go.mod" || path.Base(f.OldPath) == "go.mod" {
+			r.Dependencies = append(r.Dependencies, compareModules(f.Old, f.New)...)
+			continue
+		}
+		oldFile, oldErr := parseGo(f.OldPath, f.Old)
+		newFile, newErr := parseGo(f.NewPath, f.New)
+		if oldErr != nil || newErr != nil {
+			// A side that does not parse would make its declarations look
+			// removed or added; leave the file out instead.
+			continue
+		}
+		oldAPI.add(f.OldPath, oldFile)
+		newAPI.add(f.NewPath, newFile)
+	}
+	r.API = compareAPI(oldAPI, newAPI)
+	return r
+}
+
+// isPublic reports whether a Go file can hold API other modules use.
+func isPublic(file string) bool {
+	if strings.HasSuffix(file, "_test.go") {
+		return false
+	}
+	for _, elem := range strings.Split(path.Dir(file), "/") {
+		if elem == "internal" || elem == "testdata" || elem == "vendor" {
+			return false
+		}
+	}
+	return true
+}
+
+func isTest(file string) bool {
+	if strings.HasSuffix(file, "_test.go") {
+		return true
+	}
+	return slices.Contains(strings.Split(path.Dir(file), "/"), "testdata")
+}
+
+// decl is an exported declaration. Members are the exported fields of a
+// struct or the methods of an interface, by name.
+type decl struct {
+	kind, sig string
+	members   map[string]string
+}
+
+// apiSet holds the declarations of several files by package directory.
+type apiSet struct {
+	decls map[string]decl
+	// order keeps keys in the order they were found.
+	order []string
+	names map[string]string
+}
+
+func newAPISet() *apiSet {
+	return &apiSet{decls: map[string]decl{}, names: map[string]string{}}
+}
+
+// goFile is a parsed Go source file.
+type goFile struct {
+	fset *token.FileSet
+	file *ast.File
+}
+
+// parseGo parses src if it exists and Wants file, and returns nil otherwise.
+func parseGo(file string, src []byte) (*goFile, error) {
+	if src == nil || !Wants(file) {
+		return nil, nil
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	return &goFile{fset: fset, file: f}, nil
+}
+
+func (s *apiSet) add(file string, g *goFile) {
+	if g == nil || g.file.Name.Name == "main" {
+		return
+	}
+	f := g.file
+	dir := path.Dir(file)
+	s.names[dir] = f.Name.Name
+	put := func(name string, d decl) {
+		key := dir + "\x00" + name
+		if _, ok := s.decls[key]; !ok {
+			s.order = append(s.order, key)
+		}
+		s.decls[key] = d
+	}
+	p := &sigPrinter{fset: g.fset}
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				put(d.Name.Name, decl{kind: "func", sig: p.funcType(d.Type)})
+				continue
+			}
+			recv := receiverType(d.Recv.List[0].Type)
+			if ast.IsExported(recv) {
+				put(recv+"."+d.Name.Name, decl{kind: "method", sig: p.funcType(d.Type)})
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if spec.Name.IsExported() {
+						put(spec.Name.Name, p.typeSpec(spec))
+					}
+				case *ast.ValueSpec:
+					kind := strings.ToLower(d.Tok.String())
+					for _, name := range spec.Names {
+						if !name.IsExported() {
+							continue
+						}
+						sig := kind
+						if spec.Type != nil {
+							sig += " " + p.expr(spec.Type)
+						}
+						put(name.Name, decl{kind: kind, sig: sig})
+					}
+				}
+			}
+		}
+	}
+}
+
+func (s *apiSet) pkg(dir string) string {
+	if dir == "." {
+		return s.names[dir]
+	}
+	return dir
+}
+
+func compareAPI(old, new *apiSet) []Change {
+	var out []Change
+	for _, key := range new.order {
+		dir, name, _ := strings.Cut(key, "\x00")
+		n := new.decls[key]
+		o, ok := old.decls[key]
+		c := Change{Package: new.pkg(dir), Kind: n.kind, Name: name, Old: o.sig, New: n.sig}
+		switch {
+		case !ok:
+			c.Action = Added
+		case o.kind != n.kind || o.sig != n.sig:
+			c.Action, c.Breaking = Changed, true
+			if o.members != nil && n.members != nil && o.kind == n.kind {
+				c.Detail, c.Breaking = compareMembers(n.kind, o.members, n.members)
+			}
+		default:
+			continue
+		}
+		out = append(out, c)
+	}
+	for _, key := range old.order {
+		if _, ok := new.decls[key]; ok {
+			continue
+		}
+		dir, name, _ := strings.Cut(key, "\x00")
+		o := old.decls[key]
+		out = append(out, Change{Action: Removed, Package: old.pkg(dir), Kind: o.kind, Name: name, Old: o.sig, Breaking: true})
+	}
+	return out
+}
+
+// compareMembers describes how the fields of a struct or the methods of an
+// interface changed. New struct fields keep callers compiling; any change
+// to an interface breaks its implementations.
+func compareMembers(kind string, old, new map[string]string) (string, bool) {
+	var added, removed, changed []string
+	for name, sig := range new {
+		if o, ok := old[name]; !ok {
+			added = append(added, name)
+		} else if o != sig {
+			changed = append(changed, name)
+		}
+	}
+	for name := range old {
+		if _, ok := new[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	noun := "fields"
+	if kind == "interface" {
+		noun = "methods"
+	}
+	var parts []string
+	for _, group := range []struct {
+		action string
+		names  []string
+	}{{Added, added}, {Removed, removed}, {Changed, changed}} {
+		if len(group.names) > 0 {
+			slices.Sort(group.names)
+			parts = append(parts, group.action+" "+noun+" "+strings.Join(group.names, ", "))
+		}
+	}
+	if len(parts) == 0 {
+		// Only unexported members or the layout changed.
+		parts = append(parts, "changed "+noun)
+	}
+	breaking := len(removed) > 0 || len(changed) > 0 || (kind == "interface" && len(added) > 0)
+	return strings.Join(parts, "; "), breaking
+}
+
+// sigPrinter prints declarations without parameter names, bodies and
+// comments, on one line.
+type sigPrinter struct {
+	fset *token.FileSet
+}
+
+func (p *sigPrinter) expr(e ast.Expr) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, p.fset, e)
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+func (p *sigPrinter) funcType(ft *ast.FuncType) string {
+	s := "func"
+	if ft.TypeParams != nil {
+		s += "[" + p.fields(ft.TypeParams, true) + "]"
+	}
+	s += "(" + p.fields(ft.Params, false) + ")"
+	if ft.Results != nil && len(ft.Results.List) > 0 {
+		results := p.fields(ft.Results, false)
+		if len(ft.Results.List) == 1 && len(ft.Results.List[0].Names) <= 1 {
+			s += " " + results
+		} else {
+			s += " (" + results + ")"
+		}
+	}
+	return s
+}
+
+// fields lists the types of a field list, once per name; with names the
+// names are kept, as for type parameters.
+func (p *sigPrinter) fields(fl *ast.FieldList, names bool) string {
+	if fl == nil {
+		return ""
+	}
+	var out []string
+	for _, f := range fl.List {
+		typ := p.expr(f.Type)
+		if names && len(f.Names) > 0 {
+			var ns []string
+			for _, n := range f.Names {
+				ns = append(ns, n.Name)
+			}
+			out = append(out, strings.Join(ns, ", ")+" "+typ)
+			continue
+		}
+		for range max(1, len(f.Names)) {
+			out = append(out, typ)
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+func (p *sigPrinter) typeSpec(spec *ast.TypeSpec) decl {
+	prefix := "type"
+	if spec.TypeParams != nil {
+		prefix += "[" + p.fields(spec.TypeParams, true) + "]"
+	}
+	if spec.Assign.IsValid() {
+		return decl{kind: "type", sig: prefix + " = " + p.expr(spec.Type)}
+	}
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		members := map[string]string{}
+		for _, f := range t.Fields.List {
+			typ := p.expr(f.Type)
+			if f.Tag != nil {
+				typ += " " + f.Tag.Value
+			}
+			if len(f.Names) == 0 {
+				if name := receiverType(f.Type); ast.IsExported(name) {
+					members[name] = typ
+				}
+			}
+			for _, n := range f.Names {
+				if n.IsExported() {
+					members[n.Name] = typ
+				}
+			}
+		}
+		return decl{kind: "struct", sig: prefix + " struct" + memberSig(members), members: members}
+	case *ast.InterfaceType:
+		members := map[string]string{}
+		for _, f := range t.Methods.List {
+			if len(f.Names) == 0 {
+				// Embedded interfaces and type constraints.
+				members[p.expr(f.Type)] = ""
+			}
+			for _, n := range f.Names {
+				if ft, ok := f.Type.(*ast.FuncType); ok {
+					members[n.Name] = p.funcType(ft)
+				}
+			}
+		}
+		return decl{kind: "interface", sig: prefix + " interface" + memberSig(members), members: members}
+	}
+	return decl{kind: "type", sig: prefix + " " + p.expr(spec.Type)}
+}
+
+func memberSig(members map[string]string) string {
+	var names []string
+	for name := range members {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var out []string
+	for _, name := range names {
+		out = append(out, strings.TrimSpace(name+" "+members[name]))
+	}
+	return "{" + strings.Join(out, "; ") + "}"
+}
+
+func receiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(t.X)
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
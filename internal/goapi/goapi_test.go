@@ -0,0 +1,145 @@
+package goapi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeAPI(t *testing.T) {
+	oldSrc := `package api
+
+type Options struct {
+	Name string
+	Size int
+	priv bool
+}
+
+type Store interface {
+	Get(key string) (string, error)
+}
+
+func New(o Options) *Client { return nil }
+
+func Old() {}
+
+type Client struct{}
+
+func (c *Client) Do(ctx string) error { return nil }
+`
+	newSrc := `package api
+
+type Options struct {
+	Name  string
+	Size  int
+	Extra bool
+}
+
+type Store interface {
+	Get(key string) (string, error)
+	Put(key, value string) error
+}
+
+// New has a renamed parameter.
+func New(opts Options) *Client { return nil }
+
+type Client struct{}
+
+func (cl *Client) Do(ctx string, n int) error { return nil }
+
+func Fresh[T any](v T) T { return v }
+`
+	tests := []struct {
+		name  string
+		files []File
+		want  []string
+	}{
+		{
+			name:  "changes",
+			files: []File{{OldPath: "api/api.go", NewPath: "api/api.go", Old: []byte(oldSrc), New: []byte(newSrc)}},
+			want: []string{
+				"changed struct api.Options: added fields Extra",
+				"changed interface api.Store: added methods Put (breaking)",
+				"changed method api.Client.Do: func(string) error -> func(string, int) error (breaking)",
+				"added func api.Fresh: func[T any](T) T",
+				"removed func api.Old (breaking)",
+			},
+		},
+		{
+			name: "moved within the package",
+			files: []File{
+				{OldPath: "a.go", NewPath: "a.go", Old: []byte("package m\n\nfunc A() {}\n"), New: []byte("package m\n")},
+				{NewPath: "b.go", New: []byte("package m\n\nfunc A() {}\n")},
+			},
+		},
+		{
+			name: "root package",
+			files: []File{
+				{OldPath: "m.go", NewPath: "m.go", Old: []byte("package m\n\nconst Limit = 1\n"), New: []byte("package m\n\nvar Limit int64 = 1\n")},
+			},
+			want: []string{"changed var m.Limit: const -> var int64 (breaking)"},
+		},
+		{
+			name: "not public",
+			files: []File{
+				{OldPath: "internal/x/x.go", NewPath: "internal/x/x.go", Old: []byte("package x\n\nfunc A() {}\n"), New: []byte("package x\n")},
+				{OldPath: "cmd/tool/main.go", NewPath: "cmd/tool/main.go", Old: []byte("package main\n\nfunc A() {}\n"), New: []byte("package main\n")},
+				{OldPath: "a_test.go", NewPath: "a_test.go", Old: []byte("package m\n\nfunc A() {}\n"), New: []byte("package m\n")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range Analyze(nil, tt.files).API {
+				got = append(got, c.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("API =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeSkipsFilesThatDoNotParse(t *testing.T) {
+	oldSrc := "package api\n\nfunc A() {}\n\nfunc B() {}\n"
+	newSrc := "package api\n\nfunc A() {}\n\nfunc B() {\n"
+	r := Analyze([]string{"api.go"}, []File{{OldPath: "api.go", NewPath: "api.go", Old: []byte(oldSrc), New: []byte(newSrc)}})
+	if len(r.API) != 0 || r.Breaking() {
+		t.Fatalf("API = %v, want no changes", r.API)
+	}
+}
+
+func TestAnalyzeDependencies(t *testing.T) {
+	oldMod := "module example.com/m\n\ngo 1.22\n\nrequire (\n\tgithub.com/a/b v1.0.0\n\tgithub.com/c/d v0.1.0 // indirect\n)\n"
+	newMod := "module example.com/m\n\ngo 1.23\n\nrequire github.com/a/b v1.2.0\n\nrequire github.com/e/f v0.3.0 // indirect\n"
+	r := Analyze(nil, []File{{OldPath: "go.mod", NewPath: "go.mod", Old: []byte(oldMod), New: []byte(newMod)}})
+	var got []string
+	for _, d := range r.Dependencies {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"changed go 1.22 -> 1.23",
+		"changed github.com/a/b v1.0.0 -> v1.2.0",
+		"added github.com/e/f v0.3.0 (indirect)",
+		"removed github.com/c/d v0.1.0 (indirect)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Dependencies =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestAnalyzeTestOnly(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  bool
+	}{
+		{[]string{"a_test.go", "pkg/testdata/in.txt"}, true},
+		{[]string{"a_test.go", "a.go"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := Analyze(tt.paths, nil).TestOnly; got != tt.want {
+			t.Errorf("TestOnly(%q) = %v, want %v", tt.paths, got, tt.want)
+		}
+	}
+}
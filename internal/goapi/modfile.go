@@ -0,0 +1,83 @@
+package goapi
+
+import (
+	"strings"
+)
+
+// requirement is a module version required by go.mod.
+type requirement struct {
+	version  string
+	indirect bool
+}
+
+// parseModule reads the go directive and the requirements of a go.mod
+// file. Replace and exclude directives are ignored.
+func parseModule(src []byte) (string, map[string]requirement, []string) {
+	goVersion := ""
+	reqs := map[string]requirement{}
+	var order []string
+	inRequire := false
+	for _, line := range strings.Split(string(src), "\n") {
+		comment := ""
+		if i := strings.Index(line, "//"); i >= 0 {
+			line, comment = line[:i], line[i+2:]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case inRequire:
+			if fields[0] == ")" {
+				inRequire = false
+				continue
+			}
+		case fields[0] == "go" && len(fields) == 2:
+			goVersion = fields[1]
+			continue
+		case fields[0] == "require" && len(fields) == 2 && fields[1] == "(":
+			inRequire = true
+			continue
+		case fields[0] == "require":
+			fields = fields[1:]
+		default:
+			continue
+		}
+		if len(fields) != 2 {
+			continue
+		}
+		if _, ok := reqs[fields[0]]; !ok {
+			order = append(order, fields[0])
+		}
+		reqs[fields[0]] = requirement{version: fields[1], indirect: strings.TrimSpace(comment) == "indirect"}
+	}
+	return goVersion, reqs, order
+}
+
+// compareModules lists the changed requirements and go directive of two
+// versions of a go.mod file.
+func compareModules(old, new []byte) []Dependency {
+	oldGo, oldReqs, oldOrder := parseModule(old)
+	newGo, newReqs, newOrder := parseModule(new)
+	var out []Dependency
+	if oldGo != newGo && oldGo != "" && newGo != "" {
+		out = append(out, Dependency{Action: Changed, Module: "go", Old: oldGo, New: newGo})
+	}
+	for _, mod := range newOrder {
+		n := newReqs[mod]
+		o, ok := oldReqs[mod]
+		switch {
+		case !ok:
+			out = append(out, Dependency{Action: Added, Module: mod, New: n.version, Indirect: n.indirect})
+		case o.version != n.version:
+			out = append(out, Dependency{Action: Changed, Module: mod, Old: o.version, New: n.version, Indirect: n.indirect})
+		}
+	}
+	for _, mod := range oldOrder {
+		if _, ok := newReqs[mod]; !ok {
+			o := oldReqs[mod]
+			out = append(out, Dependency{Action: Removed, Module: mod, Old: o.version, Indirect: o.indirect})
+		}
+	}
+	return out
+}
@@ -0,0 +1,168 @@
+// Package history keeps every generated or edited commit message so it can
+// be recovered after a failed commit.
+package history
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MaxEntries is the number of entries kept across all repositories.
+const MaxEntries = 1000
+
+// Sources of a recorded message.
+const (
+	SourceGenerated = "generated"
+	SourceEdited    = "edited"
+)
+
+// Entry is one recorded message. Repo is the repository root and DiffHash
+// identifies the changes the message was written for.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Repo     string    `json:"repo"`
+	DiffHash string    `json:"diff_hash"`
+	Source   string    `json:"source"`
+	Message  string    `json:"message"`
+}
+
+// Subject returns the first line of the message.
+func (e Entry) Subject() string {
+	subject, _, _ := strings.Cut(e.Message, "\n")
+	return subject
+}
+
+// Store is an append-only JSON lines file of entries.
+type Store struct {
+	path string
+	max  int
+}
+
+// Open returns the store kept in dir. The directory is created on the first
+// write.
+func Open(dir string) *Store {
+	return &Store{path: filepath.Join(dir, "history.jsonl"), max: MaxEntries}
+}
+
+// HashDiff returns the key used to match messages to a diff.
+func HashDiff(diff string) string {
+	sum := sha256.Sum256([]byte(diff))
+	return hex.EncodeToString(sum[:])
+}
+
+// Add records e, dropping the oldest entries beyond MaxEntries.
+func (s *Store) Add(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	entries, err := s.read()
+	if err != nil {
+		return err
+	}
+	if len(entries) >= s.max {
+		entries = append(entries, e)
+		return s.write(entries[len(entries)-s.max:])
+	}
+	return s.append(e)
+}
+
+// List returns the entries for repo, newest first. An empty repo lists
+// every entry.
+func (s *Store) List(repo string) ([]Entry, error) {
+	entries, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	var out []Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if repo == "" || entries[i].Repo == repo {
+			out = append(out, entries[i])
+		}
+	}
+	return out, nil
+}
+
+// Last returns the newest entry for repo, preferring one recorded for
+// diffHash. ok is false when the repository has no history.
+func (s *Store) Last(repo, diffHash string) (e Entry, exact bool, ok bool, err error) {
+	entries, err := s.List(repo)
+	if err != nil || len(entries) == 0 {
+		return Entry{}, false, false, err
+	}
+	if diffHash != "" {
+		for _, entry := range entries {
+			if entry.DiffHash == diffHash {
+				return entry, true, true, nil
+			}
+		}
+	}
+	return entries[0], false, true, nil
+}
+
+func (s *Store) read() ([]Entry, error) {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		// Skip lines that cannot be decoded rather than losing the rest.
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+func (s *Store) append(e Entry) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(e); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *Store) write(entries []Entry) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "history-*.jsonl")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	enc := json.NewEncoder(tmp)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
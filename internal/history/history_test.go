@@ -0,0 +1,78 @@
+package history
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	store := Open(t.TempDir())
+
+	if _, _, ok, err := store.Last("/repo", ""); err != nil || ok {
+		t.Fatalf("Last on empty store = ok %t, err %v", ok, err)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: base, Repo: "/repo", DiffHash: "a", Source: SourceGenerated, Message: "feat: first"},
+		{Time: base.Add(time.Minute), Repo: "/other", DiffHash: "b", Source: SourceGenerated, Message: "fix: other"},
+		{Time: base.Add(2 * time.Minute), Repo: "/repo", DiffHash: "c", Source: SourceEdited, Message: "feat: second\n\nbody"},
+	}
+	for _, e := range entries {
+		if err := store.Add(e); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	list, err := store.List("/repo")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 || list[0].Subject() != "feat: second" || list[1].Subject() != "feat: first" {
+		t.Fatalf("List = %+v", list)
+	}
+	all, err := store.List("")
+	if err != nil || len(all) != 3 {
+		t.Fatalf("List(\"\") = %d entries, err %v", len(all), err)
+	}
+
+	tests := []struct {
+		name      string
+		diffHash  string
+		want      string
+		wantExact bool
+	}{
+		{name: "matching diff", diffHash: "a", want: "feat: first", wantExact: true},
+		{name: "unknown diff falls back to newest", diffHash: "z", want: "feat: second"},
+		{name: "no diff", want: "feat: second"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, exact, ok, err := store.Last("/repo", tt.diffHash)
+			if err != nil || !ok {
+				t.Fatalf("Last: ok %t, err %v", ok, err)
+			}
+			if e.Subject() != tt.want || exact != tt.wantExact {
+				t.Fatalf("Last = %q (exact %t), want %q (exact %t)", e.Subject(), exact, tt.want, tt.wantExact)
+			}
+		})
+	}
+}
+
+func TestStoreTrims(t *testing.T) {
+	store := Open(t.TempDir())
+	store.max = 3
+	for i := 0; i < 5; i++ {
+		if err := store.Add(Entry{Repo: "/repo", Message: fmt.Sprintf("msg %d", i)}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	list, err := store.List("/repo")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 3 || list[0].Message != "msg 4" || list[2].Message != "msg 2" {
+		t.Fatalf("List = %+v, want the newest 3 entries", list)
+	}
+}
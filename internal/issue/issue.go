@@ -0,0 +1,132 @@
+// Package issue extracts issue keys from branch names and adds them to
+// commit messages.
+package issue
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// Placements for the rendered issue reference.
+const (
+	PlacementPrefix  = "prefix"
+	PlacementFooter  = "footer"
+	PlacementTrailer = "trailer"
+)
+
+// Placements lists the valid placements.
+var Placements = []string{PlacementPrefix, PlacementFooter, PlacementTrailer}
+
+// CheckPlacement reports an error for a placement other than "" (the
+// default) or one of Placements.
+func CheckPlacement(placement string) error {
+	if placement != "" && !slices.Contains(Placements, placement) {
+		return fmt.Errorf("unknown issue placement %q (valid: %s)", placement, strings.Join(Placements, ", "))
+	}
+	return nil
+}
+
+// DefaultTemplate returns the template used for placement when none is
+// configured.
+func DefaultTemplate(placement string) string {
+	if placement == PlacementPrefix {
+		return "{{.Keys}}: "
+	}
+	return "Refs: {{.Keys}}"
+}
+
+// Keys returns the unique issue keys pattern finds in branch, in order of
+// appearance. When pattern has a capture group, the first group is the key.
+func Keys(branch, pattern string) ([]string, error) {
+	if pattern == "" || branch == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issue pattern: %w", err)
+	}
+	seen := map[string]struct{}{}
+	var keys []string
+	for _, m := range re.FindAllStringSubmatch(branch, -1) {
+		key := m[0]
+		if len(m) > 1 && m[1] != "" {
+			key = m[1]
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Render executes tmpl with .Key (the first key) and .Keys (all keys,
+// comma-separated).
+func Render(tmpl string, keys []string) (string, error) {
+	t, err := template.New("issue").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid issue template: %w", err)
+	}
+	data := struct {
+		Key  string
+		Keys string
+	}{Keys: strings.Join(keys, ", ")}
+	if len(keys) > 0 {
+		data.Key = keys[0]
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering issue template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Mentioned reports whether message already references every key as a
+// whole word, so PROJ-12 does not count as a mention of PROJ-1.
+func Mentioned(message string, keys []string) bool {
+	for _, key := range keys {
+		re := regexp.MustCompile(`(^|[^\w])` + regexp.QuoteMeta(key) + `($|[^\w])`)
+		if !re.MatchString(message) {
+			return false
+		}
+	}
+	return true
+}
+
+// AddPrefix puts prefix in front of the subject line, after its first at
+// bytes, such as a "feat(api): " header the style requires to come first.
+func AddPrefix(message, prefix string, at int) string {
+	if strings.HasPrefix(message[at:], prefix) {
+		return message
+	}
+	return message[:at] + prefix + message[at:]
+}
+
+// AddFooter appends footer as the last paragraph of message. If the last
+// paragraph is already a trailer block, footer joins it.
+func AddFooter(message, footer string) string {
+	message = strings.TrimRight(message, "\n")
+	footer = strings.TrimSpace(footer)
+	paragraphs := strings.Split(message, "\n\n")
+	last := paragraphs[len(paragraphs)-1]
+	if len(paragraphs) > 1 && isTrailerBlock(last) {
+		return message + "\n" + footer
+	}
+	return message + "\n\n" + footer
+}
+
+var trailerLine = regexp.MustCompile(`^[A-Za-z0-9-]+: \S`)
+
+func isTrailerBlock(paragraph string) bool {
+	for _, line := range strings.Split(paragraph, "\n") {
+		if !trailerLine.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package issue
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeys(t *testing.T) {
+	tests := []struct {
+		branch  string
+		pattern string
+		want    []string
+	}{
+		{"feature/PROJ-1234-new-login", `[A-Z][A-Z0-9]+-\d+`, []string{"PROJ-1234"}},
+		{"fix/PROJ-1-and-OPS-22", `[A-Z][A-Z0-9]+-\d+`, []string{"PROJ-1", "OPS-22"}},
+		{"issue-42-crash", `issue-(\d+)`, []string{"42"}},
+		{"main", `[A-Z][A-Z0-9]+-\d+`, nil},
+		{"feature/PROJ-1", "", nil},
+	}
+	for _, tt := range tests {
+		got, err := Keys(tt.branch, tt.pattern)
+		if err != nil {
+			t.Fatalf("Keys(%q): %v", tt.branch, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Keys(%q, %q) = %v, want %v", tt.branch, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	got, err := Render("[{{.Key}}] ", []string{"PROJ-1", "OPS-2"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if got != "[PROJ-1] " {
+		t.Fatalf("Render() = %q", got)
+	}
+	got, _ = Render(DefaultTemplate(PlacementFooter), []string{"PROJ-1", "OPS-2"})
+	if got != "Refs: PROJ-1, OPS-2" {
+		t.Fatalf("Render() = %q", got)
+	}
+}
+
+func TestMentioned(t *testing.T) {
+	tests := []struct {
+		message string
+		keys    []string
+		want    bool
+	}{
+		{"PROJ-1: add login", []string{"PROJ-1"}, true},
+		{"fix: crash\n\nRefs: PROJ-1, OPS-2", []string{"PROJ-1", "OPS-2"}, true},
+		{"fix: crash (PROJ-12)", []string{"PROJ-1"}, false},
+		{"fix: crash in XPROJ-1", []string{"PROJ-1"}, false},
+		{"fix: crash #42", []string{"42"}, true},
+		{"fix: crash #421", []string{"42"}, false},
+	}
+	for _, tt := range tests {
+		if got := Mentioned(tt.message, tt.keys); got != tt.want {
+			t.Errorf("Mentioned(%q, %q) = %v, want %v", tt.message, tt.keys, got, tt.want)
+		}
+	}
+}
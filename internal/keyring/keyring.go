@@ -0,0 +1,201 @@
+// Package keyring stores and retrieves secrets through the freedesktop
+// Secret Service API (GNOME Keyring, KWallet, KeePassXC) over D-Bus.
+package keyring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	serviceName       = "org.freedesktop.secrets"
+	servicePath       = "/org/freedesktop/secrets"
+	defaultCollection = "/org/freedesktop/secrets/aliases/default"
+
+	serviceIface    = "org.freedesktop.Secret.Service"
+	collectionIface = "org.freedesktop.Secret.Collection"
+	promptIface     = "org.freedesktop.Secret.Prompt"
+
+	// promptTimeout bounds the wait for an unlock dialog, which may never
+	// show up in a headless session.
+	promptTimeout = 2 * time.Minute
+)
+
+var (
+	// ErrNotFound is returned when no secret matches the lookup attributes.
+	ErrNotFound = errors.New("secret not found in keyring")
+	// ErrUnavailable is returned when no Secret Service is reachable.
+	ErrUnavailable = errors.New("secret service unavailable")
+)
+
+type secret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// Get returns the secret stored for service and user. Cancelling ctx
+// dismisses an unlock prompt.
+func Get(ctx context.Context, service, user string) (string, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	svc := conn.Object(serviceName, servicePath)
+	session, err := openSession(svc)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Object(serviceName, session).Call("org.freedesktop.Secret.Session.Close", 0)
+
+	var unlocked, locked []dbus.ObjectPath
+	if err := svc.Call(serviceIface+".SearchItems", 0, attributes(service, user)).Store(&unlocked, &locked); err != nil {
+		return "", fmt.Errorf("keyring search failed: %w", err)
+	}
+	if len(unlocked) == 0 && len(locked) > 0 {
+		if err := unlock(ctx, conn, svc, locked[:1]); err != nil {
+			return "", err
+		}
+		unlocked = locked[:1]
+	}
+	if len(unlocked) == 0 {
+		return "", ErrNotFound
+	}
+
+	var secrets map[dbus.ObjectPath]secret
+	if err := svc.Call(serviceIface+".GetSecrets", 0, unlocked[:1], session).Store(&secrets); err != nil {
+		return "", fmt.Errorf("keyring read failed: %w", err)
+	}
+	s, ok := secrets[unlocked[0]]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return string(s.Value), nil
+}
+
+// Set stores value for service and user in the default collection,
+// replacing any existing secret with the same attributes. Cancelling ctx
+// dismisses an unlock prompt.
+func Set(ctx context.Context, service, user, label, value string) error {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	svc := conn.Object(serviceName, servicePath)
+	session, err := openSession(svc)
+	if err != nil {
+		return err
+	}
+	defer conn.Object(serviceName, session).Call("org.freedesktop.Secret.Session.Close", 0)
+
+	if err := unlock(ctx, conn, svc, []dbus.ObjectPath{defaultCollection}); err != nil {
+		return err
+	}
+
+	props := map[string]dbus.Variant{
+		"org.freedesktop.Secret.Item.Label":      dbus.MakeVariant(label),
+		"org.freedesktop.Secret.Item.Attributes": dbus.MakeVariant(attributes(service, user)),
+	}
+	s := secret{Session: session, Value: []byte(value), ContentType: "text/plain"}
+	var item, prompt dbus.ObjectPath
+	collection := conn.Object(serviceName, defaultCollection)
+	if err := collection.Call(collectionIface+".CreateItem", 0, props, s, true).Store(&item, &prompt); err != nil {
+		return fmt.Errorf("keyring write failed: %w", err)
+	}
+	if prompt != "/" {
+		if err := runPrompt(ctx, conn, prompt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func attributes(service, user string) map[string]string {
+	return map[string]string{"service": service, "username": user}
+}
+
+func openSession(svc dbus.BusObject) (dbus.ObjectPath, error) {
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	if err := svc.Call(serviceIface+".OpenSession", 0, "plain", dbus.MakeVariant("")).Store(&output, &session); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return session, nil
+}
+
+func unlock(ctx context.Context, conn *dbus.Conn, svc dbus.BusObject, objects []dbus.ObjectPath) error {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	if err := svc.Call(serviceIface+".Unlock", 0, objects).Store(&unlocked, &prompt); err != nil {
+		return fmt.Errorf("keyring unlock failed: %w", err)
+	}
+	if prompt == "/" {
+		return nil
+	}
+	return runPrompt(ctx, conn, prompt)
+}
+
+func runPrompt(ctx context.Context, conn *dbus.Conn, prompt dbus.ObjectPath) error {
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(prompt),
+		dbus.WithMatchInterface(promptIface),
+		dbus.WithMatchMember("Completed"),
+	}
+	if err := conn.AddMatchSignal(match...); err != nil {
+		return fmt.Errorf("keyring prompt failed: %w", err)
+	}
+	defer conn.RemoveMatchSignal(match...)
+	signals := make(chan *dbus.Signal, 1)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	if err := conn.Object(serviceName, prompt).Call(promptIface+".Prompt", 0, "").Err; err != nil {
+		return fmt.Errorf("keyring prompt failed: %w", err)
+	}
+	err := waitPrompt(ctx, signals, prompt, promptTimeout)
+	dismiss := func() { conn.Object(serviceName, prompt).Call(promptIface+".Dismiss", 0) }
+	switch {
+	case errors.Is(err, errPromptOpen):
+		dismiss()
+		return fmt.Errorf("%w: keyring prompt timed out after %s", ErrUnavailable, promptTimeout)
+	case err != nil && ctx.Err() != nil:
+		dismiss()
+	}
+	return err
+}
+
+// errPromptOpen is returned by waitPrompt when the prompt is still open
+// after the timeout.
+var errPromptOpen = errors.New("keyring prompt still open")
+
+// waitPrompt waits for the Completed signal of prompt, ctx or timeout.
+func waitPrompt(ctx context.Context, signals <-chan *dbus.Signal, prompt dbus.ObjectPath, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case sig, ok := <-signals:
+			if !ok {
+				return errors.New("keyring prompt failed: connection closed")
+			}
+			if sig.Path != prompt || sig.Name != promptIface+".Completed" {
+				continue
+			}
+			if len(sig.Body) > 0 {
+				if dismissed, ok := sig.Body[0].(bool); ok && dismissed {
+					return fmt.Errorf("%w: keyring prompt dismissed", ErrUnavailable)
+				}
+			}
+			return nil
+		case <-timer.C:
+			return errPromptOpen
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package keyring
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestWaitPrompt(t *testing.T) {
+	const prompt = dbus.ObjectPath("/org/freedesktop/secrets/prompt/p1")
+	completed := func(path dbus.ObjectPath, dismissed bool) *dbus.Signal {
+		return &dbus.Signal{Path: path, Name: promptIface + ".Completed", Body: []any{dismissed, dbus.MakeVariant("")}}
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		signals []*dbus.Signal
+		want    error
+	}{
+		{"completed", context.Background(), []*dbus.Signal{completed("/other", true), completed(prompt, false)}, nil},
+		{"dismissed", context.Background(), []*dbus.Signal{completed(prompt, true)}, ErrUnavailable},
+		{"timed out", context.Background(), nil, errPromptOpen},
+		{"cancelled", cancelled, nil, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signals := make(chan *dbus.Signal, len(tt.signals))
+			for _, sig := range tt.signals {
+				signals <- sig
+			}
+			err := waitPrompt(tt.ctx, signals, prompt, 10*time.Millisecond)
+			if (tt.want == nil) != (err == nil) || (tt.want != nil && !errors.Is(err, tt.want)) {
+				t.Fatalf("waitPrompt = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetWithoutSessionBus(t *testing.T) {
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path=/nonexistent/bus")
+	if _, err := Get(context.Background(), "gommit", "openai"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Get = %v, want ErrUnavailable", err)
+	}
+}
@@ -0,0 +1,246 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/MenschMachine/gommit/internal/prompt"
+)
+
+const scissors = " ------------------------ >8 ------------------------"
+
+// autoCommentChars are the characters git picks the comment character from
+// for core.commentChar=auto, in order.
+const autoCommentChars = "#;@!$%^&|:"
+
+// Result holds the violations found in one commit message. Exactly one of
+// Commit and File identifies where the message came from.
+type Result struct {
+	Commit     string             `json:"commit,omitempty"`
+	File       string             `json:"file,omitempty"`
+	Subject    string             `json:"subject"`
+	Skipped    bool               `json:"skipped,omitempty"`
+	Violations []prompt.Violation `json:"violations"`
+	Suggestion string             `json:"suggestion,omitempty"`
+}
+
+func (r Result) location() string {
+	if r.Commit != "" {
+		return shortHash(r.Commit)
+	}
+	return r.File
+}
+
+// CleanMessage strips what git itself would remove from a commit message
+// file before committing: lines starting with comment, as set by
+// core.commentChar, and everything below the scissors line written by
+// `git commit -v`.
+func CleanMessage(raw, comment string) string {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	if comment == "auto" {
+		comment = autoComment(raw)
+	}
+	if comment == "" {
+		return strings.TrimSpace(raw)
+	}
+	if strings.HasPrefix(raw, comment+scissors) {
+		raw = ""
+	} else if i := strings.Index(raw, "\n"+comment+scissors); i >= 0 {
+		raw = raw[:i]
+	}
+	var kept []string
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.HasPrefix(line, comment) {
+			continue
+		}
+		kept = append(kept, strings.TrimRight(line, " \t"))
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+// autoComment returns the comment character git chose for
+// core.commentChar=auto: the one starting its scissors line or, as git
+// appends its comments, the last line. It is "" when there are no comments.
+func autoComment(raw string) string {
+	lines := strings.Split(strings.TrimRight(raw, "\n"), "\n")
+	for _, c := range autoCommentChars {
+		if strings.Contains("\n"+raw, "\n"+string(c)+scissors) {
+			return string(c)
+		}
+	}
+	last := lines[len(lines)-1]
+	if last != "" && strings.ContainsRune(autoCommentChars, rune(last[0])) {
+		return last[:1]
+	}
+	return ""
+}
+
+// Skip reports whether message was generated by git for merges, reverts or
+// autosquash and should not be held to the style rules.
+func Skip(message string) bool {
+	for _, prefix := range []string{"Merge ", "Revert \"", "fixup! ", "squash! ", "amend! "} {
+		if strings.HasPrefix(message, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check validates message against style.
+func Check(style prompt.Style, message string) Result {
+	subject, _, _ := strings.Cut(message, "\n")
+	res := Result{Subject: subject}
+	if Skip(message) {
+		res.Skipped = true
+		return res
+	}
+	res.Violations = style.Validate(message)
+	return res
+}
+
+// Failed reports whether any result has violations.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if len(r.Violations) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func WriteText(w io.Writer, results []Result) {
+	failed := 0
+	for _, r := range results {
+		if len(r.Violations) == 0 {
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "%s: %s\n", r.location(), r.Subject)
+		for _, v := range r.Violations {
+			fmt.Fprintf(w, "  ! %s\n", v.String())
+		}
+		if r.Suggestion != "" {
+			fmt.Fprintln(w, "  suggested message:")
+			for _, line := range strings.Split(r.Suggestion, "\n") {
+				fmt.Fprintln(w, "    "+line)
+			}
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(w, "%d of %d messages have style violations\n", failed, len(results))
+	}
+}
+
+func WriteJSON(w io.Writer, results []Result) error {
+	if results == nil {
+		results = []Result{}
+	}
+	for i := range results {
+		if results[i].Violations == nil {
+			results[i].Violations = []prompt.Violation{}
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+// WriteSARIF writes results as a SARIF 2.1.0 log so code scanning tools can
+// annotate them.
+func WriteSARIF(w io.Writer, results []Result, style, version string) error {
+	type message struct {
+		Text string `json:"text"`
+	}
+	type artifactLocation struct {
+		URI string `json:"uri"`
+	}
+	type physicalLocation struct {
+		ArtifactLocation artifactLocation `json:"artifactLocation"`
+	}
+	type logicalLocation struct {
+		Name string `json:"name"`
+		Kind string `json:"kind"`
+	}
+	type location struct {
+		PhysicalLocation *physicalLocation `json:"physicalLocation,omitempty"`
+		LogicalLocations []logicalLocation `json:"logicalLocations,omitempty"`
+	}
+	type result struct {
+		RuleID    string     `json:"ruleId"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations"`
+	}
+	type rule struct {
+		ID string `json:"id"`
+	}
+	type driver struct {
+		Name           string `json:"name"`
+		Version        string `json:"version"`
+		InformationURI string `json:"informationUri"`
+		Rules          []rule `json:"rules"`
+	}
+	type tool struct {
+		Driver driver `json:"driver"`
+	}
+	type run struct {
+		Tool    tool     `json:"tool"`
+		Results []result `json:"results"`
+	}
+	type log struct {
+		Schema  string `json:"$schema"`
+		Version string `json:"version"`
+		Runs    []run  `json:"runs"`
+	}
+
+	ruleIDs := map[string]struct{}{}
+	out := []result{}
+	for _, r := range results {
+		loc := location{}
+		if r.Commit != "" {
+			loc.LogicalLocations = []logicalLocation{{Name: r.Commit, Kind: "commit"}}
+		} else {
+			loc.PhysicalLocation = &physicalLocation{ArtifactLocation: artifactLocation{URI: r.File}}
+		}
+		for _, v := range r.Violations {
+			ruleIDs[v.Rule] = struct{}{}
+			out = append(out, result{
+				RuleID:    v.Rule,
+				Level:     "error",
+				Message:   message{Text: fmt.Sprintf("%s (%s style): %s", v.Message, style, r.Subject)},
+				Locations: []location{loc},
+			})
+		}
+	}
+	rules := make([]rule, 0, len(ruleIDs))
+	for id := range ruleIDs {
+		rules = append(rules, rule{ID: id})
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []run{{
+			Tool: tool{Driver: driver{
+				Name:           "gommit",
+				Version:        version,
+				InformationURI: "https://github.com/MenschMachine/gommit",
+				Rules:          rules,
+			}},
+			Results: out,
+		}},
+	})
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
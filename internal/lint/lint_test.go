@@ -0,0 +1,67 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/MenschMachine/gommit/internal/prompt"
+)
+
+func TestCleanMessage(t *testing.T) {
+	tests := []struct {
+		raw, comment, want string
+	}{
+		{"feat: add login\n\nBody line  \n# Please enter the commit message\n#" + scissors + "\ndiff --git a/a b/a\n", "#", "feat: add login\n\nBody line"},
+		{"feat: add login\n\n#1 is fixed\n; Please enter the commit message\n", ";", "feat: add login\n\n#1 is fixed"},
+		{"feat: add login\n\n#1 is fixed\n; Please enter the commit message\n", "auto", "feat: add login\n\n#1 is fixed"},
+		{"feat: add login\n\n#1 is fixed\n;" + scissors + "\ndiff --git a/a b/a\n", "auto", "feat: add login\n\n#1 is fixed"},
+		{"feat: add login\n\nBody line\n", "auto", "feat: add login\n\nBody line"},
+	}
+	for _, tt := range tests {
+		if got := CleanMessage(tt.raw, tt.comment); got != tt.want {
+			t.Errorf("CleanMessage(%q, %q) = %q, want %q", tt.raw, tt.comment, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSkipsGitGeneratedMessages(t *testing.T) {
+	style, _ := prompt.LookupStyle("conventional")
+	for _, msg := range []string{"Merge branch 'main'", "fixup! feat: add login", "Revert \"feat: add login\""} {
+		res := Check(style, msg)
+		if !res.Skipped || len(res.Violations) > 0 {
+			t.Errorf("expected %q to be skipped, got %+v", msg, res)
+		}
+	}
+	if res := Check(style, "add login"); len(res.Violations) == 0 {
+		t.Fatalf("expected violations for non-conventional subject")
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	results := []Result{
+		{Commit: "abc123", Subject: "add login", Violations: []prompt.Violation{{Rule: "subject-format", Message: "bad"}}},
+		{File: ".git/COMMIT_EDITMSG", Subject: "feat: ok"},
+	}
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, results, "conventional", "dev"); err != nil {
+		t.Fatalf("WriteSARIF: %v", err)
+	}
+	var decoded struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Results []struct {
+				RuleID string `json:"ruleId"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if decoded.Version != "2.1.0" || len(decoded.Runs) != 1 || len(decoded.Runs[0].Results) != 1 {
+		t.Fatalf("unexpected SARIF log: %s", buf.String())
+	}
+	if decoded.Runs[0].Results[0].RuleID != "subject-format" {
+		t.Fatalf("unexpected rule id %q", decoded.Runs[0].Results[0].RuleID)
+	}
+}
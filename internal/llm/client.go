@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -16,6 +17,30 @@ type Client struct {
 	Model   string
 	Headers map[string]string
 	HTTP    *http.Client
+
+	// OnUsage, if set, is called with the token usage of every successful
+	// completion that reports it.
+	OnUsage func(Usage)
+}
+
+// Usage counts the tokens of one or more completions. CachedTokens is the
+// part of PromptTokens served from the provider's prompt cache.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	CachedTokens     int `json:"cached_tokens"`
+}
+
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		CachedTokens:     u.CachedTokens + other.CachedTokens,
+	}
+}
+
+func (u Usage) IsZero() bool {
+	return u == Usage{}
 }
 
 func NewClient(baseURL, apiKey, model string, headers map[string]string, timeoutSeconds int) *Client {
@@ -34,9 +59,15 @@ func NewClient(baseURL, apiKey, model string, headers map[string]string, timeout
 }
 
 type chatRequest struct {
-	Model       string        `json:"model"`
-	Messages    []chatMessage `json:"messages"`
-	Temperature float64       `json:"temperature,omitempty"`
+	Model         string         `json:"model"`
+	Messages      []chatMessage  `json:"messages"`
+	Temperature   float64        `json:"temperature,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type chatMessage struct {
@@ -48,6 +79,38 @@ type chatResponse struct {
 	Choices []struct {
 		Message chatMessage `json:"message"`
 	} `json:"choices"`
+	Usage *responseUsage `json:"usage"`
+}
+
+// responseUsage accepts both the OpenAI usage block and Anthropic's
+// input/output token names.
+type responseUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+}
+
+func (u responseUsage) normalize() Usage {
+	if u.PromptTokens > 0 || u.CompletionTokens > 0 {
+		return Usage{
+			PromptTokens:     u.PromptTokens,
+			CompletionTokens: u.CompletionTokens,
+			CachedTokens:     u.PromptTokensDetails.CachedTokens,
+		}
+	}
+	// Anthropic reports cache reads and writes separately from input_tokens.
+	return Usage{
+		PromptTokens:     u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens,
+		CompletionTokens: u.OutputTokens,
+		CachedTokens:     u.CacheReadInputTokens,
+	}
 }
 
 func (c *Client) buildChatRequest(systemPrompt, userPrompt string) chatRequest {
@@ -67,15 +130,93 @@ func (c *Client) BuildChatPayload(systemPrompt, userPrompt string) ([]byte, erro
 }
 
 func (c *Client) ChatCompletion(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	resp, err := c.post(ctx, c.buildChatRequest(systemPrompt, userPrompt))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var decoded chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return "", err
+	}
+	if len(decoded.Choices) == 0 {
+		return "", fmt.Errorf("llm returned no choices")
+	}
+	if decoded.Usage != nil && c.OnUsage != nil {
+		c.OnUsage(decoded.Usage.normalize())
+	}
+	return strings.TrimSpace(decoded.Choices[0].Message.Content), nil
+}
+
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *responseUsage `json:"usage"`
+}
+
+// ChatCompletionStream is ChatCompletion with server-sent events: onDelta
+// receives the text as it arrives and the full text is returned at the end.
+func (c *Client) ChatCompletionStream(ctx context.Context, systemPrompt, userPrompt string, onDelta func(string)) (string, error) {
 	payload := c.buildChatRequest(systemPrompt, userPrompt)
-	buf, err := json.Marshal(payload)
+	payload.Stream = true
+	payload.StreamOptions = &streamOptions{IncludeUsage: true}
+	resp, err := c.post(ctx, payload)
 	if err != nil {
 		return "", err
 	}
+	defer resp.Body.Close()
+
+	var text strings.Builder
+	var usage *responseUsage
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return "", fmt.Errorf("llm stream: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			text.WriteString(choice.Delta.Content)
+			if onDelta != nil {
+				onDelta(choice.Delta.Content)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if usage != nil && c.OnUsage != nil {
+		c.OnUsage(usage.normalize())
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+func (c *Client) post(ctx context.Context, payload chatRequest) (*http.Response, error) {
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
 	url := c.BaseURL + "/chat/completions"
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(buf))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	if c.APIKey != "" {
@@ -90,18 +231,11 @@ func (c *Client) ChatCompletion(ctx context.Context, systemPrompt, userPrompt st
 
 	resp, err := c.HTTP.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", fmt.Errorf("llm request failed: %s", resp.Status)
+		resp.Body.Close()
+		return nil, fmt.Errorf("llm request failed: %s", resp.Status)
 	}
-	var decoded chatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
-		return "", err
-	}
-	if len(decoded.Choices) == 0 {
-		return "", fmt.Errorf("llm returned no choices")
-	}
-	return strings.TrimSpace(decoded.Choices[0].Message.Content), nil
+	return resp, nil
 }
@@ -0,0 +1,86 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChatCompletionUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want Usage
+	}{
+		{
+			name: "openai",
+			body: `{"choices":[{"message":{"role":"assistant","content":"feat: x"}}],
+				"usage":{"prompt_tokens":120,"completion_tokens":12,"prompt_tokens_details":{"cached_tokens":100}}}`,
+			want: Usage{PromptTokens: 120, CompletionTokens: 12, CachedTokens: 100},
+		},
+		{
+			name: "anthropic",
+			body: `{"choices":[{"message":{"role":"assistant","content":"feat: x"}}],
+				"usage":{"input_tokens":20,"output_tokens":12,"cache_read_input_tokens":100}}`,
+			want: Usage{PromptTokens: 120, CompletionTokens: 12, CachedTokens: 100},
+		},
+		{
+			name: "no usage",
+			body: `{"choices":[{"message":{"role":"assistant","content":"feat: x"}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			var got Usage
+			client := NewClient(srv.URL, "key", "model", nil, 5)
+			client.OnUsage = func(u Usage) { got = got.Add(u) }
+			message, err := client.ChatCompletion(context.Background(), "system", "user")
+			if err != nil {
+				t.Fatalf("ChatCompletion: %v", err)
+			}
+			if message != "feat: x" {
+				t.Fatalf("message = %q", message)
+			}
+			if got != tt.want {
+				t.Fatalf("usage = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatCompletionStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"choices":[{"delta":{"role":"assistant"}}]}`,
+			`{"choices":[{"delta":{"content":"feat: "}}]}`,
+			`{"choices":[{"delta":{"content":"stream"}}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":10,"completion_tokens":3}}`,
+			`[DONE]`,
+		} {
+			_, _ = w.Write([]byte("data: " + event + "\n\n"))
+		}
+	}))
+	defer srv.Close()
+
+	var deltas []string
+	var got Usage
+	client := NewClient(srv.URL, "key", "model", nil, 5)
+	client.OnUsage = func(u Usage) { got = u }
+	message, err := client.ChatCompletionStream(context.Background(), "system", "user", func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("ChatCompletionStream: %v", err)
+	}
+	if message != "feat: stream" || len(deltas) != 2 {
+		t.Fatalf("message = %q, deltas = %q", message, deltas)
+	}
+	if got != (Usage{PromptTokens: 10, CompletionTokens: 3}) {
+		t.Fatalf("usage = %+v", got)
+	}
+}
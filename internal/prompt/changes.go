@@ -0,0 +1,114 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/MenschMachine/gommit/internal/git"
+)
+
+// Change is one row of the change summary at the top of the prompt.
+type Change struct {
+	// Status is added, modified, deleted, renamed, copied, mode-changed
+	// or type-changed.
+	Status string
+	Path   string
+	// OldPath is set for renames and copies.
+	OldPath string
+	Added   int
+	Removed int
+	Binary  bool
+	// Details are notes such as "100% similar" or "mode 100644 -> 100755".
+	Details []string
+}
+
+func collectChanges(chunks []diffChunk, binaries []git.BinaryFile) []Change {
+	var out []Change
+	for _, chunk := range chunks {
+		f := chunk.File
+		if f.Path() == "" {
+			continue
+		}
+		c := Change{Status: changeStatus(f), Path: f.Path(), Binary: f.Binary}
+		if f.Status == git.StatusRenamed || f.Status == git.StatusCopied {
+			c.OldPath = f.OldPath
+			c.Details = append(c.Details, fmt.Sprintf("%d%% similar", f.Similarity))
+		}
+		switch {
+		case f.Status == git.StatusTypeChanged:
+			c.Details = append(c.Details, modeType(f.OldMode)+" -> "+modeType(f.NewMode))
+		case f.ModeChanged():
+			c.Details = append(c.Details, "mode "+f.OldMode+" -> "+f.NewMode)
+		case f.Status == git.StatusAdded && f.NewMode != "100644":
+			c.Details = append(c.Details, modeType(f.NewMode))
+		}
+		if f.Truncated {
+			c.Details = append(c.Details, "diff truncated")
+		}
+		for _, h := range f.Hunks {
+			for _, line := range h.Lines {
+				switch {
+				case strings.HasPrefix(line, "+"):
+					c.Added++
+				case strings.HasPrefix(line, "-"):
+					c.Removed++
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	for _, bf := range binaries {
+		c := Change{Status: bf.Status.String(), Path: bf.Path, OldPath: bf.OldPath, Binary: true}
+		if bf.Size >= 0 {
+			c.Details = append(c.Details, fmt.Sprintf("%d bytes", bf.Size))
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func changeStatus(f git.FileDiff) string {
+	if f.Status == git.StatusModified && f.ModeChanged() && len(f.Hunks) == 0 {
+		return "mode-changed"
+	}
+	return f.Status.String()
+}
+
+// modeType names the kind of file a git mode stands for.
+func modeType(mode string) string {
+	switch mode {
+	case "120000":
+		return "symlink"
+	case "160000":
+		return "submodule"
+	case "100755":
+		return "executable"
+	default:
+		return "file"
+	}
+}
+
+// changeTable formats changes as aligned columns.
+func changeTable(changes []Change) string {
+	var buf strings.Builder
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "status\tpath\tlines\tdetails")
+	for _, c := range changes {
+		path := c.Path
+		if c.OldPath != "" {
+			path = c.OldPath + " -> " + c.Path
+		}
+		lines := fmt.Sprintf("+%d -%d", c.Added, c.Removed)
+		if c.Binary {
+			lines = "binary"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Status, path, lines, strings.Join(c.Details, ", "))
+	}
+	w.Flush()
+	rows := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i, row := range rows {
+		rows[i] = strings.TrimRight(row, " ")
+	}
+	return strings.Join(rows, "\n")
+}
@@ -0,0 +1,162 @@
+package prompt
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Fix repairs the violations that need no judgement: surrounding code
+// fences, a missing blank line after the subject, a trailing period the
+// style forbids, a miscased type and body lines over the wrap width.
+// Everything else is left for the model.
+func (s Style) Fix(message string) string {
+	message = stripCodeFences(strings.ReplaceAll(message, "\r\n", "\n"))
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return message
+	}
+	subject, rest := splitMessage(message)
+	subject = strings.TrimSpace(subject)
+
+	if s.Check != nil && hasRule(s.Check(s, subject), "subject-period") {
+		subject = strings.TrimSuffix(subject, ".")
+	}
+	if len(s.Types) > 0 {
+		if m := conventionalHeader.FindStringSubmatch(subject); m != nil {
+			if lower := strings.ToLower(m[1]); lower != m[1] && containsString(s.Types, lower) {
+				subject = lower + subject[len(m[1]):]
+			}
+		}
+	}
+
+	if len(rest) > 0 && strings.TrimSpace(rest[0]) != "" {
+		rest = append([]string{""}, rest...)
+	}
+	if s.BodyWrap > 0 {
+		rest = wrapLines(rest, s.BodyWrap)
+	}
+	return strings.Join(append([]string{subject}, rest...), "\n")
+}
+
+func hasRule(violations []Violation, rule string) bool {
+	for _, v := range violations {
+		if v.Rule == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// stripCodeFences removes a fence pair wrapping the whole message; fenced
+// examples in the body are kept.
+func stripCodeFences(message string) string {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	if len(lines) < 2 || !isFence(lines[0]) || strings.TrimSpace(lines[len(lines)-1]) != "```" {
+		return message
+	}
+	inner := lines[1 : len(lines)-1]
+	// With fences in between, the first and last may open and close
+	// examples instead.
+	for _, line := range inner {
+		if isFence(line) {
+			return message
+		}
+	}
+	return strings.Join(inner, "\n")
+}
+
+func isFence(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "```")
+}
+
+// trailerLine matches a git trailer such as "Signed-off-by: A <a@b>" or a
+// Conventional Commits footer.
+var trailerLine = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9-]*|BREAKING CHANGE): `)
+
+// keepLength reports which body lines must not be wrapped: the trailers
+// in the last paragraph, fenced or indented code, and lines without
+// spaces such as URLs.
+func keepLength(body []string) []bool {
+	keep := make([]bool, len(body))
+	fenced := false
+	for i, line := range body {
+		if isFence(line) {
+			fenced = !fenced
+			keep[i] = true
+			continue
+		}
+		keep[i] = fenced || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "    ") ||
+			!strings.Contains(strings.TrimSpace(line), " ")
+	}
+	// Trailer values may continue on indented lines.
+	start := len(body)
+	for start > 0 && strings.TrimSpace(body[start-1]) != "" &&
+		(trailerLine.MatchString(body[start-1]) || strings.HasPrefix(body[start-1], " ")) {
+		start--
+	}
+	if start < len(body) && trailerLine.MatchString(body[start]) && (start == 0 || strings.TrimSpace(body[start-1]) == "") {
+		for i := start; i < len(body); i++ {
+			keep[i] = true
+		}
+	}
+	return keep
+}
+
+// wrapLines word-wraps lines longer than width, keeping the indentation or
+// list marker of the original line on its continuations. Lines keepLength
+// reports are left untouched.
+func wrapLines(lines []string, width int) []string {
+	var out []string
+	keep := keepLength(lines)
+	for i, line := range lines {
+		if utf8.RuneCountInString(line) <= width || keep[i] {
+			out = append(out, line)
+			continue
+		}
+		prefix, hang := linePrefix(line)
+		words := strings.Fields(line[len(prefix):])
+		current := prefix
+		for _, word := range words {
+			if current != prefix && current != hang && utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) > width {
+				out = append(out, current)
+				current = hang
+			}
+			if current == prefix || current == hang {
+				current += word
+			} else {
+				current += " " + word
+			}
+		}
+		out = append(out, current)
+	}
+	return out
+}
+
+// linePrefix returns the leading indentation or list marker of line and the
+// indentation its continuation lines should use.
+func linePrefix(line string) (string, string) {
+	trimmed := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(trimmed)]
+	for _, marker := range []string{"- ", "* ", "+ "} {
+		if strings.HasPrefix(trimmed, marker) {
+			return indent + marker, indent + strings.Repeat(" ", len(marker))
+		}
+	}
+	return indent, indent
+}
+
+// RepairHint builds refinement guidance asking the model to correct the
+// violations found in its previous answer.
+func RepairHint(message string, violations []Violation) string {
+	var b strings.Builder
+	b.WriteString("Your previous commit message broke the style rules.\n")
+	b.WriteString("Previous message:\n")
+	b.WriteString(message)
+	b.WriteString("\nViolations:\n")
+	for _, v := range violations {
+		b.WriteString("- " + v.String() + "\n")
+	}
+	b.WriteString("Write a corrected commit message that fixes every violation.")
+	return b.String()
+}
@@ -0,0 +1,48 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStyleFix(t *testing.T) {
+	conventional, _ := LookupStyle("conventional")
+	beams, _ := LookupStyle("beams")
+	freeform, _ := LookupStyle("freeform")
+	kernel, _ := LookupStyle("kernel")
+	trailers := "Co-developed-by: Someone With A Rather Long Name <someone.with.a.long.name@example.com>\n" +
+		"Signed-off-by: Someone With A Rather Long Name <someone.with.a.long.name@example.com>"
+	example := "```\n" + strings.Repeat("call(arg) ", 9) + "\n```"
+
+	tests := []struct {
+		name    string
+		style   Style
+		message string
+		want    string
+	}{
+		{"fences and period", conventional, "```\nfeat: add login.\n```", "feat: add login"},
+		{"type case", conventional, "Fix(api): handle nil", "fix(api): handle nil"},
+		{"blank line", conventional, "fix: x\nbody text", "fix: x\n\nbody text"},
+		{"ellipsis kept", conventional, "fix: wait for it...", "fix: wait for it..."},
+		{"freeform period kept", freeform, "Bump to v1.2.", "Bump to v1.2."},
+		{"trailers kept", kernel, "net: fix leak\n\nDrop it.\n\n" + trailers, "net: fix leak\n\nDrop it.\n\n" + trailers},
+		{"body fence kept", beams, "Add login\n\nFor example:\n\n" + example, "Add login\n\nFor example:\n\n" + example},
+		{
+			"wrap body",
+			beams,
+			"Add login\n\n- " + strings.Repeat("word ", 20) + "end",
+			"Add login\n\n- word word word word word word word word word word word word word word\n  word word word word word word end",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.style.Fix(tt.message)
+			if got != tt.want {
+				t.Fatalf("Fix(%q) = %q, want %q", tt.message, got, tt.want)
+			}
+			if vs := tt.style.Validate(got); len(vs) > 0 {
+				t.Fatalf("expected fixed message to validate, got %v", vs)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package prompt
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/MenschMachine/gommit/internal/scopes"
+)
+
+// Detail levels a file's diff can end up at when the prompt has a budget,
+// from most to least complete.
+const (
+	DetailFull      = "full"
+	DetailCondensed = "condensed"
+	DetailHunks     = "hunk headers"
+	DetailHeader    = "header"
+	DetailOmitted   = "omitted"
+)
+
+// File categories, from least to most important for the diff budget.
+const (
+	CategoryGenerated = "generated"
+	CategoryDocs      = "docs"
+	CategoryTest      = "test"
+	CategorySource    = "source"
+	CategoryImportant = "important"
+)
+
+var categoryRank = map[string]int{
+	CategoryGenerated: 0,
+	CategoryDocs:      1,
+	CategoryTest:      2,
+	CategorySource:    3,
+	CategoryImportant: 4,
+}
+
+// FileDetail is how much of one file's diff made it into the prompt.
+type FileDetail struct {
+	Path     string
+	Level    string
+	Category string
+	// Changed counts added and removed lines, leaving out lines that only
+	// changed in whitespace.
+	Changed int
+}
+
+func (d FileDetail) String() string {
+	lines := "lines"
+	if d.Changed == 1 {
+		lines = "line"
+	}
+	return fmt.Sprintf("%-12s %s (%s, %d %s changed)", d.Level, d.Path, d.Category, d.Changed, lines)
+}
+
+// priority ranks a chunk for the diff budget; chunks that rank higher are
+// given more detail first.
+type priority struct {
+	category string
+	changed  int
+}
+
+func (p priority) before(q priority) bool {
+	if p.category != q.category {
+		return categoryRank[p.category] > categoryRank[q.category]
+	}
+	return p.changed > q.changed
+}
+
+func chunkPriority(chunk diffChunk, important []string) priority {
+	return priority{category: categorize(chunk, important), changed: semanticChanges(chunk)}
+}
+
+// categorize sorts a file into source, tests, docs or generated code by its
+// path and, for generated code, the marker Go and other generators write.
+// Files matching an important glob outrank all others.
+func categorize(chunk diffChunk, important []string) string {
+	file := chunk.Path
+	for _, glob := range important {
+		if scopes.Match(glob, file) {
+			return CategoryImportant
+		}
+	}
+	base := path.Base(file)
+	dirs := strings.Split(path.Dir(file), "/")
+	has := func(names ...string) bool {
+		for _, dir := range dirs {
+			for _, name := range names {
+				if dir == name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	switch {
+	case generatedFiles[base], has("vendor", "node_modules", "dist"),
+		strings.HasSuffix(base, ".pb.go"), strings.HasSuffix(base, "_gen.go"), strings.HasPrefix(base, "zz_generated"),
+		strings.Contains(base, ".min."), strings.Contains(base, ".gen."),
+		chunk.Generated, hasGeneratedMarker(chunk):
+		return CategoryGenerated
+	case strings.HasSuffix(base, "_test.go"), strings.HasPrefix(base, "test_") && strings.HasSuffix(base, ".py"),
+		strings.Contains(base, ".test."), strings.Contains(base, ".spec."), has("test", "tests", "__tests__", "testdata"):
+		return CategoryTest
+	case docExtensions[path.Ext(base)], has("doc", "docs"),
+		strings.HasPrefix(base, "LICENSE"), strings.HasPrefix(base, "CHANGELOG"):
+		return CategoryDocs
+	}
+	return CategorySource
+}
+
+// generatedMarker is the line https://go.dev/s/generatedcode asks
+// generators to write; many non-Go generators follow it too.
+var generatedMarker = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// IsGenerated reports whether a line of src is the generated code marker.
+func IsGenerated(src []byte) bool {
+	for line := range bytes.Lines(src) {
+		if generatedMarker.Match(bytes.TrimRight(line, "\r\n")) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGeneratedMarker reports whether an added or unchanged line of chunk is
+// the generated code marker, so a file losing its marker or merely
+// mentioning it is not taken for generated code.
+func hasGeneratedMarker(chunk diffChunk) bool {
+	for _, h := range chunk.File.Hunks {
+		for _, line := range h.Lines {
+			if (strings.HasPrefix(line, "+") || strings.HasPrefix(line, " ")) && generatedMarker.MatchString(line[1:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var generatedFiles = map[string]bool{
+	"go.sum":            true,
+	"package-lock.json": true,
+	"yarn.lock":         true,
+	"pnpm-lock.yaml":    true,
+	"Cargo.lock":        true,
+	"poetry.lock":       true,
+	"composer.lock":     true,
+	"Gemfile.lock":      true,
+}
+
+var docExtensions = map[string]bool{".md": true, ".rst": true, ".adoc": true, ".txt": true}
+
+// semanticChanges counts the added and removed lines of a chunk, leaving
+// out removed and added lines that differ only in whitespace.
+func semanticChanges(chunk diffChunk) int {
+	removed := map[string]int{}
+	var added []string
+	for _, h := range chunk.File.Hunks {
+		for _, line := range h.Lines {
+			if line == "" {
+				continue
+			}
+			text := strings.Join(strings.Fields(line[1:]), "")
+			if text == "" {
+				continue
+			}
+			switch line[0] {
+			case '-':
+				removed[text]++
+			case '+':
+				added = append(added, text)
+			}
+		}
+	}
+	n := 0
+	for _, text := range added {
+		if removed[text] > 0 {
+			removed[text]--
+			continue
+		}
+		n++
+	}
+	for _, count := range removed {
+		n += count
+	}
+	return n
+}
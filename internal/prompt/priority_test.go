@@ -0,0 +1,99 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCategorize(t *testing.T) {
+	tests := []struct {
+		path, text string
+		want       string
+	}{
+		{"internal/git/diff.go", "", CategorySource},
+		{"internal/git/diff_test.go", "", CategoryTest},
+		{"web/src/app.spec.ts", "", CategoryTest},
+		{"tests/test_cli.py", "", CategoryTest},
+		{"README.md", "", CategoryDocs},
+		{"docs/setup/install.sh", "", CategoryDocs},
+		{"go.sum", "", CategoryGenerated},
+		{"api/v1/api.pb.go", "", CategoryGenerated},
+		{"vendor/github.com/x/y.go", "", CategoryGenerated},
+		{"mocks.go", "+// Code generated by mockgen. DO NOT EDIT.", CategoryGenerated},
+		{"stub.go", " // Code generated by stringer. DO NOT EDIT.\n+var x = 1", CategoryGenerated},
+		{"handwritten.go", "-// Code generated by mockgen. DO NOT EDIT.", CategorySource},
+		{"gen.go", "+// Code generated files say DO NOT EDIT. at the top.\n+var note = \"// Code generated by x. DO NOT EDIT.\"", CategorySource},
+		{"cmd/gommit/main.go", "", CategoryImportant},
+		{"docs/important.md", "", CategoryImportant},
+	}
+	important := []string{"cmd/**", "docs/important.md"}
+	for _, tt := range tests {
+		text := "diff --git a/" + tt.path + " b/" + tt.path + "\n@@ -1 +1 @@\n" + tt.text
+		if got := categorize(parseDiffChunks(text)[0], important); got != tt.want {
+			t.Errorf("categorize(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCategorizeKnownGenerated(t *testing.T) {
+	// The marker is far above the hunk, so only the file content shows it.
+	chunk := parseDiffChunks("diff --git a/api.go b/api.go\n@@ -40 +40 @@\n-a\n+b")[0]
+	if got := categorize(chunk, nil); got != CategorySource {
+		t.Fatalf("categorize = %s, want %s", got, CategorySource)
+	}
+	chunk.Generated = true
+	if got := categorize(chunk, nil); got != CategoryGenerated {
+		t.Fatalf("categorize = %s, want %s", got, CategoryGenerated)
+	}
+}
+
+func TestIsGenerated(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"// Code generated by protoc-gen-go. DO NOT EDIT.\r\n\npackage api\n", true},
+		{"package api\n\n// Code generated by hand, please edit.\n", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsGenerated([]byte(tt.src)); got != tt.want {
+			t.Errorf("IsGenerated(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestSemanticChanges(t *testing.T) {
+	chunks := parseDiffChunks("diff --git a/a.go b/a.go\n@@ -1,3 +1,3 @@\n-func a() {\n-\treturn\n+func a()  {\n+  return 1\n \n}")
+	if got := semanticChanges(chunks[0]); got != 2 {
+		t.Fatalf("semanticChanges = %d, want 2", got)
+	}
+}
+
+func TestBuildDiffWithBudgetPrefersImportantFiles(t *testing.T) {
+	docs := "diff --git a/docs/a.md b/docs/a.md\n@@ -1 +1 @@\n+" + strings.Repeat("d", 600)
+	test := "diff --git a/a_test.go b/a_test.go\n@@ -1 +1 @@\n+" + strings.Repeat("t", 600)
+	src := "diff --git a/a.go b/a.go\n@@ -1 +1 @@\n+" + strings.Repeat("s", 600)
+	chunks := parseDiffChunks(docs + "\n" + test + "\n" + src)
+
+	diff, details := buildDiffWithBudget(chunks, 900, nil)
+	want := []string{DetailHunks, DetailHunks, DetailFull}
+	for i, d := range details {
+		if d.Level != want[i] {
+			t.Errorf("%s: level %s, want %s", d.Path, d.Level, want[i])
+		}
+	}
+	if len(diff) > 900 || !strings.HasPrefix(diff, "diff --git a/docs/a.md") || !strings.HasSuffix(diff, src) {
+		t.Fatalf("diff is not in diff order with a.go in full:\n%s", diff)
+	}
+
+	_, details = buildDiffWithBudget(chunks, 900, []string{"docs/**"})
+	if details[0].Level != DetailFull || details[2].Level != DetailHunks {
+		t.Fatalf("important glob not preferred: %+v", details)
+	}
+
+	_, details = buildDiffWithBudget(chunks, 30, nil)
+	if details[0].Level != DetailOmitted || details[1].Level != DetailOmitted || details[2].Level != DetailHeader {
+		t.Fatalf("expected only the source header to fit: %+v", details)
+	}
+}
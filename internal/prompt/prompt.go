@@ -8,125 +8,12 @@ import (
 	"github.com/MenschMachine/gommit/internal/git"
 )
 
-const systemPrompt = "You are a senior software engineer who writes precise git commit messages."
-
-func SystemPrompt() string {
-	return systemPrompt
-}
-
-func BuildSinglePrompt(style string, scope string, diff string, binaries []git.BinaryFile, truncated []string) string {
-	var b strings.Builder
-	b.WriteString("Generate a git commit message for the following changes.\n")
-	b.WriteString(fmt.Sprintf("Diff scope: %s.\n", scope))
-	b.WriteString("\n")
-
-	if strings.ToLower(style) == "conventional" {
-		b.WriteString("Use Conventional Commits. Format: type(scope): summary. Summary <= 72 chars, imperative, no trailing period.\n")
-		b.WriteString("Allowed types: feat, fix, docs, style, refactor, perf, test, build, ci, chore, revert.\n")
-		b.WriteString("Include body if useful, separated by a blank line.\n")
-	} else {
-		b.WriteString("Write a concise summary line (<= 72 chars) and an optional body if helpful.\n")
-	}
-
-	if len(truncated) > 0 {
-		sort.Strings(truncated)
-		b.WriteString("\nNote: some file diffs were truncated due to size:\n")
-		for _, path := range truncated {
-			b.WriteString("- " + path + "\n")
-		}
-	}
-
-	if len(binaries) > 0 {
-		b.WriteString("\nBinary files changed (content omitted):\n")
-		sort.Slice(binaries, func(i, j int) bool { return binaries[i].Path < binaries[j].Path })
-		for _, bf := range binaries {
-			size := "unknown"
-			if bf.Size >= 0 {
-				size = fmt.Sprintf("%d bytes", bf.Size)
-			}
-			b.WriteString(fmt.Sprintf("- %s (%s)\n", bf.Path, size))
-		}
-	}
-
-	b.WriteString("\nDiff:\n")
-	b.WriteString(diff)
-	b.WriteString("\n\nReturn only the commit message, no code fences or extra commentary.")
-	return b.String()
-}
-
-func BuildSinglePromptWithMax(style string, scope string, diff string, binaries []git.BinaryFile, truncated []string, maxChars int) string {
-	if maxChars <= 0 {
-		return BuildSinglePrompt(style, scope, diff, binaries, truncated)
-	}
-	return buildPromptWithMax(style, scope, diff, binaries, truncated, maxChars)
-}
-
 type diffChunk struct {
 	Path string
 	Text string
-}
-
-func buildPromptWithMax(style string, scope string, diff string, binaries []git.BinaryFile, truncated []string, maxChars int) string {
-	var b strings.Builder
-	b.WriteString("Generate a git commit message for the following changes.\n")
-	b.WriteString(fmt.Sprintf("Diff scope: %s.\n", scope))
-	b.WriteString("\n")
-
-	if strings.ToLower(style) == "conventional" {
-		b.WriteString("Use Conventional Commits. Format: type(scope): summary. Summary <= 72 chars, imperative, no trailing period.\n")
-		b.WriteString("Allowed types: feat, fix, docs, style, refactor, perf, test, build, ci, chore, revert.\n")
-		b.WriteString("Include body if useful, separated by a blank line.\n")
-	} else {
-		b.WriteString("Write a concise summary line (<= 72 chars) and an optional body if helpful.\n")
-	}
-
-	b.WriteString("\nNote: diff detail may be reduced to fit max_prompt_chars.\n")
-
-	if len(truncated) > 0 {
-		sort.Strings(truncated)
-		b.WriteString("\nNote: some file diffs were truncated due to size:\n")
-		for _, path := range truncated {
-			b.WriteString("- " + path + "\n")
-		}
-	}
-
-	if len(binaries) > 0 {
-		b.WriteString("\nBinary files changed (content omitted):\n")
-		sort.Slice(binaries, func(i, j int) bool { return binaries[i].Path < binaries[j].Path })
-		for _, bf := range binaries {
-			size := "unknown"
-			if bf.Size >= 0 {
-				size = fmt.Sprintf("%d bytes", bf.Size)
-			}
-			b.WriteString(fmt.Sprintf("- %s (%s)\n", bf.Path, size))
-		}
-	}
-
-	chunks := parseDiffChunks(diff)
-	files := collectFiles(chunks, binaries)
-	if len(files) > 0 {
-		b.WriteString("\nFiles changed (all):\n")
-		for _, file := range files {
-			b.WriteString("- " + file + "\n")
-		}
-	}
-
-	b.WriteString("\nDiff:\n")
-	preamble := b.String()
-
-	suffix := "\n\nReturn only the commit message, no code fences or extra commentary."
-
-	diffBudget := maxChars - len(preamble) - len(suffix)
-	if diffBudget < 0 {
-		return trimToMax(preamble+suffix, maxChars)
-	}
-
-	diffBody, _ := buildDiffWithBudget(chunks, diffBudget)
-	promptText := preamble + diffBody + suffix
-	if len(promptText) > maxChars {
-		return trimToMax(promptText, maxChars)
-	}
-	return promptText
+	File git.FileDiff
+	// Generated is set for files known to carry the generated code marker.
+	Generated bool
 }
 
 func parseDiffChunks(diff string) []diffChunk {
@@ -134,14 +21,14 @@ func parseDiffChunks(diff string) []diffChunk {
 	if diff == "" {
 		return nil
 	}
-	raw := git.SplitDiffChunks(diff)
-	chunks := make([]diffChunk, 0, len(raw))
-	for i, chunk := range raw {
-		path := git.ParseDiffPath(chunk)
+	files := git.ParseDiff(diff)
+	chunks := make([]diffChunk, 0, len(files))
+	for i, f := range files {
+		path := f.Path()
 		if path == "" {
 			path = fmt.Sprintf("unknown-%d", i+1)
 		}
-		chunks = append(chunks, diffChunk{Path: path, Text: chunk})
+		chunks = append(chunks, diffChunk{Path: path, Text: f.Text, File: f})
 	}
 	return chunks
 }
@@ -173,87 +60,68 @@ type chunkVariant struct {
 	full      string
 }
 
-func buildDiffWithBudget(chunks []diffChunk, budget int) (string, bool) {
-	if budget <= 0 || len(chunks) == 0 {
-		return "", len(chunks) > 0
-	}
-
-	variants := make([]chunkVariant, 0, len(chunks))
-	for _, chunk := range chunks {
-		header := diffHeaderOnly(chunk.Text)
-		hunks := diffHunkHeadersOnly(chunk.Text)
-		condensed := condenseChunk(chunk.Text, 2000)
-		variants = append(variants, chunkVariant{
-			header:    header,
-			hunks:     hunks,
-			condensed: condensed,
+// buildDiffWithBudget fits chunks into budget characters. Chunks get their
+// header line first, then the remaining budget upgrades them to hunk
+// headers, condensed and full content in turn, the most important chunks
+// first (see chunkPriority). Chunks keep their diff order in the result;
+// details report the level each one ended at.
+func buildDiffWithBudget(chunks []diffChunk, budget int, important []string) (string, []FileDetail) {
+	if len(chunks) == 0 {
+		return "", nil
+	}
+	variants := make([]chunkVariant, len(chunks))
+	details := make([]FileDetail, len(chunks))
+	priorities := make([]priority, len(chunks))
+	order := make([]int, len(chunks))
+	for i, chunk := range chunks {
+		variants[i] = chunkVariant{
+			header:    diffHeaderOnly(chunk.Text),
+			hunks:     diffHunkHeadersOnly(chunk.Text),
+			condensed: condenseChunk(chunk.Text, 2000),
 			full:      chunk.Text,
-		})
-	}
-
-	headerTotal := sumVariantSizes(variants, func(v chunkVariant) string { return v.header })
-	if len(variants) > 1 {
-		headerTotal += len(variants) - 1
-	}
-	if budget < headerTotal {
-		return buildPartialHeaders(variants, budget)
-	}
-
-	current := make([]string, len(variants))
-	for i, v := range variants {
-		current[i] = v.header
+		}
+		priorities[i] = chunkPriority(chunk, important)
+		details[i] = FileDetail{Path: chunk.Path, Level: DetailOmitted, Category: priorities[i].category, Changed: priorities[i].changed}
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return priorities[order[a]].before(priorities[order[b]]) })
+
+	// Every included chunk costs its length plus a joining newline; the
+	// last one needs none.
+	remaining := budget + 1
+	current := make([]string, len(chunks))
+	for _, i := range order {
+		if cost := len(variants[i].header) + 1; cost <= remaining {
+			current[i] = variants[i].header
+			details[i].Level = DetailHeader
+			remaining -= cost
+		}
 	}
-
-	remaining := budget - headerTotal
-	upgrade := func(next func(v chunkVariant) string) {
-		for i, v := range variants {
-			target := next(v)
-			extra := len(target) - len(current[i])
-			if extra <= 0 {
-				current[i] = target
+	upgrade := func(level string, next func(v chunkVariant) string) {
+		for _, i := range order {
+			if details[i].Level == DetailOmitted {
 				continue
 			}
+			target := next(variants[i])
+			extra := len(target) - len(current[i])
 			if extra <= remaining {
 				current[i] = target
-				remaining -= extra
+				details[i].Level = level
+				remaining -= max(extra, 0)
 			}
 		}
 	}
+	upgrade(DetailHunks, func(v chunkVariant) string { return v.hunks })
+	upgrade(DetailCondensed, func(v chunkVariant) string { return v.condensed })
+	upgrade(DetailFull, func(v chunkVariant) string { return v.full })
 
-	upgrade(func(v chunkVariant) string { return v.hunks })
-	upgrade(func(v chunkVariant) string { return v.condensed })
-	upgrade(func(v chunkVariant) string { return v.full })
-
-	return strings.Join(current, "\n"), false
-}
-
-func sumVariantSizes(variants []chunkVariant, getter func(chunkVariant) string) int {
-	total := 0
-	for _, v := range variants {
-		total += len(getter(v))
-	}
-	return total
-}
-
-func buildPartialHeaders(variants []chunkVariant, budget int) (string, bool) {
 	var out []string
-	remaining := budget
-	for _, v := range variants {
-		sep := 0
-		if len(out) > 0 {
-			sep = 1
-		}
-		needed := len(v.header) + sep
-		if needed > remaining {
-			return strings.Join(out, "\n"), true
+	for i, text := range current {
+		if details[i].Level != DetailOmitted {
+			out = append(out, text)
 		}
-		if sep > 0 {
-			remaining -= sep
-		}
-		out = append(out, v.header)
-		remaining -= len(v.header)
 	}
-	return strings.Join(out, "\n"), false
+	return strings.Join(out, "\n"), details
 }
 
 func diffHeaderOnly(chunk string) string {
@@ -306,6 +174,10 @@ func isDiffMetaLine(line string) bool {
 		return true
 	case strings.HasPrefix(line, "rename to "):
 		return true
+	case strings.HasPrefix(line, "copy from "):
+		return true
+	case strings.HasPrefix(line, "copy to "):
+		return true
 	default:
 		return false
 	}
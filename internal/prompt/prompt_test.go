@@ -5,11 +5,19 @@ import (
 	"testing"
 
 	"github.com/MenschMachine/gommit/internal/git"
+	"github.com/MenschMachine/gommit/internal/symbols"
 )
 
 func TestBuildSinglePromptIncludesMetadata(t *testing.T) {
 	binaries := []git.BinaryFile{{Path: "image.png", Size: 1234}}
-	promptText := BuildSinglePrompt("conventional", "staged only", "diff --git a/a b/a", binaries, []string{"big.txt"})
+	tmpl, err := LoadTemplate("conventional", TemplateSource{}, TemplateSource{})
+	if err != nil {
+		t.Fatalf("load template: %v", err)
+	}
+	_, promptText, err := tmpl.Render(Data{Scope: "staged only", Diff: "diff --git a/a b/a", Binaries: binaries, Truncated: []string{"big.txt"}}, 0)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
 
 	if !strings.Contains(promptText, "Conventional Commits") {
 		t.Fatalf("expected conventional commit instructions")
@@ -22,3 +30,137 @@ func TestBuildSinglePromptIncludesMetadata(t *testing.T) {
 	}
 }
 
+func TestRenderCustomUserTemplate(t *testing.T) {
+	user := TemplateSource{Inline: "Branch {{.Branch}}; last: {{join .RecentCommits \", \"}}; hint: {{.Hint}}\n{{.Diff}}"}
+	tmpl, err := LoadTemplate("freeform", TemplateSource{}, user)
+	if err != nil {
+		t.Fatalf("load template: %v", err)
+	}
+	system, promptText, err := tmpl.Render(Data{
+		Diff:          "diff --git a/a b/a",
+		Branch:        "main",
+		RecentCommits: []string{"fix: one", "feat: two"},
+		Hint:          "shorter",
+	}, 0)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(system, "git commit messages") {
+		t.Fatalf("expected built-in system prompt, got %q", system)
+	}
+	want := "Branch main; last: fix: one, feat: two; hint: shorter\ndiff --git a/a b/a"
+	if promptText != want {
+		t.Fatalf("got %q, want %q", promptText, want)
+	}
+}
+
+func TestRenderFitsDiffToMaxChars(t *testing.T) {
+	diff := "diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n+" + strings.Repeat("x", 5000)
+	tmpl, err := LoadTemplate("conventional", TemplateSource{}, TemplateSource{})
+	if err != nil {
+		t.Fatalf("load template: %v", err)
+	}
+	_, promptText, err := tmpl.Render(Data{Scope: "staged only", Diff: diff}, 1000)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if len(promptText) > 1000 {
+		t.Fatalf("expected prompt within 1000 chars, got %d", len(promptText))
+	}
+	if !strings.Contains(promptText, "@@ -1 +1 @@") || !strings.HasSuffix(promptText, "extra commentary.") {
+		t.Fatalf("expected reduced diff with intact footer, got %q", promptText)
+	}
+}
+
+func TestRenderFitsRepeatedDiffToMaxChars(t *testing.T) {
+	diff := "diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n+" + strings.Repeat("x", 5000)
+	user := TemplateSource{Inline: "{{.Diff}}\n---\n{{.Diff}}"}
+	tmpl, err := LoadTemplate("freeform", TemplateSource{}, user)
+	if err != nil {
+		t.Fatalf("load template: %v", err)
+	}
+	_, promptText, err := tmpl.Render(Data{Diff: diff}, 1000)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if len(promptText) > 1000 || strings.Contains(promptText, diffPlaceholder) {
+		t.Fatalf("expected both copies of the diff within 1000 chars, got %d: %q", len(promptText), promptText)
+	}
+	if strings.Count(promptText, "@@ -1 +1 @@") != 2 {
+		t.Fatalf("expected the reduced diff twice, got %q", promptText)
+	}
+}
+
+func TestLoadTemplateUnknownStyle(t *testing.T) {
+	if _, err := LoadTemplate("nope", TemplateSource{}, TemplateSource{}); err == nil {
+		t.Fatalf("expected error for unknown style")
+	}
+}
+
+func TestRenderIncludesScopesAndTypes(t *testing.T) {
+	tmpl, err := LoadTemplate("conventional", TemplateSource{}, TemplateSource{})
+	if err != nil {
+		t.Fatalf("load template: %v", err)
+	}
+	tmpl.Style.Types = []string{"feat", "fix"}
+	tmpl.Style.Scopes = []string{"api", "ui"}
+	_, promptText, err := tmpl.Render(Data{Diff: "diff --git a/a b/a", CommitScopes: []string{"api"}}, 0)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	for _, want := range []string{"Allowed types: feat, fix.", "Scope: use api", "Only these scopes are allowed: api, ui."} {
+		if !strings.Contains(promptText, want) {
+			t.Fatalf("expected %q in prompt:\n%s", want, promptText)
+		}
+	}
+}
+
+func TestDiffHunkHeadersOnlyKeepsCopySource(t *testing.T) {
+	chunk := "diff --git a/a.go b/b.go\nsimilarity index 90%\ncopy from a.go\ncopy to b.go\n--- a/a.go\n+++ b/b.go\n@@ -1 +1 @@\n-x\n+y"
+	want := "diff --git a/a.go b/b.go\nsimilarity index 90%\ncopy from a.go\ncopy to b.go\n--- a/a.go\n+++ b/b.go\n@@ -1 +1 @@"
+	if got := diffHunkHeadersOnly(chunk); got != want {
+		t.Fatalf("diffHunkHeadersOnly = %q, want %q", got, want)
+	}
+}
+
+func TestRenderChangeSummary(t *testing.T) {
+	diff := "diff --git a/old.go b/new.go\nsimilarity index 100%\nrename from old.go\nrename to new.go\n" +
+		"diff --git a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\n" +
+		"diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1,2 +1,2 @@\n-x\n+y\n+z"
+	tmpl, err := LoadTemplate("conventional", TemplateSource{}, TemplateSource{})
+	if err != nil {
+		t.Fatalf("load template: %v", err)
+	}
+	_, promptText, err := tmpl.Render(Data{Scope: "staged only", Diff: diff, Binaries: []git.BinaryFile{{Path: "logo.png", Size: 5, Status: git.StatusDeleted}}}, 0)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	want := "Change summary:\n" +
+		"status        path              lines   details\n" +
+		"renamed       old.go -> new.go  +0 -0   100% similar\n" +
+		"mode-changed  run.sh            +0 -0   mode 100644 -> 100755\n" +
+		"modified      a.go              +2 -1\n" +
+		"deleted       logo.png          binary  5 bytes\n"
+	if !strings.Contains(promptText, want) {
+		t.Errorf("expected change summary\n%s\ngot:\n%s", want, promptText)
+	}
+	if strings.Index(promptText, "Change summary:") > strings.Index(promptText, "Use Conventional Commits") {
+		t.Fatalf("summary is not at the top:\n%s", promptText)
+	}
+}
+
+func TestRenderKeepsSymbolsWhenDiffIsReduced(t *testing.T) {
+	diff := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n+" + strings.Repeat("x", 5000)
+	tmpl, err := LoadTemplate("conventional", TemplateSource{}, TemplateSource{})
+	if err != nil {
+		t.Fatalf("load template: %v", err)
+	}
+	syms := []symbols.File{{Path: "a.go", Changes: []symbols.Change{{Action: symbols.Added, Kind: "func", Name: "New"}}}}
+	_, promptText, err := tmpl.Render(Data{Scope: "staged only", Diff: diff, Symbols: syms}, 800)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(promptText, "Changed symbols:\n- a.go: added func New\n") || strings.Contains(promptText, "xxxx") {
+		t.Fatalf("expected symbol summary with a reduced diff, got %q", promptText)
+	}
+}
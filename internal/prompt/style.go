@@ -0,0 +1,341 @@
+package prompt
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Violation is a single rule a generated commit message breaks.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return v.Rule + ": " + v.Message
+}
+
+// Style pairs a built-in prompt template with the rules the messages it
+// produces are expected to follow. Zero SubjectMax or BodyWrap disables
+// the respective length check.
+type Style struct {
+	Name         string
+	Description  string
+	SubjectMax   int
+	BodyWrap     int
+	Types        []string
+	Scopes       []string
+	RequireScope bool
+	// RequiredTrailers lists trailer keys, such as Signed-off-by, that
+	// every message must carry.
+	RequiredTrailers []string
+	// Header matches the structured start of a subject, such as
+	// "feat(api): ", that prefixes like issue keys are put after.
+	Header *regexp.Regexp
+	// Check reports style-specific violations beyond the shared length,
+	// code fence and blank line rules.
+	Check func(s Style, message string) []Violation
+}
+
+// HeaderLen returns the length of the start of message that Header
+// matches, 0 without a Header or a match.
+func (s Style) HeaderLen(message string) int {
+	if s.Header == nil {
+		return 0
+	}
+	subject, _ := splitMessage(message)
+	return len(s.Header.FindString(subject))
+}
+
+// Validate reports every rule message breaks.
+func (s Style) Validate(message string) []Violation {
+	out := validateCommon(message, s.SubjectMax, s.BodyWrap)
+	if strings.TrimSpace(message) == "" {
+		return out
+	}
+	out = append(out, checkTrailers(s.RequiredTrailers, message)...)
+	if s.Check == nil {
+		return out
+	}
+	return append(out, s.Check(s, message)...)
+}
+
+var styles = map[string]Style{}
+
+// RegisterStyle adds s to the style registry. Every style needs a template
+// of the same name under templates/.
+func RegisterStyle(s Style) {
+	styles[strings.ToLower(s.Name)] = s
+}
+
+func LookupStyle(name string) (Style, bool) {
+	s, ok := styles[strings.ToLower(strings.TrimSpace(name))]
+	return s, ok
+}
+
+// StyleNames returns the registered style names in sorted order.
+func StyleNames() []string {
+	names := make([]string, 0, len(styles))
+	for name := range styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	ConventionalTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+	AngularTypes      = []string{"build", "ci", "docs", "feat", "fix", "perf", "refactor", "test"}
+
+	conventionalHeader = regexp.MustCompile(`^([a-zA-Z]+)(\(([^()]*)\))?(!)?: (.*)$`)
+	kernelHeader       = regexp.MustCompile(`^([\w./+-]+: )+(.*)$`)
+	gitmojiShortcode   = regexp.MustCompile(`^:[a-z0-9_+-]+: `)
+	signedOffBy        = regexp.MustCompile(`(?m)^Signed-off-by: .+ <[^<>@\s]+@[^<>\s]+>$`)
+
+	conventionalPrefix = regexp.MustCompile(`^[a-zA-Z]+(\([^()]*\))?!?: `)
+	kernelPrefix       = regexp.MustCompile(`^([\w./+-]+: )+`)
+	gitmojiPrefix      = regexp.MustCompile(`^\S+ `)
+)
+
+func init() {
+	RegisterStyle(Style{
+		Name:        "conventional",
+		Description: "Conventional Commits: type(scope): summary",
+		SubjectMax:  72,
+		Types:       ConventionalTypes,
+		Header:      conventionalPrefix,
+		Check:       checkConventional,
+	})
+	RegisterStyle(Style{
+		Name:        "freeform",
+		Description: "concise summary line and optional body",
+		SubjectMax:  72,
+	})
+	RegisterStyle(Style{
+		Name:        "gitmoji",
+		Description: "gitmoji: emoji-prefixed summary",
+		SubjectMax:  72,
+		Header:      gitmojiPrefix,
+		Check:       checkGitmoji,
+	})
+	RegisterStyle(Style{
+		Name:         "angular",
+		Description:  "Angular: type(scope): summary with mandatory scope and BREAKING CHANGE footer",
+		SubjectMax:   72,
+		Types:        AngularTypes,
+		RequireScope: true,
+		Header:       conventionalPrefix,
+		Check:        checkAngular,
+	})
+	RegisterStyle(Style{
+		Name:        "kernel",
+		Description: "Linux kernel: subsystem: summary, wrapped body and Signed-off-by",
+		SubjectMax:  75,
+		BodyWrap:    75,
+		Header:      kernelPrefix,
+		Check:       checkKernel,
+	})
+	RegisterStyle(Style{
+		Name:        "beams",
+		Description: "Chris Beams' 50/72 rule",
+		SubjectMax:  50,
+		BodyWrap:    72,
+		Check:       checkBeams,
+	})
+}
+
+// splitMessage returns the subject line and the remaining lines of message.
+func splitMessage(message string) (string, []string) {
+	message = strings.TrimRight(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+	lines := strings.Split(message, "\n")
+	return lines[0], lines[1:]
+}
+
+// validateCommon checks the rules shared by all styles.
+func validateCommon(message string, subjectMax, bodyWrap int) []Violation {
+	if strings.TrimSpace(message) == "" {
+		return []Violation{{Rule: "empty", Message: "message is empty"}}
+	}
+	var out []Violation
+	subject, rest := splitMessage(message)
+	if isFence(subject) {
+		out = append(out, Violation{Rule: "code-fence", Message: "message is wrapped in a code fence"})
+	}
+	if strings.TrimSpace(subject) == "" {
+		out = append(out, Violation{Rule: "subject-empty", Message: "subject line is empty"})
+	}
+	if n := utf8.RuneCountInString(subject); subjectMax > 0 && n > subjectMax {
+		out = append(out, Violation{Rule: "subject-length", Message: fmt.Sprintf("subject is %d chars, max %d", n, subjectMax)})
+	}
+	if len(rest) > 0 && strings.TrimSpace(rest[0]) != "" {
+		out = append(out, Violation{Rule: "blank-line", Message: "subject must be followed by a blank line"})
+	}
+	if bodyWrap > 0 {
+		keep := keepLength(rest)
+		for i, line := range rest {
+			if utf8.RuneCountInString(line) > bodyWrap && !keep[i] {
+				out = append(out, Violation{Rule: "body-wrap", Message: fmt.Sprintf("body line %d exceeds %d chars", i+1, bodyWrap)})
+				break
+			}
+		}
+	}
+	return out
+}
+
+func checkTrailers(required []string, message string) []Violation {
+	var out []Violation
+	_, rest := splitMessage(message)
+	for _, key := range required {
+		found := false
+		for _, line := range rest {
+			if strings.HasPrefix(strings.ToLower(line), strings.ToLower(key)+":") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, Violation{Rule: "trailer", Message: fmt.Sprintf("missing %s trailer", key)})
+		}
+	}
+	return out
+}
+
+func hasBody(rest []string) bool {
+	for _, line := range rest {
+		if strings.TrimSpace(line) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func noTrailingPeriod(text string) []Violation {
+	text = strings.TrimSpace(text)
+	if strings.HasSuffix(text, ".") && !strings.HasSuffix(text, "...") {
+		return []Violation{{Rule: "subject-period", Message: "subject must not end with a period"}}
+	}
+	return nil
+}
+
+func checkConventional(s Style, message string) []Violation {
+	var out []Violation
+	subject, _ := splitMessage(message)
+	m := conventionalHeader.FindStringSubmatch(subject)
+	if m == nil {
+		return []Violation{{Rule: "subject-format", Message: "subject must look like type(scope): summary"}}
+	}
+	if len(s.Types) > 0 && !containsString(s.Types, m[1]) {
+		out = append(out, Violation{Rule: "type", Message: fmt.Sprintf("type %q is not one of %s", m[1], strings.Join(s.Types, ", "))})
+	}
+	if m[2] != "" && strings.TrimSpace(m[3]) == "" {
+		out = append(out, Violation{Rule: "scope", Message: "scope must not be empty"})
+	}
+	if len(s.Scopes) > 0 {
+		for _, scope := range strings.Split(m[3], ",") {
+			scope = strings.TrimSpace(scope)
+			if scope != "" && !containsString(s.Scopes, scope) {
+				out = append(out, Violation{Rule: "scope", Message: fmt.Sprintf("scope %q is not one of %s", scope, strings.Join(s.Scopes, ", "))})
+			}
+		}
+	}
+	if s.RequireScope && m[2] == "" {
+		out = append(out, Violation{Rule: "scope", Message: "scope is required"})
+	}
+	if strings.TrimSpace(m[5]) == "" {
+		out = append(out, Violation{Rule: "subject-empty", Message: "summary after the type is empty"})
+	}
+	return append(out, noTrailingPeriod(m[5])...)
+}
+
+func checkGitmoji(_ Style, message string) []Violation {
+	var out []Violation
+	subject, _ := splitMessage(message)
+	if !startsWithEmoji(subject) {
+		out = append(out, Violation{Rule: "subject-format", Message: "subject must start with a gitmoji followed by a space"})
+	}
+	return append(out, noTrailingPeriod(subject)...)
+}
+
+func startsWithEmoji(subject string) bool {
+	if gitmojiShortcode.MatchString(subject) {
+		return true
+	}
+	emoji := false
+	for i, r := range subject {
+		switch {
+		case unicode.Is(unicode.So, r) || r >= 0x1F000:
+			emoji = true
+		case r == '\uFE0F' || r == '\u200D':
+			// variation selector and zero-width joiner inside emoji sequences
+		default:
+			return emoji && r == ' ' && i > 0
+		}
+	}
+	return false
+}
+
+func checkAngular(s Style, message string) []Violation {
+	out := checkConventional(s, message)
+	subject, rest := splitMessage(message)
+	if m := conventionalHeader.FindStringSubmatch(subject); m != nil {
+		if r, _ := utf8.DecodeRuneInString(m[5]); unicode.IsUpper(r) {
+			out = append(out, Violation{Rule: "subject-case", Message: "summary must start with a lowercase letter"})
+		}
+		if m[1] != "docs" && !hasBody(rest) {
+			out = append(out, Violation{Rule: "body-required", Message: "body is required for non-docs commits"})
+		}
+		breaking := false
+		for _, line := range rest {
+			if strings.HasPrefix(line, "BREAKING CHANGE") {
+				breaking = true
+				if !strings.HasPrefix(line, "BREAKING CHANGE: ") {
+					out = append(out, Violation{Rule: "breaking-change", Message: "footer must read \"BREAKING CHANGE: <description>\""})
+				}
+			}
+		}
+		if m[4] == "!" && !breaking {
+			out = append(out, Violation{Rule: "breaking-change", Message: "breaking changes need a BREAKING CHANGE footer"})
+		}
+	}
+	return out
+}
+
+func checkKernel(_ Style, message string) []Violation {
+	var out []Violation
+	subject, rest := splitMessage(message)
+	m := kernelHeader.FindStringSubmatch(subject)
+	if m == nil {
+		out = append(out, Violation{Rule: "subject-format", Message: "subject must look like subsystem: summary"})
+	} else {
+		out = append(out, noTrailingPeriod(m[2])...)
+	}
+	if !hasBody(rest) {
+		out = append(out, Violation{Rule: "body-required", Message: "body describing the change is required"})
+	}
+	if !signedOffBy.MatchString(message) {
+		out = append(out, Violation{Rule: "signed-off-by", Message: "missing Signed-off-by: Name <email> trailer"})
+	}
+	return out
+}
+
+func checkBeams(_ Style, message string) []Violation {
+	var out []Violation
+	subject, _ := splitMessage(message)
+	if r, _ := utf8.DecodeRuneInString(subject); unicode.IsLower(r) {
+		out = append(out, Violation{Rule: "subject-case", Message: "subject must be capitalized"})
+	}
+	return append(out, noTrailingPeriod(subject)...)
+}
+
+func containsString(items []string, item string) bool {
+	for _, candidate := range items {
+		if candidate == item {
+			return true
+		}
+	}
+	return false
+}
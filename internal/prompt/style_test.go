@@ -0,0 +1,79 @@
+package prompt
+
+import (
+	"testing"
+)
+
+func violationRules(vs []Violation) map[string]bool {
+	rules := map[string]bool{}
+	for _, v := range vs {
+		rules[v.Rule] = true
+	}
+	return rules
+}
+
+func TestStyleValidators(t *testing.T) {
+	tests := []struct {
+		style   string
+		message string
+		want    []string
+	}{
+		{"conventional", "feat(api): add login", nil},
+		{"conventional", "feature: add login.", []string{"type", "subject-period"}},
+		{"conventional", "```\nfix: x\n```", []string{"code-fence", "subject-format", "blank-line"}},
+		{"conventional", "fix: x\nbody", []string{"blank-line"}},
+		{"freeform", "Add login", nil},
+		{"gitmoji", "✨ add login", nil},
+		{"gitmoji", ":bug: fix crash", nil},
+		{"gitmoji", "♻️ simplify parser", nil},
+		{"gitmoji", "add login", []string{"subject-format"}},
+		{"angular", "feat(auth): add login\n\nAllow users to sign in.", nil},
+		{"angular", "feat: Add login", []string{"scope", "subject-case", "body-required"}},
+		{"angular", "feat(auth)!: drop v1 tokens\n\nRemove legacy tokens.", []string{"breaking-change"}},
+		{"angular", "feat(auth)!: drop v1 tokens\n\nRemove legacy tokens.\n\nBREAKING CHANGE: v1 tokens are rejected", nil},
+		{"kernel", "net: ipv4: fix refcount leak\n\nDrop the reference on error.\n\nSigned-off-by: A B <a@b.c>", nil},
+		{"kernel", "fix refcount leak", []string{"subject-format", "body-required", "signed-off-by"}},
+		{"kernel", "net: fix leak\n\nDrop the reference on error.\n\nFixes: 1234567890ab (\"net: take a reference on the device before it is registered\")\nSigned-off-by: A B <a@b.c>", nil},
+		{"kernel", "net: fix leak\n\nDrop the reference on error.\n\n```\n" + "if (err) goto out; /* the reference taken above is dropped on the way out */" + "\n```\n\nSigned-off-by: A B <a@b.c>", nil},
+		{"beams", "Add login form\n\nExplain why.", nil},
+		{"beams", "add a login form that is much too long for fifty chars.", []string{"subject-length", "subject-case", "subject-period"}},
+	}
+	for _, tt := range tests {
+		style, ok := LookupStyle(tt.style)
+		if !ok {
+			t.Fatalf("style %s not registered", tt.style)
+		}
+		got := violationRules(style.Validate(tt.message))
+		if len(got) != len(tt.want) {
+			t.Errorf("%s %q: got %v, want %v", tt.style, tt.message, got, tt.want)
+			continue
+		}
+		for _, rule := range tt.want {
+			if !got[rule] {
+				t.Errorf("%s %q: missing %s, got %v", tt.style, tt.message, rule, got)
+			}
+		}
+	}
+}
+
+func TestEveryStyleHasTemplate(t *testing.T) {
+	for _, name := range StyleNames() {
+		if _, err := LoadTemplate(name, TemplateSource{}, TemplateSource{}); err != nil {
+			t.Errorf("style %s: %v", name, err)
+		}
+	}
+}
+
+func TestStyleScopesAndTrailers(t *testing.T) {
+	style, _ := LookupStyle("conventional")
+	style.Scopes = []string{"api", "ui"}
+	style.RequiredTrailers = []string{"Signed-off-by"}
+
+	got := violationRules(style.Validate("feat(db): add index"))
+	if !got["scope"] || !got["trailer"] {
+		t.Fatalf("expected scope and trailer violations, got %v", got)
+	}
+	if vs := style.Validate("feat(api): add index\n\nSigned-off-by: A <a@b.c>"); len(vs) > 0 {
+		t.Fatalf("expected no violations, got %v", vs)
+	}
+}
@@ -0,0 +1,230 @@
+package prompt
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/MenschMachine/gommit/internal/git"
+	"github.com/MenschMachine/gommit/internal/goapi"
+	"github.com/MenschMachine/gommit/internal/symbols"
+)
+
+//go:embed templates/*.tmpl
+var builtinFS embed.FS
+
+// diffPlaceholder stands in for the diff while measuring how much of the
+// prompt budget the rest of the template consumes.
+const diffPlaceholder = "\x00gommit-diff\x00"
+
+// Data is the value user and system templates are executed against.
+type Data struct {
+	Diff          string
+	Files         []string
+	Binaries      []git.BinaryFile
+	Truncated     []string
+	Changes       []Change
+	Symbols       []symbols.File
+	GoAPI         *goapi.Report
+	Scope         string
+	Branch        string
+	Author        string
+	Types         []string
+	CommitScopes  []string
+	AllowedScopes []string
+	RecentCommits []string
+	Hint          string
+	MaxChars      int
+	// Generated lists changed files whose new content carries the
+	// generated code marker, which their hunks may not show.
+	Generated []string
+}
+
+// TemplateSource is a user-supplied template given inline or as a file path.
+// Inline takes precedence when both are set.
+type TemplateSource struct {
+	Inline string
+	File   string
+}
+
+func (s TemplateSource) text() (string, bool, error) {
+	if s.Inline != "" {
+		return s.Inline, true, nil
+	}
+	if s.File == "" {
+		return "", false, nil
+	}
+	data, err := os.ReadFile(s.File)
+	if err != nil {
+		return "", false, fmt.Errorf("reading template: %w", err)
+	}
+	return string(data), true, nil
+}
+
+type Template struct {
+	Name  string
+	Style Style
+	// Important holds globs of files whose diffs get detail first when the
+	// prompt has a budget.
+	Important []string
+	tmpl      *template.Template
+}
+
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"size": func(size int64) string {
+		if size < 0 {
+			return "unknown"
+		}
+		return fmt.Sprintf("%d bytes", size)
+	},
+	"changeTable": changeTable,
+	"has":         slices.Contains[[]string],
+}
+
+// BuiltinTemplates returns the names of the templates shipped with gommit.
+func BuiltinTemplates() []string {
+	entries, _ := builtinFS.ReadDir("templates")
+	var names []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".tmpl")
+		if name == "common" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuiltinTemplateSource returns the raw text of a built-in template.
+func BuiltinTemplateSource(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	data, err := builtinFS.ReadFile("templates/" + name + ".tmpl")
+	if err != nil {
+		return "", fmt.Errorf("unknown template %q (available: %s)", name, strings.Join(BuiltinTemplates(), ", "))
+	}
+	return string(data), nil
+}
+
+// LoadTemplate returns the built-in template for styleName, with its system
+// and user parts replaced by the given sources when they are set.
+func LoadTemplate(styleName string, system, user TemplateSource) (*Template, error) {
+	name := strings.ToLower(strings.TrimSpace(styleName))
+	style, ok := LookupStyle(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown style %q (available: %s)", name, strings.Join(StyleNames(), ", "))
+	}
+	base, err := BuiltinTemplateSource(name)
+	if err != nil {
+		return nil, err
+	}
+	common, err := builtinFS.ReadFile("templates/common.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := template.New(name).Funcs(templateFuncs)
+	if _, err := tmpl.Parse(string(common)); err != nil {
+		return nil, err
+	}
+	if _, err := tmpl.Parse(base); err != nil {
+		return nil, fmt.Errorf("template %s: %w", name, err)
+	}
+	if err := overrideTemplate(tmpl, "system", system); err != nil {
+		return nil, err
+	}
+	if err := overrideTemplate(tmpl, "user", user); err != nil {
+		return nil, err
+	}
+	return &Template{Name: name, Style: style, tmpl: tmpl}, nil
+}
+
+func overrideTemplate(tmpl *template.Template, name string, src TemplateSource) error {
+	text, ok, err := src.text()
+	if err != nil || !ok {
+		return err
+	}
+	if _, err := tmpl.New(name).Parse(text); err != nil {
+		return fmt.Errorf("%s template: %w", name, err)
+	}
+	return nil
+}
+
+// Render executes the system and user templates. When maxChars is positive
+// the diff is reduced to fit whatever budget the rest of the user template
+// leaves over.
+func (t *Template) Render(data Data, maxChars int) (string, string, error) {
+	system, user, _, err := t.RenderDetail(data, maxChars)
+	return system, user, err
+}
+
+// RenderDetail is Render that also reports the detail level each file of
+// the diff was reduced to. Details are nil when the diff was not reduced.
+func (t *Template) RenderDetail(data Data, maxChars int) (string, string, []FileDetail, error) {
+	sort.Strings(data.Truncated)
+	sort.Slice(data.Binaries, func(i, j int) bool { return data.Binaries[i].Path < data.Binaries[j].Path })
+	chunks := parseDiffChunks(data.Diff)
+	if data.Files == nil {
+		data.Files = collectFiles(chunks, data.Binaries)
+	}
+	if data.Changes == nil {
+		data.Changes = collectChanges(chunks, data.Binaries)
+	}
+	if data.Types == nil {
+		data.Types = t.Style.Types
+	}
+	if data.AllowedScopes == nil {
+		data.AllowedScopes = t.Style.Scopes
+	}
+	if maxChars < 0 {
+		maxChars = 0
+	}
+	data.MaxChars = maxChars
+
+	system, err := t.execute("system", data)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	if maxChars == 0 {
+		user, err := t.execute("user", data)
+		return system, user, nil, err
+	}
+
+	diff := data.Diff
+	data.Diff = diffPlaceholder
+	rendered, err := t.execute("user", data)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if !strings.Contains(rendered, diffPlaceholder) {
+		data.Diff = diff
+		user, err := t.execute("user", data)
+		return system, trimToMax(user, maxChars), nil, err
+	}
+
+	// A template may show the diff more than once; each copy gets an equal
+	// share of the budget.
+	copies := strings.Count(rendered, diffPlaceholder)
+	diffBudget := (maxChars - (len(rendered) - copies*len(diffPlaceholder))) / copies
+	for i, chunk := range chunks {
+		chunks[i].Generated = slices.Contains(data.Generated, chunk.Path)
+	}
+	diffBody, details := buildDiffWithBudget(chunks, max(diffBudget, 0), t.Important)
+	user := strings.ReplaceAll(rendered, diffPlaceholder, diffBody)
+	return system, trimToMax(user, maxChars), details, nil
+}
+
+func (t *Template) execute(name string, data Data) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("rendering %s template: %w", name, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,246 @@
+// Package rpc implements a small JSON-RPC 2.0 server over newline-delimited
+// JSON, as spoken by `gommit serve --stdio`.
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Standard JSON-RPC error codes, plus RequestCancelled as used by LSP.
+const (
+	ParseError       = -32700
+	InvalidRequest   = -32600
+	MethodNotFound   = -32601
+	InvalidParams    = -32602
+	InternalError    = -32603
+	ServerError      = -32000
+	RequestCancelled = -32800
+)
+
+// Error is a JSON-RPC error object. Handlers may return one to control the
+// code; any other error is reported as InternalError.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Notify sends a notification to the client.
+type Notify func(method string, params any)
+
+// Handler serves one method. The request id is available from ctx via
+// RequestID; ctx is cancelled by the "cancel" method or when the server
+// stops.
+type Handler func(ctx context.Context, params json.RawMessage, notify Notify) (any, error)
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type idKey struct{}
+
+// RequestID returns the id of the request being handled, or nil for a
+// notification.
+func RequestID(ctx context.Context) json.RawMessage {
+	id, _ := ctx.Value(idKey{}).(json.RawMessage)
+	return id
+}
+
+// Server dispatches requests to registered handlers. Requests run
+// concurrently; "cancel" (or LSP's "$/cancelRequest") with {"id": ...} or
+// MCP's "notifications/cancelled" with {"requestId": ...} cancels one, and
+// "exit" stops the server.
+type Server struct {
+	handlers map[string]Handler
+
+	writeMu sync.Mutex
+	out     *json.Encoder
+
+	mu       sync.Mutex
+	inflight map[string]context.CancelFunc
+}
+
+func NewServer() *Server {
+	return &Server{handlers: map[string]Handler{}, inflight: map[string]context.CancelFunc{}}
+}
+
+// Handle registers h for method.
+func (s *Server) Handle(method string, h Handler) {
+	s.handlers[method] = h
+}
+
+// Serve reads requests from r until EOF, ctx is done or an "exit"
+// notification arrives, then cancels and waits for running requests.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	// Stop running requests before waiting for them.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	s.out = json.NewEncoder(w)
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(r)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case line := <-lines:
+			if exit := s.dispatch(ctx, line, &wg); exit {
+				return nil
+			}
+		}
+	}
+}
+
+func (s *Server) dispatch(ctx context.Context, line []byte, wg *sync.WaitGroup) (exit bool) {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return false
+	}
+	var req message
+	if err := json.Unmarshal(line, &req); err != nil {
+		s.reply(nil, nil, &Error{Code: ParseError, Message: err.Error()})
+		return false
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		s.reply(req.ID, nil, &Error{Code: InvalidRequest, Message: "invalid request"})
+		return false
+	}
+
+	switch req.Method {
+	case "exit":
+		return true
+	case "cancel", "$/cancelRequest", "notifications/cancelled":
+		var params struct {
+			ID        json.RawMessage `json:"id"`
+			RequestID json.RawMessage `json:"requestId"`
+		}
+		err := json.Unmarshal(req.Params, &params)
+		if params.ID == nil {
+			params.ID = params.RequestID
+		}
+		if err != nil || params.ID == nil {
+			if req.ID != nil {
+				s.reply(req.ID, nil, &Error{Code: InvalidParams, Message: "cancel needs {\"id\": ...}"})
+			}
+			return false
+		}
+		s.mu.Lock()
+		cancel, ok := s.inflight[string(params.ID)]
+		s.mu.Unlock()
+		if ok {
+			cancel()
+		}
+		if req.ID != nil {
+			s.reply(req.ID, map[string]bool{"cancelled": ok}, nil)
+		}
+		return false
+	}
+
+	h, ok := s.handlers[req.Method]
+	if !ok {
+		// Unknown notifications are ignored, as the spec requires.
+		if req.ID != nil {
+			s.reply(req.ID, nil, &Error{Code: MethodNotFound, Message: "method not found: " + req.Method})
+		}
+		return false
+	}
+
+	reqCtx, cancel := context.WithCancel(context.WithValue(ctx, idKey{}, req.ID))
+	if req.ID != nil {
+		s.mu.Lock()
+		s.inflight[string(req.ID)] = cancel
+		s.mu.Unlock()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			cancel()
+			if req.ID != nil {
+				s.mu.Lock()
+				delete(s.inflight, string(req.ID))
+				s.mu.Unlock()
+			}
+		}()
+		result, err := h(reqCtx, req.Params, s.notify)
+		if req.ID == nil {
+			return
+		}
+		if err != nil {
+			s.reply(req.ID, nil, toError(reqCtx, err))
+			return
+		}
+		s.reply(req.ID, result, nil)
+	}()
+	return false
+}
+
+func toError(ctx context.Context, err error) *Error {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		return &Error{Code: RequestCancelled, Message: "request cancelled"}
+	}
+	return &Error{Code: InternalError, Message: err.Error()}
+}
+
+func (s *Server) notify(method string, params any) {
+	s.write(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (s *Server) reply(id json.RawMessage, result any, rpcErr *Error) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	resp := map[string]any{"jsonrpc": "2.0", "id": id}
+	if rpcErr != nil {
+		resp["error"] = rpcErr
+	} else {
+		resp["result"] = result
+	}
+	s.write(resp)
+}
+
+func (s *Server) write(v any) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	// Nothing sensible can be done if the client has gone away.
+	_ = s.out.Encode(v)
+}
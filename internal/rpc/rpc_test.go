@@ -0,0 +1,156 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer lets the test read output while the server writes it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) lines() []map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		_ = json.Unmarshal([]byte(line), &m)
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestServe(t *testing.T) {
+	srv := NewServer()
+	srv.Handle("echo", func(ctx context.Context, params json.RawMessage, notify Notify) (any, error) {
+		notify("progress", map[string]any{"id": RequestID(ctx)})
+		var p struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &Error{Code: InvalidParams, Message: err.Error()}
+		}
+		return p.Text, nil
+	})
+
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"echo","params":{"text":"hi"}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"missing"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"echo","params":[]}`,
+		`not json`,
+		`{"jsonrpc":"2.0","method":"missing"}`,
+		`{"jsonrpc":"2.0","method":"cancel","params":{"id":42}}`,
+	}, "\n") + "\n"
+	var out syncBuffer
+	if err := srv.Serve(context.Background(), strings.NewReader(in), &out); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+
+	byID := map[float64]map[string]any{}
+	notifications, replies := 0, 0
+	for _, m := range out.lines() {
+		if m["method"] == "progress" {
+			notifications++
+			continue
+		}
+		replies++
+		id, _ := m["id"].(float64)
+		byID[id] = m
+	}
+	if notifications != 2 {
+		t.Errorf("notifications = %d, want 2", notifications)
+	}
+	// Notifications get no reply, not even an error.
+	if replies != 4 {
+		t.Errorf("replies = %d, want 4", replies)
+	}
+	if byID[1]["result"] != "hi" {
+		t.Errorf("echo result = %v", byID[1])
+	}
+	if code := errorCodeOf(byID[2]); code != MethodNotFound {
+		t.Errorf("missing method code = %v", code)
+	}
+	if code := errorCodeOf(byID[3]); code != InvalidParams {
+		t.Errorf("bad params code = %v", code)
+	}
+	if code := errorCodeOf(byID[0]); code != ParseError {
+		t.Errorf("parse error code = %v", code)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		name   string
+		cancel string
+	}{
+		{"cancel", `{"jsonrpc":"2.0","id":"b","method":"cancel","params":{"id":"a"}}`},
+		{"mcp", `{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":"a"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer()
+			started := make(chan struct{})
+			srv.Handle("wait", func(ctx context.Context, params json.RawMessage, notify Notify) (any, error) {
+				close(started)
+				<-ctx.Done()
+				return nil, ctx.Err()
+			})
+
+			r, w := io.Pipe()
+			var out syncBuffer
+			done := make(chan error)
+			go func() { done <- srv.Serve(context.Background(), r, &out) }()
+
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":"a","method":"wait"}` + "\n"))
+			<-started
+			_, _ = w.Write([]byte(tt.cancel + "\n"))
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","method":"exit"}` + "\n"))
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("Serve: %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Serve did not exit")
+			}
+			_ = w.Close()
+
+			var cancelled bool
+			for _, m := range out.lines() {
+				if m["id"] == "a" && errorCodeOf(m) == RequestCancelled {
+					cancelled = true
+				}
+			}
+			if !cancelled {
+				t.Fatalf("request a was not reported as cancelled: %v", out.lines())
+			}
+		})
+	}
+}
+
+func errorCodeOf(m map[string]any) int {
+	e, ok := m["error"].(map[string]any)
+	if !ok {
+		return 0
+	}
+	code, _ := e["code"].(float64)
+	return int(code)
+}
@@ -0,0 +1,172 @@
+// Package scopes infers Conventional Commit scopes from changed file paths.
+package scopes
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Derive modes used when no rule matches a file.
+const (
+	DeriveNone        = ""
+	DeriveDir         = "dir"
+	DeriveGoPackage   = "go-package"
+	DerivePackageJSON = "package-json"
+)
+
+// DeriveModes are the derive modes besides DeriveNone.
+var DeriveModes = []string{DeriveDir, DeriveGoPackage, DerivePackageJSON}
+
+// Rule maps files matching Glob to Scope. Globs are slash-separated and
+// relative to the repository root; "**" matches any number of directories.
+type Rule struct {
+	Glob  string
+	Scope string
+}
+
+type Options struct {
+	Rules  []Rule
+	Derive string
+}
+
+// Infer returns the sorted, de-duplicated scopes for files. Each file takes
+// the scope of the first matching rule, falling back to opts.Derive.
+func Infer(root string, files []string, opts Options) []string {
+	seen := map[string]struct{}{}
+	var out []string
+	for _, file := range files {
+		file = filepath.ToSlash(file)
+		scope := ""
+		for _, rule := range opts.Rules {
+			if Match(rule.Glob, file) {
+				scope = rule.Scope
+				break
+			}
+		}
+		if scope == "" {
+			scope = derive(root, file, opts.Derive)
+		}
+		if scope == "" {
+			continue
+		}
+		if _, ok := seen[scope]; ok {
+			continue
+		}
+		seen[scope] = struct{}{}
+		out = append(out, scope)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Names returns the scopes named by rules, in rule order.
+func Names(rules []Rule) []string {
+	seen := map[string]struct{}{}
+	var out []string
+	for _, rule := range rules {
+		if rule.Scope == "" {
+			continue
+		}
+		if _, ok := seen[rule.Scope]; ok {
+			continue
+		}
+		seen[rule.Scope] = struct{}{}
+		out = append(out, rule.Scope)
+	}
+	return out
+}
+
+// Match reports whether name matches the slash-separated glob pattern.
+func Match(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		ok, err := path.Match(pattern[0], parts[0])
+		if err != nil || !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+func derive(root, file, mode string) string {
+	switch mode {
+	case DeriveDir:
+		dir, _, ok := strings.Cut(file, "/")
+		if !ok {
+			return ""
+		}
+		return dir
+	case DeriveGoPackage:
+		return goPackage(root, file)
+	case DerivePackageJSON:
+		return packageJSONName(root, file)
+	default:
+		return ""
+	}
+}
+
+// goPackage returns the package name declared by a Go file, or the name of
+// its directory when the file cannot be parsed (for example when deleted).
+func goPackage(root, file string) string {
+	if !strings.HasSuffix(file, ".go") {
+		return ""
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(root, filepath.FromSlash(file)), nil, parser.PackageClauseOnly)
+	if err == nil {
+		return strings.TrimSuffix(f.Name.Name, "_test")
+	}
+	dir := path.Dir(file)
+	if dir == "." {
+		return ""
+	}
+	return path.Base(dir)
+}
+
+// packageJSONName returns the name from the nearest package.json between
+// the file and the repository root, without any @org/ prefix.
+func packageJSONName(root, file string) string {
+	dir := path.Dir(file)
+	for {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(dir), "package.json"))
+		if err == nil {
+			var pkg struct {
+				Name string `json:"name"`
+			}
+			if json.Unmarshal(data, &pkg) == nil && pkg.Name != "" {
+				name := pkg.Name
+				if i := strings.LastIndex(name, "/"); i >= 0 {
+					name = name[i+1:]
+				}
+				return name
+			}
+		}
+		if dir == "." || dir == "/" {
+			return ""
+		}
+		dir = path.Dir(dir)
+	}
+}
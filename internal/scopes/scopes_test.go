@@ -0,0 +1,66 @@
+package scopes
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"internal/git/**", "internal/git/diff.go", true},
+		{"internal/git/**", "internal/gitx/diff.go", false},
+		{"**/*.md", "README.md", true},
+		{"**/*.md", "docs/a/b.md", true},
+		{"cmd/*/main.go", "cmd/tool/main.go", true},
+		{"cmd/*/main.go", "cmd/a/b/main.go", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %t, want %t", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInferRulesThenDerive(t *testing.T) {
+	opts := Options{
+		Rules:  []Rule{{Glob: "**/*.md", Scope: "docs"}},
+		Derive: DeriveDir,
+	}
+	got := Infer("", []string{"README.md", "internal/git/diff.go", "main.go", "internal/ui/a.go"}, opts)
+	want := []string{"docs", "internal"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Infer() = %v, want %v", got, want)
+	}
+}
+
+func TestInferGoPackageAndPackageJSON(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("internal/llm/client.go", "package llm\n")
+	write("internal/llm/client_test.go", "package llm_test\n")
+	write("web/app/package.json", `{"name": "@acme/app"}`)
+	write("web/app/src/index.js", "")
+
+	got := Infer(root, []string{"internal/llm/client.go", "internal/llm/client_test.go", "internal/gone/gone.go"}, Options{Derive: DeriveGoPackage})
+	if want := []string{"gone", "llm"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("go-package Infer() = %v, want %v", got, want)
+	}
+	got = Infer(root, []string{"web/app/src/index.js"}, Options{Derive: DerivePackageJSON})
+	if want := []string{"app"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("package-json Infer() = %v, want %v", got, want)
+	}
+}
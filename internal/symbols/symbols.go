@@ -0,0 +1,277 @@
+// Package symbols reports which functions, types and methods a change
+// adds, removes or modifies.
+package symbols
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/MenschMachine/gommit/internal/scopes"
+)
+
+// Actions of a Change.
+const (
+	Added    = "added"
+	Removed  = "removed"
+	Modified = "modified"
+)
+
+// Change is a declaration the change added, removed or modified.
+type Change struct {
+	Action string
+	Kind   string
+	Name   string
+}
+
+// File lists the symbol changes of one file.
+type File struct {
+	Path    string
+	Changes []Change
+}
+
+// Summary describes the changes grouped by action, e.g.
+// "added func New; modified method Client.Do".
+func (f File) Summary() string {
+	var parts []string
+	for _, action := range []string{Added, Modified, Removed} {
+		var names []string
+		for _, c := range f.Changes {
+			if c.Action == action {
+				names = append(names, c.Kind+" "+c.Name)
+			}
+		}
+		if len(names) > 0 {
+			parts = append(parts, action+" "+strings.Join(names, ", "))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Rule finds declarations of Kind in files matching Glob, one line at a
+// time. The group named "name" of Pattern, or else its first group, is the
+// symbol name.
+type Rule struct {
+	Glob    string
+	Kind    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultRules cover common languages besides Go, and Go files that do not
+// parse.
+var DefaultRules = []Rule{
+	rule("**/*.go", "func", `^func\s+(?:\([^)]*\)\s*)?(\w+)`),
+	rule("**/*.go", "type", `^type\s+(\w+)`),
+	rule("**/*.py", "class", `^\s*class\s+(\w+)`),
+	rule("**/*.py", "def", `^\s*(?:async\s+)?def\s+(\w+)`),
+	rule("**/*.rb", "class", `^\s*(?:class|module)\s+([\w:]+)`),
+	rule("**/*.rb", "def", `^\s*def\s+(?:self\.)?(\w+[?!=]?)`),
+	rule("**/*.rs", "fn", `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?fn\s+(\w+)`),
+	rule("**/*.rs", "type", `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:struct|enum|trait|type|union)\s+(\w+)`),
+	rule("**/*.java", "class", `^\s*(?:(?:public|protected|private|abstract|static|final|sealed)\s+)*(?:class|interface|enum|record)\s+(\w+)`),
+	rule("**/*.java", "method", `^\s*(?:(?:public|protected|private|abstract|static|final|synchronized)\s+)+[\w<>\[\], ?]+\s+(\w+)\s*\(`),
+	rule("**/*.sh", "function", `^\s*(?:function\s+)?([\w-]+)\s*\(\)`),
+}
+
+func init() {
+	for _, ext := range []string{"js", "jsx", "mjs", "cjs", "ts", "tsx"} {
+		DefaultRules = append(DefaultRules,
+			rule("**/*."+ext, "class", `^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(\w+)`),
+			rule("**/*."+ext, "function", `^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(\w+)`),
+		)
+	}
+}
+
+func rule(glob, kind, pattern string) Rule {
+	return Rule{Glob: glob, Kind: kind, Pattern: regexp.MustCompile(pattern)}
+}
+
+// Supported reports whether Diff can find declarations in file.
+func Supported(file string, rules []Rule) bool {
+	return path.Ext(file) == ".go" || len(matchingRules(file, rules)) > 0
+}
+
+// Diff compares the declarations in the old and new content of file; nil
+// content stands for a file that does not exist on that side. Go files are
+// parsed, other files are searched with the first rules whose glob matches.
+func Diff(file string, old, new []byte, rules []Rule) []Change {
+	oldSyms := extract(file, old, rules)
+	newSyms := extract(file, new, rules)
+	oldText := map[string]string{}
+	for _, s := range oldSyms {
+		oldText[s.key()] = s.text
+	}
+	newKeys := map[string]bool{}
+	var changes []Change
+	for _, s := range newSyms {
+		newKeys[s.key()] = true
+		text, ok := oldText[s.key()]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Action: Added, Kind: s.kind, Name: s.name})
+		case text != s.text:
+			changes = append(changes, Change{Action: Modified, Kind: s.kind, Name: s.name})
+		}
+	}
+	for _, s := range oldSyms {
+		if !newKeys[s.key()] {
+			changes = append(changes, Change{Action: Removed, Kind: s.kind, Name: s.name})
+		}
+	}
+	return changes
+}
+
+// symbol is a declaration and the source it spans.
+type symbol struct {
+	kind, name string
+	// nth tells apart declarations with the same kind and name, such as
+	// several init functions.
+	nth  int
+	text string
+}
+
+func (s symbol) key() string {
+	return s.kind + " " + s.name + "#" + strconv.Itoa(s.nth)
+}
+
+func extract(file string, src []byte, rules []Rule) []symbol {
+	if src == nil {
+		return nil
+	}
+	var syms []symbol
+	if path.Ext(file) == ".go" {
+		var err error
+		if syms, err = goSymbols(src); err != nil {
+			syms = ruleSymbols(src, matchingRules(file, DefaultRules))
+		}
+	} else {
+		syms = ruleSymbols(src, matchingRules(file, rules))
+	}
+	seen := map[string]int{}
+	for i := range syms {
+		k := syms[i].kind + " " + syms[i].name
+		syms[i].nth = seen[k]
+		seen[k]++
+	}
+	return syms
+}
+
+// matchingRules returns the rules of the first glob in rules that matches
+// file, so configured rules replace the built-in ones for their files.
+func matchingRules(file string, rules []Rule) []Rule {
+	var out []Rule
+	for _, r := range rules {
+		if len(out) > 0 && r.Glob != out[0].Glob {
+			break
+		}
+		if len(out) > 0 || scopes.Match(r.Glob, file) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func goSymbols(src []byte) ([]symbol, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	var syms []symbol
+	add := func(kind, name string, node ast.Node) {
+		start, end := fset.Position(node.Pos()).Offset, fset.Position(node.End()).Offset
+		syms = append(syms, symbol{kind: kind, name: name, text: string(src[start:end])})
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				add("method", receiverType(d.Recv.List[0].Type)+"."+d.Name.Name, d)
+			} else {
+				add("func", d.Name.Name, d)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok {
+					add("type", ts.Name.Name, ts)
+				}
+			}
+		}
+	}
+	return syms, nil
+}
+
+func receiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(t.X)
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// ruleSymbols finds declarations line by line. Each one spans up to the
+// next; one indented below another is named after it, e.g. "Client.get".
+func ruleSymbols(src []byte, rules []Rule) []symbol {
+	if len(rules) == 0 {
+		return nil
+	}
+	type open struct {
+		indent int
+		name   string
+	}
+	var syms []symbol
+	var stack []open
+	var body []string
+	flush := func() {
+		if len(syms) > 0 {
+			syms[len(syms)-1].text = strings.Join(body, "\n")
+		}
+		body = body[:0]
+	}
+	for _, line := range strings.Split(string(src), "\n") {
+		kind, name := matchLine(line, rules)
+		if name == "" {
+			body = append(body, line)
+			continue
+		}
+		flush()
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 {
+			name = stack[len(stack)-1].name + "." + name
+		}
+		stack = append(stack, open{indent: indent, name: name})
+		syms = append(syms, symbol{kind: kind, name: name})
+		body = append(body, line)
+	}
+	flush()
+	return syms
+}
+
+func matchLine(line string, rules []Rule) (kind, name string) {
+	for _, r := range rules {
+		m := r.Pattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if i := r.Pattern.SubexpIndex("name"); i > 0 {
+			return r.Kind, m[i]
+		}
+		if len(m) > 1 {
+			return r.Kind, m[1]
+		}
+	}
+	return "", ""
+}
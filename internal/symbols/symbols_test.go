@@ -0,0 +1,95 @@
+package symbols
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		old, new string
+		rules    []Rule
+		want     string
+	}{
+		{
+			name: "go",
+			file: "a.go",
+			old:  "package a\n\ntype T struct{}\n\nfunc (t *T) Do() {}\n\nfunc Old() {}\n\nfunc init() {}\n",
+			new:  "package a\n\ntype T struct{ n int }\n\nfunc (t *T) Do() {}\n\nfunc New[K any]() {}\n\nfunc init() {}\n\nfunc init() {}\n",
+			want: "added func New, func init; modified type T; removed func Old",
+		},
+		{
+			name: "generic receiver",
+			file: "a.go",
+			old:  "package a\n\nfunc (l *List[T]) Push(v T) {}\n",
+			new:  "package a\n\nfunc (l *List[T]) Push(v T) { l.n++ }\n",
+			want: "modified method List.Push",
+		},
+		{
+			name: "go that does not parse",
+			file: "a.go",
+			old:  "package a\n\nfunc A() {}\n",
+			new:  "package a\n\nfunc A() {\n\nfunc B() {}\n",
+			want: "added func B; modified func A",
+		},
+		{
+			name: "added file",
+			file: "pkg/b.go",
+			new:  "package b\n\ntype B int\n",
+			want: "added type B",
+		},
+		{
+			name:  "python nesting",
+			file:  "s.py",
+			old:   "class Client:\n    def get(self):\n        return 1\n\ndef helper():\n    pass\n",
+			new:   "class Client:\n    def get(self):\n        return 2\n\n    def post(self):\n        pass\n",
+			rules: DefaultRules,
+			want:  "added def Client.post; modified def Client.get; removed def helper",
+		},
+		{
+			name:  "configured rule replaces the built-in ones",
+			file:  "lib/x.py",
+			old:   "def a():\n    pass\n",
+			new:   "def a():\n    return 1\n",
+			rules: append([]Rule{{Glob: "lib/**", Kind: "task", Pattern: regexp.MustCompile(`^@task\s+(?P<name>\w+)`)}}, DefaultRules...),
+			want:  "",
+		},
+		{
+			name: "unknown language",
+			file: "notes.txt",
+			old:  "def a():\n",
+			new:  "def b():\n",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var old, new []byte
+			if tt.old != "" {
+				old = []byte(tt.old)
+			}
+			if tt.new != "" {
+				new = []byte(tt.new)
+			}
+			got := File{Changes: Diff(tt.file, old, new, tt.rules)}.Summary()
+			if got != tt.want {
+				t.Fatalf("Diff = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleSymbolsNameGroup(t *testing.T) {
+	rules := []Rule{{Glob: "**/*.tasks", Kind: "task", Pattern: regexp.MustCompile(`^(@)task\s+(?P<name>\w+)`)}}
+	got := Diff("ci/build.tasks", nil, []byte("@task lint\n  run\n@task test\n"), rules)
+	want := []Change{{Action: Added, Kind: "task", Name: "lint"}, {Action: Added, Kind: "task", Name: "test"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diff = %+v, want %+v", got, want)
+	}
+	if !Supported("ci/build.tasks", rules) || Supported("ci/build.yml", rules) || !Supported("x.go", nil) {
+		t.Fatal("Supported does not follow the rules")
+	}
+}
@@ -2,6 +2,8 @@ package ui
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -13,10 +15,18 @@ import (
 	"golang.org/x/term"
 )
 
-func EditInEditor(initial string) (string, error) {
+// ErrInterrupted is returned when the user presses Ctrl-C in a prompt or
+// while editing.
+var ErrInterrupted = errors.New("interrupted")
+
+// EditInEditor opens initial in $EDITOR, or reads a message from stdin
+// when it is unset. The editor is left running when ctx is cancelled, as it
+// owns the terminal; a Ctrl-C it does not handle itself ends the edit with
+// ErrInterrupted.
+func EditInEditor(ctx context.Context, initial string) (string, error) {
 	editor := strings.TrimSpace(os.Getenv("EDITOR"))
 	if editor == "" {
-		return InlineEdit(initial)
+		return InlineEdit(ctx, initial)
 	}
 
 	tmpDir := os.TempDir()
@@ -29,7 +39,11 @@ func EditInEditor(initial string) (string, error) {
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return "", ErrInterrupted
+	}
+	if err != nil {
 		return "", err
 	}
 
@@ -40,14 +54,34 @@ func EditInEditor(initial string) (string, error) {
 	return strings.TrimSpace(string(data)), nil
 }
 
-func InlineEdit(initial string) (string, error) {
+// InlineEdit reads a message from stdin until EOF. Cancelling ctx returns
+// ErrInterrupted without waiting for the input.
+func InlineEdit(ctx context.Context, initial string) (string, error) {
 	fmt.Println("Enter commit message. End with EOF (Ctrl-D).")
 	fmt.Println("---")
 	if initial != "" {
 		fmt.Println(initial)
 		fmt.Println("---")
 	}
-	reader := bufio.NewReader(os.Stdin)
+	type result struct {
+		text string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		text, err := readMessage(os.Stdin)
+		done <- result{text, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return "", ErrInterrupted
+	case r := <-done:
+		return r.text, r.err
+	}
+}
+
+func readMessage(r io.Reader) (string, error) {
+	reader := bufio.NewReader(r)
 	var lines []string
 	for {
 		line, err := reader.ReadString('\n')
@@ -1,6 +1,18 @@
 package ui
 
-import "github.com/charmbracelet/huh"
+import (
+	"errors"
+
+	"github.com/charmbracelet/huh"
+)
+
+// interrupted maps huh's Ctrl-C error to ErrInterrupted.
+func interrupted(err error) error {
+	if errors.Is(err, huh.ErrUserAborted) {
+		return ErrInterrupted
+	}
+	return err
+}
 
 // SelectOption presents an interactive arrow-key menu
 func SelectOption(prompt string, options []string) (string, error) {
@@ -17,7 +29,7 @@ func SelectOption(prompt string, options []string) (string, error) {
 		Value(&selected).
 		Run()
 
-	return selected, err
+	return selected, interrupted(err)
 }
 
 // PromptInput presents an interactive text input
@@ -30,5 +42,18 @@ func PromptInput(prompt, placeholder string) (string, error) {
 		Value(&input).
 		Run()
 
-	return input, err
+	return input, interrupted(err)
+}
+
+// PromptSecret presents an interactive input with masked echo
+func PromptSecret(prompt string) (string, error) {
+	var input string
+
+	err := huh.NewInput().
+		Title(prompt).
+		EchoMode(huh.EchoModePassword).
+		Value(&input).
+		Run()
+
+	return input, interrupted(err)
 }
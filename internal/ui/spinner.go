@@ -1,22 +1,28 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Spinner struct {
-	writer  io.Writer
-	text    string
-	stopCh  chan struct{}
-	doneCh  chan struct{}
-	enabled bool
+	writer   io.Writer
+	text     string
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	doneCh   chan struct{}
+	enabled  bool
 }
 
-func StartSpinner(writer io.Writer, text string) *Spinner {
+// StartSpinner shows text with a spinner on terminals until Stop is called
+// or ctx is cancelled, whichever comes first. The line is cleared either
+// way, so an interrupted run does not leave the cursor behind the spinner.
+func StartSpinner(ctx context.Context, writer io.Writer, text string) *Spinner {
 	s := &Spinner{
 		writer: writer,
 		text:   text,
@@ -30,7 +36,7 @@ func StartSpinner(writer io.Writer, text string) *Spinner {
 	}
 
 	s.enabled = true
-	go s.spin()
+	go s.spin(ctx)
 	return s
 }
 
@@ -38,14 +44,13 @@ func (s *Spinner) Stop() {
 	if !s.enabled {
 		return
 	}
-	close(s.stopCh)
+	s.stopOnce.Do(func() { close(s.stopCh) })
 	<-s.doneCh
-	clearLen := len(s.text) + 2
-	fmt.Fprintf(s.writer, "\r%s\r", strings.Repeat(" ", clearLen))
 }
 
-func (s *Spinner) spin() {
+func (s *Spinner) spin(ctx context.Context) {
 	defer close(s.doneCh)
+	defer fmt.Fprintf(s.writer, "\r%s\r", strings.Repeat(" ", len(s.text)+2))
 	frames := []rune{'|', '/', '-', '\\'}
 	ticker := time.NewTicker(120 * time.Millisecond)
 	defer ticker.Stop()
@@ -54,6 +59,8 @@ func (s *Spinner) spin() {
 		select {
 		case <-s.stopCh:
 			return
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
 			fmt.Fprintf(s.writer, "\r%c %s", frames[i%len(frames)], s.text)
 			i++
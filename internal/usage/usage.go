@@ -0,0 +1,219 @@
+// Package usage keeps a ledger of token usage per provider, model and day
+// and prices it from the configured price table.
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/llm"
+)
+
+const dayLayout = "2006-01-02"
+
+// Record is the usage of one provider and model on one day. Day is empty
+// in summaries spanning several days.
+type Record struct {
+	Day      string `json:"day,omitempty"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Requests int    `json:"requests"`
+	llm.Usage
+}
+
+// Ledger is a JSON file of records, one per provider, model and day.
+type Ledger struct {
+	path string
+}
+
+// Open returns the ledger kept in dir. The directory is created on the
+// first write.
+func Open(dir string) *Ledger {
+	return &Ledger{path: filepath.Join(dir, "usage.json")}
+}
+
+// Add counts one request with usage u made at t.
+func (l *Ledger) Add(t time.Time, provider, model string, u llm.Usage) error {
+	records, err := l.read()
+	if err != nil {
+		return err
+	}
+	day := t.Local().Format(dayLayout)
+	found := false
+	for i := range records {
+		r := &records[i]
+		if r.Day == day && r.Provider == provider && r.Model == model {
+			r.Requests++
+			r.Usage = r.Usage.Add(u)
+			found = true
+			break
+		}
+	}
+	if !found {
+		records = append(records, Record{Day: day, Provider: provider, Model: model, Requests: 1, Usage: u})
+	}
+	return l.write(records)
+}
+
+// Since returns the records from day (YYYY-MM-DD) onwards, oldest first.
+func (l *Ledger) Since(day string) ([]Record, error) {
+	records, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	var out []Record
+	for _, r := range records {
+		if r.Day >= day {
+			out = append(out, r)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Day < out[j].Day })
+	return out, nil
+}
+
+// Summarize merges records by provider and model, sorted by name.
+func Summarize(records []Record) []Record {
+	index := map[string]int{}
+	var out []Record
+	for _, r := range records {
+		key := r.Provider + "\x00" + r.Model
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(out)
+			out = append(out, Record{Provider: r.Provider, Model: r.Model})
+			i = len(out) - 1
+		}
+		out[i].Requests += r.Requests
+		out[i].Usage = out[i].Usage.Add(r.Usage)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+// Cost prices u in USD.
+func Cost(u llm.Usage, p config.Price) float64 {
+	cachedPrice := p.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = p.Input
+	}
+	uncached := u.PromptTokens - u.CachedTokens
+	return (float64(uncached)*p.Input + float64(u.CachedTokens)*cachedPrice + float64(u.CompletionTokens)*p.Output) / 1e6
+}
+
+// LookupPrice finds the price of model, trying "provider/model" before the
+// bare model name.
+func LookupPrice(prices map[string]config.Price, provider, model string) (config.Price, bool) {
+	if p, ok := prices[provider+"/"+model]; ok {
+		return p, true
+	}
+	p, ok := prices[model]
+	return p, ok
+}
+
+// EstimatedCompletionTokens is the completion size assumed when estimating
+// the cost of a request; commit messages are short.
+const EstimatedCompletionTokens = 300
+
+// EstimateTokens approximates the token count of texts at four characters
+// per token, which is close enough for guarding against oversized prompts.
+func EstimateTokens(texts ...string) int {
+	chars := 0
+	for _, text := range texts {
+		chars += len(text)
+	}
+	return (chars + 3) / 4
+}
+
+// Spent prices the records from day onwards. Models without a price are
+// not counted.
+func (l *Ledger) Spent(day string, prices map[string]config.Price) (float64, error) {
+	records, err := l.Since(day)
+	if err != nil {
+		return 0, err
+	}
+	total := 0.0
+	for _, r := range records {
+		if price, ok := LookupPrice(prices, r.Provider, r.Model); ok {
+			total += Cost(r.Usage, price)
+		}
+	}
+	return total, nil
+}
+
+// Today returns the ledger day for now.
+func Today() string {
+	return time.Now().Format(dayLayout)
+}
+
+// ParseSince turns "30d", "2w" or a YYYY-MM-DD date into the first day to
+// include, relative to now.
+func ParseSince(since string, now time.Time) (string, error) {
+	since = strings.TrimSpace(since)
+	if t, err := time.ParseInLocation(dayLayout, since, now.Location()); err == nil {
+		return t.Format(dayLayout), nil
+	}
+	if len(since) >= 2 {
+		n, err := strconv.Atoi(since[:len(since)-1])
+		if err == nil && n >= 0 {
+			switch since[len(since)-1] {
+			case 'd':
+				return now.AddDate(0, 0, -n+1).Format(dayLayout), nil
+			case 'w':
+				return now.AddDate(0, 0, -7*n+1).Format(dayLayout), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("invalid --since %q (use e.g. 30d, 2w or 2024-01-31)", since)
+}
+
+func (l *Ledger) read() ([]Record, error) {
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("read %s: %w", l.path, err)
+	}
+	return records, nil
+}
+
+func (l *Ledger) write(records []Record) error {
+	dir := filepath.Dir(l.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "usage-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
@@ -0,0 +1,120 @@
+package usage
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/llm"
+)
+
+func TestLedger(t *testing.T) {
+	ledger := Open(t.TempDir())
+	day1 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+
+	adds := []struct {
+		t     time.Time
+		model string
+		u     llm.Usage
+	}{
+		{day1, "gpt-4o-mini", llm.Usage{PromptTokens: 100, CompletionTokens: 10}},
+		{day1, "gpt-4o-mini", llm.Usage{PromptTokens: 50, CompletionTokens: 5, CachedTokens: 40}},
+		{day2, "gpt-4o-mini", llm.Usage{PromptTokens: 10, CompletionTokens: 1}},
+		{day2, "gpt-4o", llm.Usage{PromptTokens: 7, CompletionTokens: 3}},
+	}
+	for _, a := range adds {
+		if err := ledger.Add(a.t, "openai", a.model, a.u); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	all, err := ledger.Since("2024-01-01")
+	if err != nil {
+		t.Fatalf("Since: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("len(Since) = %d, want 3 records", len(all))
+	}
+	if all[0].Requests != 2 || all[0].PromptTokens != 150 || all[0].CachedTokens != 40 {
+		t.Fatalf("first record = %+v", all[0])
+	}
+
+	recent, err := ledger.Since("2024-01-02")
+	if err != nil {
+		t.Fatalf("Since: %v", err)
+	}
+	if len(recent) != 2 {
+		t.Fatalf("len(Since(day2)) = %d, want 2", len(recent))
+	}
+
+	summary := Summarize(all)
+	if len(summary) != 2 || summary[0].Model != "gpt-4o" || summary[1].Requests != 3 || summary[1].PromptTokens != 160 {
+		t.Fatalf("Summarize = %+v", summary)
+	}
+}
+
+func TestSpent(t *testing.T) {
+	ledger := Open(t.TempDir())
+	day := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	if err := ledger.Add(day, "openai", "priced", llm.Usage{PromptTokens: 1_000_000}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.Add(day, "openai", "unpriced", llm.Usage{PromptTokens: 1_000_000}); err != nil {
+		t.Fatal(err)
+	}
+	spent, err := ledger.Spent("2024-01-01", map[string]config.Price{"priced": {Input: 3}})
+	if err != nil {
+		t.Fatalf("Spent: %v", err)
+	}
+	if spent != 3 {
+		t.Fatalf("Spent = %v, want 3", spent)
+	}
+}
+
+func TestCost(t *testing.T) {
+	u := llm.Usage{PromptTokens: 1_000_000, CachedTokens: 500_000, CompletionTokens: 100_000}
+	tests := []struct {
+		name  string
+		price config.Price
+		want  float64
+	}{
+		{name: "cached price", price: config.Price{Input: 2, CachedInput: 1, Output: 10}, want: 1 + 0.5 + 1},
+		{name: "cached defaults to input", price: config.Price{Input: 2, Output: 10}, want: 2 + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Cost(u, tt.price); math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("Cost = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "1d", want: "2024-03-10"},
+		{in: "30d", want: "2024-02-10"},
+		{in: "2w", want: "2024-02-26"},
+		{in: "2024-01-31", want: "2024-01-31"},
+		{in: "yesterday", wantErr: true},
+		{in: "d", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSince(tt.in, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSince(%q) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseSince(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
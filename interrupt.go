@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/MenschMachine/gommit/internal/ui"
+)
+
+// exitInterrupted is the exit status after Ctrl-C, as for a shell job
+// killed by SIGINT.
+const exitInterrupted = 130
+
+// interruptContext returns a context cancelled by the first SIGINT or
+// SIGTERM, so the running stage can stop its git processes and requests and
+// leave the terminal clean. A second signal exits at once.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+		<-signals
+		os.Exit(exitInterrupted)
+	}()
+	return ctx
+}
+
+// isInterrupt reports whether err ended a stage because the user pressed
+// Ctrl-C, either as a signal or inside a prompt.
+func isInterrupt(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || errors.Is(err, ui.ErrInterrupted) || errors.Is(err, context.Canceled)
+}
+
+// interrupted reports which stage was stopped and how to pick up from
+// there, then exits with exitInterrupted.
+func interrupted(stage, resume string) {
+	msg := "interrupted while " + stage
+	if resume != "" {
+		msg += "; " + resume
+	}
+	if outputJSON {
+		writeJSONError(errCodeInterrupted, msg)
+	} else {
+		// The interrupted line may end in a spinner or prompt.
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "gommit:", msg)
+	}
+	os.Exit(exitInterrupted)
+}
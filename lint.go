@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/MenschMachine/gommit/internal/cli"
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/git"
+	"github.com/MenschMachine/gommit/internal/lint"
+	"github.com/MenschMachine/gommit/internal/prompt"
+	"github.com/MenschMachine/gommit/internal/ui"
+	"github.com/MenschMachine/gommit/pkg/gommit"
+)
+
+const commitMsgHook = `#!/bin/sh
+# Installed by gommit lint --install-hook
+exec gommit lint --file "$1"
+`
+
+// lintFlags are the options of `gommit lint`.
+type lintFlags struct {
+	file, format, style, configPath string
+	suggest, installHook            bool
+}
+
+func lintCommand() *cli.Command {
+	o := &lintFlags{}
+	return &cli.Command{
+		Name:        "lint",
+		Synopsis:    []string{"[options] [<revision>|<range>]", "[options] --file <msgfile>", "--install-hook"},
+		Summary:     "check commit messages against the configured style",
+		Description: "Lints HEAD when neither a revision nor --file is given.",
+		Flags: []*cli.Flag{
+			cli.String(&o.file, "file", "", "file", "", "lint the commit message in file (as passed to a commit-msg hook)"),
+			cli.String(&o.format, "format", "", "format", "text", "report format ("+strings.Join(lintFormats, ", ")+")").
+				Complete(func() []string { return lintFormats }),
+			styleOption(&o.style),
+			configOption(&o.configPath),
+			cli.Bool(&o.suggest, "suggest", "", "ask the LLM for a corrected message"),
+			cli.Bool(&o.installHook, "install-hook", "", "install gommit lint as the commit-msg hook"),
+		},
+		Run: func(args []string) error {
+			runLint(o, args)
+			return nil
+		},
+	}
+}
+
+var lintFormats = []string{"text", "json", "sarif"}
+
+func runLint(o *lintFlags, args []string) {
+	root, err := git.RepoRoot()
+	if err != nil {
+		fatal(err.Error())
+	}
+	if o.installHook {
+		path, err := installCommitMsgHook(root)
+		if err != nil {
+			fatal(err.Error())
+		}
+		fmt.Println("Installed commit-msg hook at", path)
+		return
+	}
+	if o.file != "" && len(args) > 0 {
+		fatal("--file and a revision cannot be used together")
+	}
+	if len(args) > 1 {
+		fatal("lint takes at most one revision or range")
+	}
+	if !slices.Contains(lintFormats, o.format) {
+		fatal(fmt.Sprintf("unknown format %q (%s)", o.format, strings.Join(lintFormats, ", ")))
+	}
+
+	cfg, err := gommit.LoadConfig(o.configPath)
+	if err != nil {
+		fatal(err.Error())
+	}
+	if o.style != "" {
+		cfg.Style = o.style
+	}
+	tmpl, err := gommit.LoadTemplate(cfg)
+	if err != nil {
+		fatal(err.Error())
+	}
+
+	var results []lint.Result
+	var messages []string
+	if o.file != "" {
+		raw, err := os.ReadFile(o.file)
+		if err != nil {
+			fatal(err.Error())
+		}
+		message := lint.CleanMessage(string(raw), git.CommentString(root))
+		res := lint.Check(tmpl.Style, message)
+		res.File = o.file
+		results = append(results, res)
+		messages = append(messages, message)
+	} else {
+		rev := "HEAD"
+		if len(args) == 1 {
+			rev = args[0]
+		}
+		commits, err := git.CommitMessages(root, rev)
+		if err != nil {
+			fatal(err.Error())
+		}
+		for _, c := range commits {
+			res := lint.Check(tmpl.Style, c.Message)
+			res.Commit = c.Hash
+			results = append(results, res)
+			messages = append(messages, c.Message)
+		}
+	}
+
+	if o.suggest && lint.Failed(results) {
+		ctx := interruptContext()
+		err := suggestMessages(ctx, root, cfg, tmpl, results, messages, o.format == "text")
+		if isInterrupt(ctx, err) {
+			interrupted("generating suggestions", "")
+		}
+		if err != nil {
+			fatal(err.Error())
+		}
+	}
+
+	switch o.format {
+	case "json":
+		err = lint.WriteJSON(os.Stdout, results)
+	case "sarif":
+		err = lint.WriteSARIF(os.Stdout, results, tmpl.Style.Name, version)
+	default:
+		lint.WriteText(os.Stdout, results)
+	}
+	if err != nil {
+		fatal(err.Error())
+	}
+	if lint.Failed(results) {
+		os.Exit(1)
+	}
+}
+
+// suggestMessages asks the model for a corrected message for every failing
+// result, using the diff of the commit or, for message files, the index,
+// in the prompt generating a message would send.
+func suggestMessages(ctx context.Context, root string, cfg config.Config, tmpl *prompt.Template, results []lint.Result, messages []string, showSpinner bool) error {
+	client, err := gommit.NewClient(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	meter := newUsageMeter(cfg)
+	client.OnUsage = meter.record
+	spinnerOut := io.Writer(os.Stderr)
+	if !showSpinner {
+		spinnerOut = io.Discard
+	}
+	warn := func(err error) { fmt.Fprintln(os.Stderr, "gommit:", err) }
+
+	for i, res := range results {
+		if len(res.Violations) == 0 {
+			continue
+		}
+		var diff git.DiffResult
+		scopeLabel := gommit.ScopeLabel(gommit.ScopeStaged)
+		if res.Commit != "" {
+			diff, err = git.CollectCommitDiff(ctx, root, res.Commit, cfg.PerFileLimit)
+			scopeLabel = "commit " + res.Commit
+		} else {
+			diff, err = git.CollectDiff(ctx, root, git.ScopeStaged, cfg.PerFileLimit)
+		}
+		if err != nil {
+			return err
+		}
+
+		p, err := gommit.RenderPrompt(ctx, gommit.Options{
+			Config:    cfg,
+			Template:  tmpl,
+			Diff:      gommit.StaticDiff(gommit.Diff{Root: root, Scope: scopeLabel, Text: diff.Diff, Binaries: diff.Binary, Truncated: diff.TruncatedFiles}),
+			Provider:  gommit.ClientProvider{Client: client},
+			Hint:      prompt.RepairHint(messages[i], res.Violations),
+			OnWarning: warn,
+		})
+		if errors.Is(err, gommit.ErrNoChanges) {
+			// Nothing to base a suggestion on, e.g. an empty commit.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := guardRequest(cfg.Guard, meter, p.System, p.User, false); err != nil {
+			return err
+		}
+		spinner := ui.StartSpinner(ctx, spinnerOut, "Generating suggestion for "+res.Subject)
+		suggestion, err := client.ChatCompletion(ctx, p.System, p.User)
+		spinner.Stop()
+		if err != nil {
+			return err
+		}
+		results[i].Suggestion = tmpl.Style.Fix(suggestion)
+	}
+	if line := meter.summary(); line != "" && showSpinner {
+		fmt.Fprintln(os.Stderr, line)
+	}
+	return nil
+}
+
+func installCommitMsgHook(root string) (string, error) {
+	dir, err := git.HooksDir(root)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "commit-msg")
+	existing, err := os.ReadFile(path)
+	if err == nil && !strings.Contains(string(existing), "gommit lint") {
+		return "", fmt.Errorf("%s already exists; remove it or add `gommit lint --file \"$1\"` to it", path)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(commitMsgHook), 0o755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -1,287 +1,480 @@
 package main
 
 import (
-	"context"
-	"flag"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
-	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/cache"
+	"github.com/MenschMachine/gommit/internal/cli"
 	"github.com/MenschMachine/gommit/internal/git"
+	"github.com/MenschMachine/gommit/internal/history"
 	"github.com/MenschMachine/gommit/internal/llm"
 	"github.com/MenschMachine/gommit/internal/prompt"
 	"github.com/MenschMachine/gommit/internal/ui"
+	"github.com/MenschMachine/gommit/pkg/gommit"
 )
 
 var version = "dev"
 
-const (
-	scopeStaged           = "staged only"
-	scopeStagedUnstaged   = "staged + unstaged"
-	scopeAllWithUntracked = "staged + unstaged + untracked"
-)
-
 func main() {
-	var includeUnstaged bool
-	var includeAll bool
-	var autoAccept bool
-	var dumpContext bool
-	var showVersion bool
-	var maxPromptCharsFlag int
-	var providerFlag string
-	var modelFlag string
-	var baseURLFlag string
-	var styleFlag string
-	var configPathFlag string
-	var tagFlag string
-	var skipCI bool
-	var noVerify bool
-	var dryRun bool
-	var ignoreEmpty bool
-	var openRouterRefFlag string
-	var openRouterTitleFlag string
-
-	flag.Usage = func() {
-		out := flag.CommandLine.Output()
-		cfgDefaults := config.DefaultConfig()
-		cfgPath, err := config.DefaultConfigPath()
-		if err != nil {
-			cfgPath = "~/.config/gommit/config.toml"
-		}
-
-		fmt.Fprintln(out, "Usage: gommit [options]")
-		fmt.Fprintln(out, "")
-		fmt.Fprintln(out, "Options:")
-		fmt.Fprintln(out, "  --version                show version and exit")
-		fmt.Fprintln(out, "  -u, --include-unstaged   include staged + unstaged")
-		fmt.Fprintln(out, "  -A, --include-all        include staged + unstaged + untracked")
-		fmt.Fprintln(out, "  -f, --accept             auto-accept proposed result")
-		fmt.Fprintln(out, "  -n, --dry-run            generate and print commit message only")
-		fmt.Fprintln(out, "  -I, --ignore-empty       exit 0 if no changes found")
-		fmt.Fprintln(out, "  -d, --dump-context       print LLM request JSON and exit")
-		fmt.Fprintln(out, "      --max-prompt-chars   max chars for user prompt (0 = no limit)")
-		fmt.Fprintf(out, "  -p, --provider string    llm provider (openai, openrouter, anthropic) (default: %s)\n", cfgDefaults.Provider)
-		fmt.Fprintln(out, "  -m, --model string       model name (required unless set in config/env)")
-		fmt.Fprintln(out, "  -b, --base-url string    base url for openai-compatible api")
-		fmt.Fprintf(out, "                           default: %s (openai), %s (openrouter)\n", config.DefaultBaseURL("openai"), config.DefaultBaseURL("openrouter"))
-		fmt.Fprintln(out, "  -t, --tag string         append [STRING] to commit message")
-		fmt.Fprintln(out, "  -s, --skip-ci            shortcut for --tag \"skip ci\"")
-		fmt.Fprintln(out, "      --no-verify          pass --no-verify to git commit")
-		fmt.Fprintf(out, "      --style string       commit style (conventional or freeform) (default: %s)\n", cfgDefaults.Style)
-		fmt.Fprintf(out, "  -c, --config string      path to config file (default: %s)\n", cfgPath)
-		fmt.Fprintln(out, "  -r, --openrouter-referer string  openrouter HTTP-Referer header")
-		fmt.Fprintln(out, "  -T, --openrouter-title string    openrouter X-Title header")
-	}
-
-	flag.BoolVar(&includeUnstaged, "u", false, "include staged + unstaged")
-	flag.BoolVar(&includeUnstaged, "include-unstaged", false, "include staged + unstaged")
-	flag.BoolVar(&includeAll, "A", false, "include staged + unstaged + untracked")
-	flag.BoolVar(&includeAll, "include-all", false, "include staged + unstaged + untracked")
-	flag.BoolVar(&autoAccept, "f", false, "auto-accept proposed result")
-	flag.BoolVar(&autoAccept, "accept", false, "auto-accept proposed result")
-	flag.BoolVar(&dryRun, "n", false, "generate and print commit message only")
-	flag.BoolVar(&dryRun, "dry-run", false, "generate and print commit message only")
-	flag.BoolVar(&ignoreEmpty, "I", false, "exit 0 if no changes found")
-	flag.BoolVar(&ignoreEmpty, "ignore-empty", false, "exit 0 if no changes found")
-	flag.BoolVar(&dumpContext, "d", false, "print LLM request JSON and exit")
-	flag.BoolVar(&dumpContext, "dump-context", false, "print LLM request JSON and exit")
-	flag.BoolVar(&showVersion, "version", false, "show version and exit")
-	flag.IntVar(&maxPromptCharsFlag, "max-prompt-chars", -1, "max chars for user prompt (0 = no limit)")
-	flag.StringVar(&providerFlag, "p", "", "llm provider (openai, openrouter, anthropic)")
-	flag.StringVar(&providerFlag, "provider", "", "llm provider (openai, openrouter, anthropic)")
-	flag.StringVar(&modelFlag, "m", "", "model name")
-	flag.StringVar(&modelFlag, "model", "", "model name")
-	flag.StringVar(&baseURLFlag, "b", "", "base url for openai-compatible api")
-	flag.StringVar(&baseURLFlag, "base-url", "", "base url for openai-compatible api")
-	flag.StringVar(&tagFlag, "t", "", "append [STRING] to commit message")
-	flag.StringVar(&tagFlag, "tag", "", "append [STRING] to commit message")
-	flag.BoolVar(&skipCI, "s", false, "shortcut for --tag \"skip ci\"")
-	flag.BoolVar(&skipCI, "skip-ci", false, "shortcut for --tag \"skip ci\"")
-	flag.BoolVar(&noVerify, "no-verify", false, "pass --no-verify to git commit")
-	flag.StringVar(&styleFlag, "style", "", "commit style (conventional or freeform)")
-	flag.StringVar(&configPathFlag, "c", "", "path to config file")
-	flag.StringVar(&configPathFlag, "config", "", "path to config file")
-	flag.StringVar(&openRouterRefFlag, "r", "", "openrouter HTTP-Referer header")
-	flag.StringVar(&openRouterRefFlag, "openrouter-referer", "", "openrouter HTTP-Referer header")
-	flag.StringVar(&openRouterTitleFlag, "T", "", "openrouter X-Title header")
-	flag.StringVar(&openRouterTitleFlag, "openrouter-title", "", "openrouter X-Title header")
-	flag.Parse()
-
-	if showVersion {
+	if err := newApp().Run(os.Args[1:]); err != nil {
+		fatal(err.Error())
+	}
+}
+
+// commitFlags are the options of the default commit command.
+type commitFlags struct {
+	includeUnstaged     bool
+	includeAll          bool
+	autoAccept          bool
+	dumpContext         bool
+	reuse               bool
+	noCache             bool
+	outputFlag          string
+	showVersion         bool
+	maxPromptCharsFlag  int
+	providerFlag        string
+	modelFlag           string
+	baseURLFlag         string
+	styleFlag           string
+	configPathFlag      string
+	tagFlag             string
+	skipCI              bool
+	noVerify            bool
+	dryRun              bool
+	ignoreEmpty         bool
+	openRouterRefFlag   string
+	openRouterTitleFlag string
+	coAuthorFlags       stringList
+	trailerFlags        stringList
+	signoff             bool
+	gpgSign             optionalValue
+	commitAuthor        string
+	commitDate          string
+	allowEmpty          bool
+	cleanup             string
+	fixup               string
+	squash              string
+	gitArgFlags         stringList
+}
+
+func commitCommand() *cli.Command {
+	o := &commitFlags{}
+	return &cli.Command{
+		Name:     "commit",
+		Synopsis: []string{"[options]"},
+		Summary:  "generate a message for the current changes and commit (default)",
+		Description: "Collects the staged changes (or more with -u/-A), asks the model for a commit message\n" +
+			"and lets you accept, edit or regenerate it before committing.",
+		Flags: []*cli.Flag{
+			cli.Bool(&o.showVersion, "version", "", "show version and exit"),
+			cli.Bool(&o.includeUnstaged, "include-unstaged", "u", "include staged + unstaged"),
+			cli.Bool(&o.includeAll, "include-all", "A", "include staged + unstaged + untracked"),
+			cli.Bool(&o.autoAccept, "accept", "f", "auto-accept proposed result"),
+			cli.Bool(&o.dryRun, "dry-run", "n", "generate and print commit message only"),
+			cli.Bool(&o.ignoreEmpty, "ignore-empty", "I", "exit 0 if no changes found"),
+			cli.Bool(&o.dumpContext, "dump-context", "d", "print LLM request JSON and exit"),
+			cli.Bool(&o.reuse, "reuse", "", "propose the last recorded message instead of generating one"),
+			cli.Bool(&o.noCache, "no-cache", "", "do not read or write the response cache"),
+			cli.String(&o.outputFlag, "output", "", "format", "text",
+				"output format: text or json (json prints the result as one object and implies --dry-run unless --accept is set)").
+				Complete(func() []string { return outputFormats }),
+			cli.Int(&o.maxPromptCharsFlag, "max-prompt-chars", "", "n", -1, "max chars for user prompt (0 = no limit)").
+				ShowDefault("from config"),
+			providerOption(&o.providerFlag),
+			modelOption(&o.modelFlag),
+			baseURLOption(&o.baseURLFlag),
+			cli.String(&o.tagFlag, "tag", "t", "string", "", "append [STRING] to commit message"),
+			cli.Bool(&o.skipCI, "skip-ci", "s", `shortcut for --tag "skip ci"`),
+			cli.Bool(&o.noVerify, "no-verify", "", "pass --no-verify to git commit"),
+			cli.Var(&o.coAuthorFlags, "co-author", "", "string", `add a Co-authored-by trailer (alias or "Name <email>", repeatable)`).
+				Complete(coAuthorAliases),
+			cli.Var(&o.trailerFlags, "trailer", "", "key=value", "add a trailer (repeatable)"),
+			cli.Bool(&o.signoff, "signoff", "S", "add a Signed-off-by trailer"),
+			cli.Var(&o.gpgSign, "gpg-sign", "", "keyid", "GPG/SSH-sign the commit"),
+			cli.String(&o.commitAuthor, "author", "", "string", "", "override the commit author"),
+			cli.String(&o.commitDate, "date", "", "string", "", "override the author date"),
+			cli.Bool(&o.allowEmpty, "allow-empty", "", "allow a commit without changes (message is written in the editor)"),
+			cli.String(&o.cleanup, "cleanup", "", "mode", "", "git commit message cleanup mode").
+				Complete(func() []string { return cleanupModes }),
+			cli.String(&o.fixup, "fixup", "", "commit", "", "create a fixup! commit for the given commit (no message is generated)"),
+			cli.String(&o.squash, "squash", "", "commit", "", "create a squash! commit with the generated message as body"),
+			cli.Var(&o.gitArgFlags, "git-arg", "", "string", "pass an extra argument to git commit (repeatable)"),
+			styleOption(&o.styleFlag),
+			configOption(&o.configPathFlag),
+			cli.String(&o.openRouterRefFlag, "openrouter-referer", "r", "string", "", "openrouter HTTP-Referer header"),
+			cli.String(&o.openRouterTitleFlag, "openrouter-title", "T", "string", "", "openrouter X-Title header"),
+		},
+		Run: func(args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("unknown command %q (run 'gommit help')", args[0])
+			}
+			runCommit(o)
+			return nil
+		},
+	}
+}
+
+// runCommit is the default command: generate a message for the selected
+// changes, let the user review it and commit.
+func runCommit(o *commitFlags) {
+	if o.showVersion {
 		fmt.Println("gommit", version)
 		return
 	}
 
-	if dryRun {
-		autoAccept = true
+	start := time.Now()
+	switch o.outputFlag {
+	case "text":
+	case "json":
+		outputJSON = true
+		// JSON is for scripts: never prompt, and only commit when asked to.
+		if !o.autoAccept {
+			o.dryRun = true
+		}
+	default:
+		fail(errCodeUsage, fmt.Sprintf("unknown --output %q (text, json)", o.outputFlag))
+	}
+	if outputJSON && o.dumpContext {
+		fail(errCodeUsage, "--output json and --dump-context cannot be used together")
 	}
 
-	if skipCI {
-		if tagFlag != "" {
-			fatal("--tag and --skip-ci cannot be used together")
-		}
-		tagFlag = "skip ci"
+	if o.dryRun {
+		o.autoAccept = true
 	}
 
-	cfgPath := configPathFlag
-	if cfgPath == "" {
-		var err error
-		cfgPath, err = config.DefaultConfigPath()
-		if err != nil {
-			fatal(err.Error())
+	if o.skipCI {
+		if o.tagFlag != "" {
+			fail(errCodeUsage, "--tag and --skip-ci cannot be used together")
+		}
+		o.tagFlag = "skip ci"
+	}
+
+	commitOpts := commitOptions{
+		NoVerify:   o.noVerify,
+		GPGSign:    o.gpgSign.value,
+		Author:     o.commitAuthor,
+		Date:       o.commitDate,
+		AllowEmpty: o.allowEmpty,
+		Cleanup:    o.cleanup,
+		Fixup:      o.fixup,
+		Squash:     o.squash,
+		GitArgs:    o.gitArgFlags,
+		Quiet:      outputJSON,
+	}
+	if err := commitOpts.validate(); err != nil {
+		fail(errCodeUsage, err.Error())
+	}
+	if o.reuse && o.dumpContext {
+		fail(errCodeUsage, "--reuse and --dump-context cannot be used together")
+	}
+	if o.fixup != "" {
+		switch {
+		case o.dryRun, o.dumpContext, o.reuse, outputJSON:
+			fail(errCodeUsage, "--fixup does not generate a message; --dry-run, --dump-context, --reuse and --output json do not apply")
+		case o.tagFlag != "", len(o.coAuthorFlags) > 0, len(o.trailerFlags) > 0, o.signoff:
+			fail(errCodeUsage, "--fixup uses git's own message; --tag, --co-author, --trailer and --signoff cannot be added")
 		}
 	}
 
-	cfg, err := config.Load(cfgPath)
+	cfg, err := gommit.LoadConfig(o.configPathFlag)
 	if err != nil {
-		fatal(err.Error())
+		fail(errCodeConfig, err.Error())
 	}
-	config.ApplyEnvOverrides(&cfg)
 
-	if providerFlag != "" {
-		cfg.Provider = providerFlag
-	}
-	if modelFlag != "" {
-		cfg.Model = modelFlag
+	if o.providerFlag != "" {
+		cfg.Provider = o.providerFlag
 	}
-	if baseURLFlag != "" {
-		cfg.BaseURL = baseURLFlag
+	if o.modelFlag != "" {
+		cfg.Model = o.modelFlag
 	}
-	if styleFlag != "" {
-		cfg.Style = styleFlag
+	if o.baseURLFlag != "" {
+		cfg.BaseURL = o.baseURLFlag
 	}
-	if maxPromptCharsFlag >= 0 {
-		cfg.MaxPromptChars = maxPromptCharsFlag
+	if o.styleFlag != "" {
+		cfg.Style = o.styleFlag
 	}
-	if openRouterRefFlag != "" {
-		cfg.OpenRouterRef = openRouterRefFlag
+	if o.maxPromptCharsFlag >= 0 {
+		cfg.MaxPromptChars = o.maxPromptCharsFlag
 	}
-	if openRouterTitleFlag != "" {
-		cfg.OpenRouterTitle = openRouterTitleFlag
+	if o.openRouterRefFlag != "" {
+		cfg.OpenRouterRef = o.openRouterRefFlag
 	}
-
-	provider := strings.ToLower(strings.TrimSpace(cfg.Provider))
-	if provider == "" {
-		provider = "openai"
+	if o.openRouterTitleFlag != "" {
+		cfg.OpenRouterTitle = o.openRouterTitleFlag
 	}
 
-	if cfg.BaseURL == "" {
-		cfg.BaseURL = config.DefaultBaseURL(provider)
-	}
-	if provider == "anthropic" && cfg.BaseURL == "" {
-		fatal("anthropic requires an OpenAI-compatible base URL; set --base-url or config base_url")
+	tmpl, err := gommit.LoadTemplate(cfg)
+	if err != nil {
+		fail(errCodeConfig, err.Error())
 	}
-	if cfg.Model == "" {
-		fatal("model is required; set --model or config model")
+	root, err := git.RepoRoot()
+	if err != nil {
+		fail(errCodeGit, err.Error())
 	}
 
-	apiKey, err := config.ResolveAPIKey(provider)
-	if err != nil {
-		fatal(err.Error())
+	scope := gommit.ScopeStaged
+	if o.includeAll {
+		scope = gommit.ScopeAll
+	} else if o.includeUnstaged {
+		scope = gommit.ScopeStagedUnstaged
 	}
 
-	root, err := git.RepoRoot()
-	if err != nil {
-		fatal(err.Error())
+	if o.fixup != "" {
+		if err := commitMessage(root, "", scope, commitOpts); err != nil {
+			fail(errCodeCommit, err.Error())
+		}
+		fmt.Println("Commit created.")
+		return
 	}
 
-	scope := git.ScopeStaged
-	scopeLabel := scopeStaged
-	if includeAll {
-		scope = git.ScopeAll
-		scopeLabel = scopeAllWithUntracked
-	} else if includeUnstaged {
-		scope = git.ScopeStagedUnstaged
-		scopeLabel = scopeStagedUnstaged
+	ctx := interruptContext()
+
+	client, err := gommit.NewClient(ctx, cfg)
+	if isInterrupt(ctx, err) {
+		interrupted("waiting for the keyring", "nothing was committed")
 	}
+	if err != nil {
+		fail(errCodeConfig, err.Error())
+	}
+	meter := newUsageMeter(cfg)
+	client.OnUsage = meter.record
 
 	spinnerOut := io.Writer(os.Stderr)
-	if dryRun {
+	if o.dryRun || outputJSON {
 		spinnerOut = io.Discard
 	}
 
-	diffSpinner := ui.StartSpinner(spinnerOut, "Collecting diff")
-	result, err := git.CollectDiff(root, scope, cfg.PerFileLimit)
+	diffSpinner := ui.StartSpinner(ctx, spinnerOut, "Collecting diff")
+	diff, err := gommit.GitDiff{Root: root, Scope: scope, PerFileLimit: cfg.PerFileLimit}.Diff(ctx)
 	diffSpinner.Stop()
+	if isInterrupt(ctx, err) {
+		interrupted("collecting the diff", "nothing was committed")
+	}
 	if err != nil {
-		fatal(err.Error())
+		fail(errCodeGit, err.Error())
 	}
-	if strings.TrimSpace(result.Diff) == "" && len(result.Binary) == 0 {
-		if ignoreEmpty {
+	if diff.Empty() {
+		if o.ignoreEmpty && !outputJSON {
 			return
 		}
-		fatal("no changes found for selected diff scope")
+		if !o.allowEmpty || outputJSON {
+			if o.ignoreEmpty {
+				// Scripts still get an object, but not a failure.
+				writeJSONError(errCodeNoChanges, "no changes found for selected diff scope")
+				return
+			}
+			fail(errCodeNoChanges, "no changes found for selected diff scope")
+		}
+		// Nothing to describe, so the message has to come from the user.
+		if o.autoAccept {
+			fatal("--allow-empty without changes needs a message; run interactively to write one")
+		}
+		message, err := ui.EditInEditor(ctx, "")
+		if isInterrupt(ctx, err) {
+			interrupted("writing the commit message", "nothing was committed")
+		}
+		if err != nil {
+			fatal(err.Error())
+		}
+		if strings.TrimSpace(message) == "" {
+			fatal("empty commit message after edit")
+		}
+		if err := commitMessage(root, message, scope, commitOpts); err != nil {
+			if isInterrupt(ctx, err) {
+				interrupted("committing", "")
+			}
+			fatal(err.Error())
+		}
+		fmt.Println("Commit created.")
+		return
+	}
+	changedFiles := diff.Files()
+	diffHash := history.HashDiff(diff.Text)
+
+	trailers, err := buildTrailers(root, cfg, o.coAuthorFlags, o.trailerFlags, o.signoff)
+	if err != nil {
+		fail(errCodeUsage, err.Error())
 	}
-	changedFiles := changedFilesFromResult(result)
 
-	headers := map[string]string{}
-	if provider == "openrouter" {
-		if cfg.OpenRouterRef != "" {
-			headers["HTTP-Referer"] = cfg.OpenRouterRef
+	historyStore, err := openHistory()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gommit: message history disabled:", err)
+	}
+	var responseCache *cache.Cache
+	if !o.noCache {
+		responseCache, err = openCache(cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "gommit: response cache disabled:", err)
+		}
+	}
+	gen := &generator{cfg: cfg, tmpl: tmpl, client: client, meter: meter, cache: responseCache, history: historyStore}
+
+	if o.dumpContext {
+		p, err := gen.prompt(ctx, diff, "")
+		if err != nil {
+			fail(errorCode(err), err.Error())
 		}
-		if cfg.OpenRouterTitle != "" {
-			headers["X-Title"] = cfg.OpenRouterTitle
+		dumpLLMContext(client, p.System, p.User)
+		if len(p.Files) > 0 {
+			fmt.Fprintf(os.Stderr, "Diff detail (max_prompt_chars %d):\n", cfg.MaxPromptChars)
+			for _, f := range p.Files {
+				fmt.Fprintln(os.Stderr, "  "+f.String())
+			}
 		}
+		e := meter.estimate(p.System, p.User)
+		fmt.Fprintln(os.Stderr, "Estimate: "+e.String())
+		for _, p := range guardViolations(cfg.Guard, e) {
+			fmt.Fprintln(os.Stderr, " ! "+p)
+		}
+		return
 	}
-	client := llm.NewClient(cfg.BaseURL, apiKey, cfg.Model, headers, cfg.Timeout)
-	ctx := context.Background()
 
 	var refinementHint string
-	for {
-		singlePrompt := prompt.BuildSinglePromptWithMax(cfg.Style, scopeLabel, result.Diff, result.Binary, result.TruncatedFiles, cfg.MaxPromptChars)
-
-		// Append refinement hint if provided
-		if refinementHint != "" {
-			singlePrompt += "\n\nAdditional guidance: " + refinementHint
+	var message string
+	var violations []prompt.Violation
+	var cached bool
+	regenerate := true
+	firstAttempt := true
+	// resume tells an interrupted user how to get back to the message.
+	resume := func() string {
+		if message == "" || historyStore == nil {
+			return "nothing was committed"
 		}
-
-		if dumpContext {
-			dumpLLMContext(client, prompt.SystemPrompt(), singlePrompt)
-			return
+		return "nothing was committed; run 'gommit --reuse' to pick up the proposed message"
+	}
+	if o.reuse {
+		if historyStore == nil {
+			fatal("--reuse needs the message history")
 		}
-		msgSpinner := ui.StartSpinner(spinnerOut, "Generating commit message")
-		message, err := client.ChatCompletion(ctx, prompt.SystemPrompt(), singlePrompt)
-		msgSpinner.Stop()
+		entry, exact, ok, err := historyStore.Last(root, diffHash)
 		if err != nil {
 			fatal(err.Error())
 		}
+		if !ok {
+			fatal("no message history for this repository")
+		}
+		if !exact {
+			fmt.Fprintln(os.Stderr, "gommit: no message recorded for these changes; reusing the most recent one")
+		}
+		message = entry.Message
+		violations = tmpl.Style.Validate(message)
+		regenerate = false
+	}
+	for {
+		if regenerate {
+			// Only the first attempt may come from the cache; a retry asks
+			// for a different answer.
+			out, err := gen.generate(ctx, diff, generateRequest{
+				Hint:        refinementHint,
+				ReadCache:   firstAttempt,
+				Interactive: !o.autoAccept,
+				Trailers:    trailers,
+				Tag:         o.tagFlag,
+				Progress:    spinnerOut,
+			})
+			if errors.Is(err, errCancelled) {
+				return
+			}
+			if isInterrupt(ctx, err) {
+				interrupted("generating the commit message", resume())
+			}
+			if err != nil {
+				fail(errorCode(err), err.Error())
+			}
+			message, violations, cached = out.Message, out.Violations, out.Cached
+			firstAttempt = false
+			// Clear refinement hint after use
+			refinementHint = ""
+		}
+		regenerate = true
 
-		// Clear refinement hint after use
-		refinementHint = ""
-		message = appendTag(message, tagFlag)
-
-		if dryRun {
+		if outputJSON {
+			res, err := newJSONResult(root, message, diff, violations)
+			if err != nil {
+				fail(errCodeGit, err.Error())
+			}
+			meter.fill(&res)
+			res.Style = tmpl.Style.Name
+			res.Cached = cached
+			if !o.dryRun {
+				if err := commitMessage(root, message, scope, commitOpts); err != nil {
+					if isInterrupt(ctx, err) {
+						interrupted("committing", resume())
+					}
+					fail(errCodeCommit, err.Error())
+				}
+				res.Committed = true
+			}
+			writeJSONResult(res, start)
+			return
+		}
+		if o.dryRun {
+			if cached {
+				fmt.Fprintln(os.Stderr, "gommit: using cached response (--no-cache to regenerate)")
+			}
+			if line := meter.summary(); line != "" {
+				fmt.Fprintln(os.Stderr, line)
+			}
+			warnViolations(violations)
 			fmt.Print(message)
 			return
 		}
 
-		fmt.Println("Proposed commit message:")
+		if cached {
+			fmt.Println("Proposed commit message (cached response):")
+		} else {
+			fmt.Println("Proposed commit message:")
+		}
 		fmt.Println("---")
 		fmt.Println(message)
 		fmt.Println("---")
+		if len(violations) > 0 {
+			fmt.Printf("Style violations (%s):\n", tmpl.Style.Name)
+			for _, v := range violations {
+				fmt.Println(" ! " + v.String())
+			}
+		}
+		if line := meter.summary(); line != "" {
+			fmt.Println(line)
+		}
 
 		ui.DisplayFileBox(os.Stdout, changedFiles, 5)
 		fmt.Println()
 
-		if autoAccept {
+		if o.autoAccept {
 			if strings.TrimSpace(message) == "" {
 				fatal("empty commit message")
 			}
-			if err := commitMessage(root, message, scope, noVerify); err != nil {
+			if err := commitMessage(root, message, scope, commitOpts); err != nil {
+				if isInterrupt(ctx, err) {
+					interrupted("committing", resume())
+				}
 				fatal(err.Error())
 			}
 			fmt.Println("Commit created.")
 			return
 		}
 
+		title := "What would you like to do with this commit message?"
+		if len(violations) > 0 {
+			title = fmt.Sprintf("What would you like to do with this commit message? (%d style violations)", len(violations))
+		}
 		action, err := ui.SelectOption(
-			"What would you like to do with this commit message?",
+			title,
 			[]string{"Accept", "Edit in editor", "Retry generation", "Cancel"},
 		)
+		if isInterrupt(ctx, err) {
+			interrupted("choosing what to do with the message", resume())
+		}
 		if err != nil {
 			fatal(err.Error())
 		}
@@ -293,21 +486,36 @@ func main() {
 				"Refinement hint (optional, press Enter to skip)",
 				"e.g., make it shorter, focus on bug fix, etc.",
 			)
+			if isInterrupt(ctx, err) {
+				interrupted("asking for a refinement hint", resume())
+			}
 			if err != nil {
 				fatal(err.Error())
 			}
 			refinementHint = strings.TrimSpace(hint)
 			continue
 		case "Edit in editor":
-			message, err = ui.EditInEditor(message)
+			edited, err := ui.EditInEditor(ctx, message)
+			if isInterrupt(ctx, err) {
+				interrupted("editing the message", resume())
+			}
 			if err != nil {
 				fatal(err.Error())
 			}
-			if strings.TrimSpace(message) == "" {
+			if strings.TrimSpace(edited) == "" {
 				fatal("empty commit message after edit")
 			}
-			if err := commitMessage(root, message, scope, noVerify); err != nil {
-				fatal(err.Error())
+			message = edited
+			recordHistory(historyStore, root, diffHash, history.SourceEdited, message)
+			if err := commitMessage(root, message, scope, commitOpts); err != nil {
+				if isInterrupt(ctx, err) {
+					interrupted("committing", resume())
+				}
+				// Keep the message so the user can fix the cause (hook,
+				// signing key) and try again without regenerating.
+				fmt.Fprintln(os.Stderr, "gommit:", err)
+				regenerate = false
+				continue
 			}
 			fmt.Println("Commit created.")
 			return
@@ -315,8 +523,13 @@ func main() {
 			if strings.TrimSpace(message) == "" {
 				fatal("empty commit message")
 			}
-			if err := commitMessage(root, message, scope, noVerify); err != nil {
-				fatal(err.Error())
+			if err := commitMessage(root, message, scope, commitOpts); err != nil {
+				if isInterrupt(ctx, err) {
+					interrupted("committing", resume())
+				}
+				fmt.Fprintln(os.Stderr, "gommit:", err)
+				regenerate = false
+				continue
 			}
 			fmt.Println("Commit created.")
 			return
@@ -324,97 +537,240 @@ func main() {
 	}
 }
 
-func appendTag(message, tag string) string {
-	if tag == "" {
-		return message
+func warnViolations(violations []prompt.Violation) {
+	for _, v := range violations {
+		fmt.Fprintln(os.Stderr, "gommit: warning:", v.String())
+	}
+}
+
+// optionalValue is a flag that may be given bare (--gpg-sign) or with a
+// value (--gpg-sign=KEY). A bare flag records "true".
+type optionalValue struct {
+	value string
+}
+
+func (v *optionalValue) String() string {
+	return v.value
+}
+
+func (v *optionalValue) Set(value string) error {
+	v.value = value
+	return nil
+}
+
+func (v *optionalValue) IsBoolFlag() bool {
+	return true
+}
+
+// cleanupModes are the values git commit accepts for --cleanup.
+var cleanupModes = []string{"strip", "whitespace", "verbatim", "scissors", "default"}
+
+// commitOptions are passed through to git commit.
+type commitOptions struct {
+	NoVerify   bool
+	GPGSign    string // "" to leave signing to git config, "true" for the default key, or a key id
+	Author     string
+	Date       string
+	AllowEmpty bool
+	Cleanup    string
+	Fixup      string
+	Squash     string
+	GitArgs    []string
+	// Quiet keeps git off the terminal, for --output json and serve.
+	Quiet bool
+	// Pathspecs limit the commit to these paths, as they limited the diff.
+	Pathspecs []string
+}
+
+// gitArgsManagedByGommit are git commit options gommit sets itself and
+// that would conflict with the generated message if passed through.
+var gitArgsManagedByGommit = []string{"-m", "--message", "-F", "--file", "-C", "--reuse-message", "-c", "--reedit-message", "-t", "--template", "--fixup", "--squash", "-a", "--all"}
+
+func (o commitOptions) validate() error {
+	if o.Fixup != "" && o.Squash != "" {
+		return fmt.Errorf("--fixup and --squash cannot be used together")
 	}
-	subject, body, hasBody := strings.Cut(strings.TrimRight(message, "\n"), "\n")
-	subject = strings.TrimRight(subject, " ") + " [" + tag + "]"
-	if hasBody {
-		return subject + "\n" + body
+	if o.Cleanup != "" && !slices.Contains(cleanupModes, o.Cleanup) {
+		return fmt.Errorf("invalid --cleanup %q (%s)", o.Cleanup, strings.Join(cleanupModes, ", "))
 	}
-	return subject
+	for _, arg := range o.GitArgs {
+		if managedGitArg(arg) {
+			return fmt.Errorf("--git-arg %s conflicts with options gommit manages", arg)
+		}
+	}
+	return nil
 }
 
-func commitMessage(root, message string, scope git.DiffScope, noVerify bool) error {
-	file, err := os.CreateTemp("", "gommit-commit-*.txt")
-	if err != nil {
-		return err
+// managedGitArg reports whether arg sets one of gitArgsManagedByGommit the
+// way git parses it: long options by any unambiguous prefix, with or
+// without "=value", and short options bundled (-am) or with their value
+// attached (-mfoo).
+func managedGitArg(arg string) bool {
+	if name, ok := strings.CutPrefix(arg, "--"); ok {
+		name, _, _ = strings.Cut(name, "=")
+		if name == "" {
+			return false
+		}
+		for _, managed := range gitArgsManagedByGommit {
+			if strings.HasPrefix(managed, "--"+name) {
+				return true
+			}
+		}
+		return false
 	}
-	defer os.Remove(file.Name())
-	if _, err := file.WriteString(strings.TrimSpace(message) + "\n"); err != nil {
-		_ = file.Close()
-		return err
+	flags, ok := strings.CutPrefix(arg, "-")
+	if !ok {
+		return false
 	}
-	if err := file.Close(); err != nil {
-		return err
+	for _, c := range flags {
+		if slices.Contains(gitArgsManagedByGommit, "-"+string(c)) {
+			return true
+		}
+		if strings.ContainsRune(gitShortFlagsWithValue, c) {
+			// The rest of the argument is the option's value.
+			return false
+		}
 	}
+	return false
+}
 
+// gitShortFlagsWithValue are short git commit options, besides the managed
+// ones, that take the rest of a bundle as their value.
+const gitShortFlagsWithValue = "Su"
+
+// commitMessage commits with message, or with git's own message when it is
+// empty (used for --fixup). If git commit fails, the message is kept in the
+// git directory so it survives for a retry.
+func commitMessage(root, message string, scope git.DiffScope, opts commitOptions) error {
+	messageFile := ""
+	if message != "" {
+		file, err := os.CreateTemp("", "gommit-commit-*.txt")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(file.Name())
+		if _, err := file.WriteString(strings.TrimSpace(message) + "\n"); err != nil {
+			_ = file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		messageFile = filepath.Clean(file.Name())
+	}
+
+	if scope == git.ScopeStaged && len(opts.Pathspecs) > 0 {
+		// git commit with paths takes them from the work tree, which would
+		// add unstaged changes the diff did not show.
+		unstaged, err := git.UnstagedFiles(root, opts.Pathspecs)
+		if err != nil {
+			return err
+		}
+		if len(unstaged) > 0 {
+			return fmt.Errorf("cannot commit only the staged changes of %s: unstaged changes in %s", strings.Join(opts.Pathspecs, " "), strings.Join(unstaged, ", "))
+		}
+	}
 	if scope == git.ScopeAll {
-		if err := runGitCmd(root, "add", "."); err != nil {
+		if err := runGitCmd(root, opts.Quiet, append([]string{"add", "-A", "--"}, pathspecsOrRoot(opts.Pathspecs)...)...); err != nil {
 			return err
 		}
 	}
 
-	args := buildCommitArgs(scope, filepath.Clean(file.Name()), noVerify)
-	cmd := exec.Command("git", args...)
-	cmd.Dir = root
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	return cmd.Run()
+	if err := runGitCmd(root, opts.Quiet, buildCommitArgs(scope, messageFile, opts)...); err != nil {
+		if message == "" {
+			return err
+		}
+		saved, saveErr := saveFailedMessage(root, message)
+		if saveErr != nil {
+			return fmt.Errorf("git commit failed: %w (saving message also failed: %v)", err, saveErr)
+		}
+		return fmt.Errorf("git commit failed: %w; message saved to %s (retry with: git commit -F %s)", err, saved, saved)
+	}
+	return nil
+}
+
+func saveFailedMessage(root, message string) (string, error) {
+	path, err := git.GitPath(root, "GOMMIT_EDITMSG")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(strings.TrimSpace(message)+"\n"), 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
 }
 
-func buildCommitArgs(scope git.DiffScope, messageFile string, noVerify bool) []string {
+func buildCommitArgs(scope git.DiffScope, messageFile string, opts commitOptions) []string {
 	args := []string{"commit"}
-	switch scope {
-	case git.ScopeStagedUnstaged, git.ScopeAll:
+	switch {
+	case len(opts.Pathspecs) > 0:
+		// The paths are committed with --only, git's default for paths.
+	case scope == git.ScopeStagedUnstaged, scope == git.ScopeAll:
 		args = append(args, "-a")
 	}
-	if noVerify {
+	if opts.NoVerify {
 		args = append(args, "--no-verify")
 	}
-	args = append(args, "-F", messageFile)
+	switch opts.GPGSign {
+	case "":
+	case "true":
+		args = append(args, "--gpg-sign")
+	case "false":
+		args = append(args, "--no-gpg-sign")
+	default:
+		args = append(args, "--gpg-sign="+opts.GPGSign)
+	}
+	if opts.Author != "" {
+		args = append(args, "--author="+opts.Author)
+	}
+	if opts.Date != "" {
+		args = append(args, "--date="+opts.Date)
+	}
+	if opts.AllowEmpty {
+		args = append(args, "--allow-empty")
+	}
+	if opts.Cleanup != "" {
+		args = append(args, "--cleanup="+opts.Cleanup)
+	}
+	if opts.Fixup != "" {
+		args = append(args, "--fixup="+opts.Fixup)
+	}
+	if opts.Squash != "" {
+		args = append(args, "--squash="+opts.Squash)
+	}
+	args = append(args, opts.GitArgs...)
+	if messageFile != "" {
+		args = append(args, "-F", messageFile)
+	}
+	if len(opts.Pathspecs) > 0 {
+		args = append(append(args, "--"), opts.Pathspecs...)
+	}
 	return args
 }
 
-func runGitCmd(root string, args ...string) error {
-	cmd := exec.Command("git", args...)
-	cmd.Dir = root
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	return cmd.Run()
+func pathspecsOrRoot(pathspecs []string) []string {
+	if len(pathspecs) == 0 {
+		return []string{"."}
+	}
+	return pathspecs
 }
 
-func changedFilesFromResult(result git.DiffResult) []string {
-	seen := map[string]struct{}{}
-	var out []string
-	for _, chunk := range git.SplitDiffChunks(result.Diff) {
-		path := strings.TrimSpace(git.ParseDiffPath(chunk))
-		if path == "" {
-			continue
-		}
-		path = filepath.Clean(path)
-		if _, ok := seen[path]; ok {
-			continue
-		}
-		seen[path] = struct{}{}
-		out = append(out, path)
+// runGitCmd runs git attached to the terminal. When quiet, git gets no
+// terminal and its output is only reported as part of an error.
+func runGitCmd(root string, quiet bool, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = root
+	if !quiet {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Stdin = os.Stdin
+		return cmd.Run()
 	}
-	for _, bf := range result.Binary {
-		path := strings.TrimSpace(bf.Path)
-		if path == "" {
-			continue
-		}
-		path = filepath.Clean(path)
-		if _, ok := seen[path]; ok {
-			continue
-		}
-		seen[path] = struct{}{}
-		out = append(out, path)
+	out, err := cmd.CombinedOutput()
+	if err != nil && len(bytes.TrimSpace(out)) > 0 {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
 	}
-	return out
+	return err
 }
 
 func dumpLLMContext(client *llm.Client, systemPrompt, userPrompt string) {
@@ -426,6 +782,5 @@ func dumpLLMContext(client *llm.Client, systemPrompt, userPrompt string) {
 }
 
 func fatal(msg string) {
-	fmt.Fprintln(os.Stderr, "gommit:", msg)
-	os.Exit(1)
+	fail(errCodeError, msg)
 }
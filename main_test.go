@@ -1,38 +1,32 @@
 package main
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 
+	"github.com/MenschMachine/gommit/internal/cli"
+	"github.com/MenschMachine/gommit/internal/config"
 	"github.com/MenschMachine/gommit/internal/git"
+	"github.com/MenschMachine/gommit/internal/llm"
+	"github.com/MenschMachine/gommit/internal/usage"
+	"github.com/MenschMachine/gommit/pkg/gommit"
 )
 
-func TestAppendTag(t *testing.T) {
-	tests := []struct {
-		message string
-		tag     string
-		want    string
-	}{
-		{"feat: add login", "", "feat: add login"},
-		{"feat: add login", "skip ci", "feat: add login [skip ci]"},
-		{"feat: add login\n", "skip ci", "feat: add login [skip ci]"},
-		{"feat: add login\n\nbody text", "skip ci", "feat: add login [skip ci]\n\nbody text"},
-		{"feat: add login\n\nline1\nline2", "WIP", "feat: add login [WIP]\n\nline1\nline2"},
-	}
-	for _, tt := range tests {
-		got := appendTag(tt.message, tt.tag)
-		if got != tt.want {
-			t.Errorf("appendTag(%q, %q) = %q, want %q", tt.message, tt.tag, got, tt.want)
-		}
-	}
-}
-
 func TestBuildCommitArgs(t *testing.T) {
 	tests := []struct {
 		name        string
 		scope       git.DiffScope
 		messageFile string
-		noVerify    bool
+		opts        commitOptions
 		want        []string
 	}{
 		{
@@ -45,31 +39,308 @@ func TestBuildCommitArgs(t *testing.T) {
 			name:        "staged with no verify",
 			scope:       git.ScopeStaged,
 			messageFile: "/tmp/msg.txt",
-			noVerify:    true,
+			opts:        commitOptions{NoVerify: true},
 			want:        []string{"commit", "--no-verify", "-F", "/tmp/msg.txt"},
 		},
 		{
 			name:        "staged unstaged with no verify",
 			scope:       git.ScopeStagedUnstaged,
 			messageFile: "/tmp/msg.txt",
-			noVerify:    true,
+			opts:        commitOptions{NoVerify: true},
 			want:        []string{"commit", "-a", "--no-verify", "-F", "/tmp/msg.txt"},
 		},
 		{
 			name:        "all scope with no verify",
 			scope:       git.ScopeAll,
 			messageFile: "/tmp/msg.txt",
-			noVerify:    true,
+			opts:        commitOptions{NoVerify: true},
 			want:        []string{"commit", "-a", "--no-verify", "-F", "/tmp/msg.txt"},
 		},
+		{
+			name:        "signing with default key",
+			scope:       git.ScopeStaged,
+			messageFile: "/tmp/msg.txt",
+			opts:        commitOptions{GPGSign: "true"},
+			want:        []string{"commit", "--gpg-sign", "-F", "/tmp/msg.txt"},
+		},
+		{
+			name:        "passthrough options",
+			scope:       git.ScopeStaged,
+			messageFile: "/tmp/msg.txt",
+			opts: commitOptions{
+				GPGSign:    "ABC123",
+				Author:     "Ada <ada@example.com>",
+				Date:       "2024-01-01",
+				AllowEmpty: true,
+				Cleanup:    "verbatim",
+				Squash:     "HEAD~2",
+				GitArgs:    []string{"--no-post-rewrite"},
+			},
+			want: []string{
+				"commit", "--gpg-sign=ABC123", "--author=Ada <ada@example.com>", "--date=2024-01-01",
+				"--allow-empty", "--cleanup=verbatim", "--squash=HEAD~2", "--no-post-rewrite", "-F", "/tmp/msg.txt",
+			},
+		},
+		{
+			name:        "pathspecs",
+			scope:       git.ScopeAll,
+			messageFile: "/tmp/msg.txt",
+			opts:        commitOptions{Pathspecs: []string{"a.go", "docs"}},
+			want:        []string{"commit", "-F", "/tmp/msg.txt", "--", "a.go", "docs"},
+		},
+		{
+			name:  "fixup uses git's message",
+			scope: git.ScopeStagedUnstaged,
+			opts:  commitOptions{Fixup: "abc123"},
+			want:  []string{"commit", "-a", "--fixup=abc123"},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := buildCommitArgs(tt.scope, tt.messageFile, tt.noVerify)
+			got := buildCommitArgs(tt.scope, tt.messageFile, tt.opts)
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Fatalf("buildCommitArgs(%q, %q, %t) = %v, want %v", tt.scope, tt.messageFile, tt.noVerify, got, tt.want)
+				t.Fatalf("buildCommitArgs(%q, %q, %+v) = %v, want %v", tt.scope, tt.messageFile, tt.opts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitMessagePathspecs(t *testing.T) {
+	root := t.TempDir()
+	for _, key := range []string{"GIT_AUTHOR", "GIT_COMMITTER"} {
+		t.Setenv(key+"_NAME", "t")
+		t.Setenv(key+"_EMAIL", "t@example.com")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "-q")
+	write("a.go", "a\n")
+	write("b.go", "b\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "init")
+
+	write("a.go", "a2\n")
+	write("b.go", "b2\n")
+	write("c.go", "c\n")
+	if err := commitMessage(root, "feat: a", git.ScopeAll, commitOptions{Quiet: true, Pathspecs: []string{"a.go", "c.go"}}); err != nil {
+		t.Fatalf("commit all: %v", err)
+	}
+	if got := run("show", "--name-only", "--format=", "HEAD"); got != "a.go\nc.go" {
+		t.Fatalf("committed %q, want a.go and c.go", got)
+	}
+	if got := run("status", "--porcelain"); got != "M b.go" {
+		t.Fatalf("status %q, want b.go left unstaged", got)
+	}
+
+	run("add", "b.go")
+	write("b.go", "b3\n")
+	err := commitMessage(root, "feat: b", git.ScopeStaged, commitOptions{Quiet: true, Pathspecs: []string{"b.go"}})
+	if err == nil || !strings.Contains(err.Error(), "unstaged changes in b.go") {
+		t.Fatalf("staged commit with unstaged changes in the paths: err = %v", err)
+	}
+}
+
+func TestCommitOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    commitOptions
+		wantErr bool
+	}{
+		{name: "empty", opts: commitOptions{}},
+		{name: "passthrough", opts: commitOptions{GitArgs: []string{"--no-post-rewrite", "--reset-author"}}},
+		{name: "fixup and squash", opts: commitOptions{Fixup: "a", Squash: "b"}, wantErr: true},
+		{name: "bad cleanup", opts: commitOptions{Cleanup: "tidy"}, wantErr: true},
+		{name: "message arg", opts: commitOptions{GitArgs: []string{"-m"}}, wantErr: true},
+		{name: "message arg with value", opts: commitOptions{GitArgs: []string{"--message=hi"}}, wantErr: true},
+		{name: "fixup arg", opts: commitOptions{GitArgs: []string{"--fixup=HEAD"}}, wantErr: true},
+		{name: "attached short value", opts: commitOptions{GitArgs: []string{"-mfoo"}}, wantErr: true},
+		{name: "bundled short flags", opts: commitOptions{GitArgs: []string{"-am"}}, wantErr: true},
+		{name: "bundle with managed flag", opts: commitOptions{GitArgs: []string{"-vF"}}, wantErr: true},
+		{name: "abbreviated long option", opts: commitOptions{GitArgs: []string{"--mess=foo"}}, wantErr: true},
+		{name: "key id after -S", opts: commitOptions{GitArgs: []string{"-Sam"}}},
+		{name: "unmanaged bundle", opts: commitOptions{GitArgs: []string{"-vn"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResponseCacheKeyIncludesBaseURL(t *testing.T) {
+	local := config.Config{Provider: "openai", Model: "m", BaseURL: "http://localhost:8080/v1"}
+	remote := config.Config{Provider: "openai", Model: "m", BaseURL: "https://api.openai.com/v1"}
+	if responseCacheKey(local, "s", "u") == responseCacheKey(remote, "s", "u") {
+		t.Fatal("endpoints with the same model share a cache key")
+	}
+	remote.BaseURL = ""
+	if responseCacheKey(remote, "s", "u") != responseCacheKey(config.Config{Provider: "openai", Model: "m", BaseURL: "https://api.openai.com/v1"}, "s", "u") {
+		t.Fatal("the default base URL gives a different cache key")
+	}
+}
+
+func TestGenerateGuardsStyleRepairs(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// Not a conventional commit, and $1 worth of prompt tokens.
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Added login"}}],
+			"usage":{"prompt_tokens":1000000,"completion_tokens":0}}`))
+	}))
+	defer srv.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.Model, cfg.Style, cfg.LintRetries = "m", "conventional", 2
+	cfg.Prices = map[string]config.Price{"m": {Input: 1}}
+	cfg.Guard.DailyBudget = 1
+	tmpl, err := gommit.LoadTemplate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := llm.NewClient(srv.URL, "key", "m", nil, 5)
+	meter := &usageMeter{ledger: usage.Open(t.TempDir()), provider: "openai", model: "m", prices: cfg.Prices}
+	client.OnUsage = meter.record
+	gen := &generator{cfg: cfg, tmpl: tmpl, client: client, meter: meter}
+
+	out, err := gen.generate(context.Background(), gommit.Diff{Text: "diff --git a/a.go b/a.go\n@@ -1 +1 @@\n+package a"}, generateRequest{})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("sent %d requests, want 1: the repair exceeds the daily budget", n)
+	}
+	if out.Message != "Added login" || len(out.Violations) == 0 {
+		t.Fatalf("got %q with violations %v, want the unrepaired message", out.Message, out.Violations)
+	}
+}
+
+func TestMCPConfigResourceLeavesOutKeySources(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Model = "m"
+	cfg.APIKeyCmd = "pass show openai"
+	cfg.APIKeyFile = "/secret/key"
+	s := &mcpServer{rpcServer: &rpcServer{cfg: cfg}}
+	res, err := s.readResource(context.Background(), []byte(`{"uri":"gommit://config"}`), nil)
+	if err != nil {
+		t.Fatalf("readResource: %v", err)
+	}
+	text := res.(map[string]any)["contents"].([]map[string]string)[0]["text"]
+	if strings.Contains(text, "pass show") || strings.Contains(text, "/secret/key") || !strings.Contains(text, `model = "m"`) {
+		t.Fatalf("config resource:\n%s", text)
+	}
+}
+
+func TestBuildTrailers(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.CoAuthors = map[string]string{"ada": "Ada Lovelace <ada@example.com>"}
+
+	got, err := buildTrailers("", cfg, []string{"ada", "Grace Hopper <grace@example.com>"}, []string{"Reviewed-by=Linus <l@example.com>"}, false)
+	if err != nil {
+		t.Fatalf("buildTrailers: %v", err)
+	}
+	want := []string{
+		"Co-authored-by: Ada Lovelace <ada@example.com>",
+		"Co-authored-by: Grace Hopper <grace@example.com>",
+		"Reviewed-by: Linus <l@example.com>",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("buildTrailers() = %v, want %v", got, want)
+	}
+
+	if _, err := buildTrailers("", cfg, nil, []string{"no value"}, false); err == nil {
+		t.Fatalf("expected error for malformed trailer")
+	}
+	if _, err := buildTrailers("", cfg, []string{" "}, nil, false); err == nil {
+		t.Fatalf("expected error for empty co-author")
+	}
+}
+
+func TestGuardViolations(t *testing.T) {
+	guard := config.GuardConfig{MaxTokens: 1000, MaxCost: 0.01, DailyBudget: 1}
+	tests := []struct {
+		name     string
+		estimate requestEstimate
+		want     int
+	}{
+		{name: "within limits", estimate: requestEstimate{Tokens: 500, Priced: true, Cost: 0.001}},
+		{name: "too many tokens", estimate: requestEstimate{Tokens: 5000}, want: 1},
+		{name: "unpriced skips cost limits", estimate: requestEstimate{Tokens: 500, Cost: 5}},
+		{name: "too expensive", estimate: requestEstimate{Tokens: 500, Priced: true, Cost: 0.02}, want: 1},
+		{name: "over budget", estimate: requestEstimate{Tokens: 500, Priced: true, Cost: 0.005, SpentToday: 0.999}, want: 1},
+		{name: "everything", estimate: requestEstimate{Tokens: 5000, Priced: true, Cost: 2}, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := guardViolations(guard, tt.estimate)
+			if len(got) != tt.want {
+				t.Fatalf("guardViolations = %q, want %d problems", got, tt.want)
 			}
 		})
 	}
 }
+
+// TestReadmeFlags keeps the README's flag list in step with the commit
+// command's definitions.
+func TestLintFlagsAfterRevision(t *testing.T) {
+	app := newApp()
+	fs := app.FlagSet(app.Lookup("lint"))
+	args, err := cli.Parse(fs, []string{"HEAD~1..HEAD", "--format", "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(args, []string{"HEAD~1..HEAD"}) || fs.Lookup("format").Value.String() != "json" {
+		t.Fatalf("args = %q, format = %s", args, fs.Lookup("format").Value)
+	}
+}
+
+func TestReadmeFlags(t *testing.T) {
+	data, err := os.ReadFile("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each entry reads "- `-m`, `--model`: description".
+	var terms []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if term, _, ok := strings.Cut(line, ": "); ok && strings.HasPrefix(term, "- `-") {
+			terms = append(terms, term)
+		}
+	}
+	for _, f := range commitCommand().Flags {
+		long := "`--" + f.Name
+		want := long
+		if f.Short != "" {
+			want = "`-" + f.Short + "`, " + long
+		}
+		found := false
+		for _, term := range terms {
+			if strings.Contains(term, long+"`") || strings.Contains(term, long+" ") || strings.Contains(term, long+"[") {
+				found = true
+				if !strings.HasPrefix(term, "- "+want) {
+					t.Errorf("README documents --%s as %q, want %s", f.Name, term, want)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("README does not document --%s", f.Name)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/MenschMachine/gommit/internal/cli"
+	"github.com/MenschMachine/gommit/internal/history"
+	"github.com/MenschMachine/gommit/internal/rpc"
+	"github.com/MenschMachine/gommit/pkg/gommit"
+)
+
+// mcpProtocolVersions are the Model Context Protocol revisions `gommit mcp`
+// speaks, newest first.
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+const (
+	mcpConfigURI  = "gommit://config"
+	mcpHistoryURI = "gommit://history"
+
+	mcpHistoryLimit = 20
+)
+
+// mcpServer exposes the rpcServer operations as MCP tools and resources.
+type mcpServer struct {
+	*rpcServer
+	tools []mcpTool
+}
+
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+
+	call func(ctx context.Context, args json.RawMessage) (any, error)
+}
+
+type mcpResource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MimeType    string `json:"mimeType"`
+}
+
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type mcpToolResult struct {
+	Content           []mcpContent `json:"content"`
+	StructuredContent any          `json:"structuredContent,omitempty"`
+	IsError           bool         `json:"isError,omitempty"`
+}
+
+type mcpGenerateArgs struct {
+	rpcTarget
+	Hint    string `json:"hint"`
+	NoCache bool   `json:"no_cache"`
+}
+
+type mcpCommitArgs struct {
+	rpcTarget
+	Message  string `json:"message"`
+	Confirm  bool   `json:"confirm"`
+	NoVerify bool   `json:"no_verify"`
+}
+
+func mcpCommand() *cli.Command {
+	var opts serverOptions
+	return &cli.Command{
+		Name:     "mcp",
+		Synopsis: []string{"[options]"},
+		Summary:  "run a Model Context Protocol server",
+		Description: "Runs a Model Context Protocol server on stdin/stdout.\n" +
+			"Tools: get_diff, build_commit_prompt, generate_commit_message, create_commit.\n" +
+			"Resources: " + mcpConfigURI + ", " + mcpHistoryURI + ".",
+		Flags: opts.flags(),
+		Run: func(args []string) error {
+			if len(args) > 0 {
+				return cli.ErrUsage
+			}
+			runMCP(opts)
+			return nil
+		},
+	}
+}
+
+func runMCP(opts serverOptions) {
+	s := newMCPServer(newRPCServer(opts))
+	srv := rpc.NewServer()
+	srv.Handle("initialize", s.initialize)
+	srv.Handle("ping", func(context.Context, json.RawMessage, rpc.Notify) (any, error) {
+		return struct{}{}, nil
+	})
+	srv.Handle("tools/list", s.listTools)
+	srv.Handle("tools/call", s.callTool)
+	srv.Handle("resources/list", s.listResources)
+	srv.Handle("resources/read", s.readResource)
+	serveStdio(srv)
+}
+
+func newMCPServer(rs *rpcServer) *mcpServer {
+	s := &mcpServer{rpcServer: rs}
+	scope := map[string]any{
+		"type":        "string",
+		"enum":        []string{"staged", "unstaged", "all"},
+		"description": "staged (default), staged + unstaged, or all including untracked files",
+	}
+	target := map[string]any{
+		"root":      map[string]any{"type": "string", "description": "repository path; defaults to the server's working directory"},
+		"scope":     scope,
+		"pathspecs": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "limit the diff to these git pathspecs"},
+	}
+	with := func(extra map[string]any) map[string]any {
+		props := map[string]any{}
+		for k, v := range target {
+			props[k] = v
+		}
+		for k, v := range extra {
+			props[k] = v
+		}
+		return props
+	}
+	hint := map[string]any{"type": "string", "description": "extra instructions for the model"}
+	s.tools = []mcpTool{
+		{
+			Name:        "get_diff",
+			Description: "Return the diff gommit would describe, with changed, binary and truncated files.",
+			InputSchema: objectSchema(target),
+			call:        s.getDiff,
+		},
+		{
+			Name:        "build_commit_prompt",
+			Description: "Render the system and user prompts gommit would send for the current changes, without calling a model.",
+			InputSchema: objectSchema(with(map[string]any{"hint": hint})),
+			call:        s.buildPrompt,
+		},
+		{
+			Name:        "generate_commit_message",
+			Description: "Generate a commit message for the current changes with the configured model and style.",
+			InputSchema: objectSchema(with(map[string]any{
+				"hint":     hint,
+				"no_cache": map[string]any{"type": "boolean", "description": "do not answer from the response cache"},
+			})),
+			call: s.generateTool,
+		},
+		{
+			Name: "create_commit",
+			Description: "Commit the changes of the given scope and pathspecs with message. Only call this after the user has " +
+				"approved the message, and pass confirm: true; without it nothing is committed.",
+			InputSchema: objectSchema(map[string]any{
+				"root":      target["root"],
+				"scope":     scope,
+				"pathspecs": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "commit only these git pathspecs; pass the ones the message was generated from"},
+				"message":   map[string]any{"type": "string", "description": "the full commit message"},
+				"confirm":   map[string]any{"type": "boolean", "description": "must be true to create the commit"},
+				"no_verify": map[string]any{"type": "boolean", "description": "skip pre-commit and commit-msg hooks"},
+			}, "message", "confirm"),
+			call: s.createCommit,
+		},
+	}
+	return s
+}
+
+func objectSchema(props map[string]any, required ...string) map[string]any {
+	schema := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (s *mcpServer) initialize(ctx context.Context, raw json.RawMessage, notify rpc.Notify) (any, error) {
+	var params struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	protocol := mcpProtocolVersions[0]
+	if slices.Contains(mcpProtocolVersions, params.ProtocolVersion) {
+		protocol = params.ProtocolVersion
+	}
+	return map[string]any{
+		"protocolVersion": protocol,
+		"capabilities": map[string]any{
+			"tools":     map[string]any{},
+			"resources": map[string]any{},
+		},
+		"serverInfo": map[string]string{"name": "gommit", "version": version},
+	}, nil
+}
+
+func (s *mcpServer) listTools(ctx context.Context, raw json.RawMessage, notify rpc.Notify) (any, error) {
+	return map[string]any{"tools": s.tools}, nil
+}
+
+// callTool runs a tool. Failures of the tool itself are reported in the
+// result with isError so the model can see them; only unknown tools and
+// malformed arguments are protocol errors.
+func (s *mcpServer) callTool(ctx context.Context, raw json.RawMessage, notify rpc.Notify) (any, error) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(s.tools, func(t mcpTool) bool { return t.Name == params.Name })
+	if i < 0 {
+		return nil, &rpc.Error{Code: rpc.InvalidParams, Message: "unknown tool: " + params.Name}
+	}
+	out, err := s.tools[i].call(ctx, params.Arguments)
+	var rpcErr *rpc.Error
+	if errors.As(err, &rpcErr) || errors.Is(err, context.Canceled) {
+		return nil, err
+	}
+	if err != nil {
+		var e jsonError
+		e.Error.Code = errorCode(err)
+		e.Error.Message = err.Error()
+		return mcpToolResult{
+			Content:           []mcpContent{{Type: "text", Text: err.Error()}},
+			StructuredContent: e,
+			IsError:           true,
+		}, nil
+	}
+	text, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcpToolResult{Content: []mcpContent{{Type: "text", Text: string(text)}}, StructuredContent: out}, nil
+}
+
+func (s *mcpServer) getDiff(ctx context.Context, raw json.RawMessage) (any, error) {
+	var args rpcTarget
+	if err := decodeParams(raw, &args); err != nil {
+		return nil, err
+	}
+	c, err := s.collect(ctx, args, false)
+	if err != nil {
+		return nil, err
+	}
+	return c.rpcDiff(), nil
+}
+
+func (s *mcpServer) buildPrompt(ctx context.Context, raw json.RawMessage) (any, error) {
+	var args mcpGenerateArgs
+	if err := decodeParams(raw, &args); err != nil {
+		return nil, err
+	}
+	c, err := s.collect(ctx, args.rpcTarget, true)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.gen.prompt(ctx, c.diff, args.Hint)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"system": p.System, "user": p.User}, nil
+}
+
+func (s *mcpServer) generateTool(ctx context.Context, raw json.RawMessage) (any, error) {
+	var args mcpGenerateArgs
+	if err := decodeParams(raw, &args); err != nil {
+		return nil, err
+	}
+	c, err := s.collect(ctx, args.rpcTarget, true)
+	if err != nil {
+		return nil, err
+	}
+	return s.generateMessage(ctx, c, generateRequest{Hint: args.Hint, ReadCache: !args.NoCache})
+}
+
+func (s *mcpServer) createCommit(ctx context.Context, raw json.RawMessage) (any, error) {
+	var args mcpCommitArgs
+	if err := decodeParams(raw, &args); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(args.Message) == "" {
+		return nil, withCode(errCodeUsage, errors.New("message is required"))
+	}
+	if !args.Confirm {
+		return nil, withCode(errCodeUsage, errors.New("not committed: show the message to the user and call again with \"confirm\": true once they approve"))
+	}
+	if err := s.commitTo(args.rpcTarget, args.Message, commitOptions{NoVerify: args.NoVerify}); err != nil {
+		return nil, err
+	}
+	return map[string]bool{"committed": true}, nil
+}
+
+func (s *mcpServer) listResources(ctx context.Context, raw json.RawMessage, notify rpc.Notify) (any, error) {
+	return map[string]any{"resources": []mcpResource{
+		{URI: mcpConfigURI, Name: "config", Description: "Effective gommit configuration after defaults, file, environment and flags.", MimeType: "application/toml"},
+		{URI: mcpHistoryURI, Name: "history", Description: "Recent commit messages gommit proposed for this repository, newest first.", MimeType: "application/json"},
+	}}, nil
+}
+
+func (s *mcpServer) readResource(ctx context.Context, raw json.RawMessage, notify rpc.Notify) (any, error) {
+	var params struct {
+		URI string `json:"uri"`
+	}
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	var mimeType, text string
+	switch params.URI {
+	case mcpConfigURI:
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(redactConfig(s.cfg)); err != nil {
+			return nil, err
+		}
+		mimeType, text = "application/toml", buf.String()
+	case mcpHistoryURI:
+		entries, err := s.recentHistory()
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		mimeType, text = "application/json", string(data)
+	default:
+		return nil, &rpc.Error{Code: rpc.InvalidParams, Message: "unknown resource: " + params.URI}
+	}
+	return map[string]any{"contents": []map[string]string{{"uri": params.URI, "mimeType": mimeType, "text": text}}}, nil
+}
+
+// redactConfig leaves out how API keys are obtained, which the model has
+// no business reading.
+func redactConfig(cfg gommit.Config) gommit.Config {
+	cfg.APIKeyCmd = ""
+	cfg.APIKeyFile = ""
+	return cfg
+}
+
+// recentHistory lists the newest entries for the server's repository, or
+// for every repository when it was started outside one.
+func (s *mcpServer) recentHistory() ([]history.Entry, error) {
+	entries := []history.Entry{}
+	store := s.gen.history
+	if store == nil {
+		return entries, nil
+	}
+	list, err := store.List(s.root)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) > mcpHistoryLimit {
+		list = list[:mcpHistoryLimit]
+	}
+	return append(entries, list...), nil
+}
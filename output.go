@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MenschMachine/gommit/internal/git"
+	"github.com/MenschMachine/gommit/internal/llm"
+	"github.com/MenschMachine/gommit/internal/prompt"
+	"github.com/MenschMachine/gommit/internal/usage"
+	"github.com/MenschMachine/gommit/pkg/gommit"
+)
+
+// Error codes reported with --output json. They are part of the scripting
+// interface; do not rename them.
+const (
+	errCodeError     = "error"
+	errCodeUsage     = "usage"
+	errCodeConfig    = "config"
+	errCodeGit       = "git"
+	errCodeNoChanges = "no_changes"
+	errCodeLLM       = "llm"
+	errCodeGuard     = "guard"
+	errCodeCommit    = "commit_failed"
+
+	errCodeInterrupted = "interrupted"
+)
+
+// outputJSON is set by --output json; failures are then reported as JSON
+// error objects on stdout.
+var outputJSON bool
+
+type jsonResult struct {
+	Subject    string             `json:"subject"`
+	Body       string             `json:"body"`
+	Message    string             `json:"message"`
+	Trailers   []git.Trailer      `json:"trailers"`
+	Files      []string           `json:"files"`
+	Binaries   []string           `json:"binaries"`
+	Truncated  []string           `json:"truncated_files"`
+	Violations []prompt.Violation `json:"violations"`
+	Provider   string             `json:"provider"`
+	Model      string             `json:"model"`
+	Style      string             `json:"style"`
+	Cached     bool               `json:"cached"`
+	Usage      llm.Usage          `json:"usage"`
+	Cost       *float64           `json:"cost,omitempty"`
+	Committed  bool               `json:"committed"`
+	DurationMS int64              `json:"duration_ms"`
+}
+
+type jsonError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// newJSONResult describes message. Trailers are taken from the last
+// paragraph as git parses it and are left out of Body.
+func newJSONResult(root, message string, diff gommit.Diff, violations []prompt.Violation) (jsonResult, error) {
+	trailers, err := git.ParseTrailers(root, message)
+	if err != nil {
+		return jsonResult{}, err
+	}
+	subject, rest, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	body := strings.TrimSpace(rest)
+	if len(trailers) > 0 {
+		if i := strings.LastIndex(body, "\n\n"); i >= 0 {
+			body = strings.TrimSpace(body[:i])
+		} else {
+			body = ""
+		}
+	}
+	binaries := []string{}
+	for _, b := range diff.Binaries {
+		binaries = append(binaries, b.Path)
+	}
+	res := jsonResult{
+		Subject:    subject,
+		Body:       body,
+		Message:    message,
+		Trailers:   trailers,
+		Files:      diff.Files(),
+		Binaries:   binaries,
+		Truncated:  diff.Truncated,
+		Violations: violations,
+	}
+	if res.Trailers == nil {
+		res.Trailers = []git.Trailer{}
+	}
+	if res.Files == nil {
+		res.Files = []string{}
+	}
+	if res.Truncated == nil {
+		res.Truncated = []string{}
+	}
+	if res.Violations == nil {
+		res.Violations = []prompt.Violation{}
+	}
+	return res, nil
+}
+
+func (m *usageMeter) fill(res *jsonResult) {
+	res.Provider = m.provider
+	res.Model = m.model
+	res.Usage = m.total
+	if price, ok := usage.LookupPrice(m.prices, m.provider, m.model); ok {
+		cost := usage.Cost(m.total, price)
+		res.Cost = &cost
+	}
+}
+
+func writeJSONResult(res jsonResult, start time.Time) {
+	res.DurationMS = time.Since(start).Milliseconds()
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(res); err != nil {
+		fatal(err.Error())
+	}
+}
+
+// fail reports msg and exits 1. With --output json the error is written to
+// stdout as {"error": {"code": ..., "message": ...}}.
+func fail(code, msg string) {
+	if outputJSON {
+		writeJSONError(code, msg)
+	} else {
+		fmt.Fprintln(os.Stderr, "gommit:", msg)
+	}
+	os.Exit(1)
+}
+
+func writeJSONError(code, msg string) {
+	var e jsonError
+	e.Error.Code = code
+	e.Error.Message = msg
+	data, _ := json.Marshal(e)
+	fmt.Println(string(data))
+}
@@ -0,0 +1,29 @@
+package gommit
+
+import "github.com/MenschMachine/gommit/internal/config"
+
+// Config is the gommit configuration, as read from config.toml.
+type Config = config.Config
+
+// DefaultConfig returns the built-in defaults.
+func DefaultConfig() Config {
+	return config.DefaultConfig()
+}
+
+// LoadConfig reads the config file at path, or the default location when
+// path is empty, and applies GOMMIT_* environment overrides.
+func LoadConfig(path string) (Config, error) {
+	if path == "" {
+		var err error
+		path, err = config.DefaultConfigPath()
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return cfg, err
+	}
+	config.ApplyEnvOverrides(&cfg)
+	return cfg, nil
+}
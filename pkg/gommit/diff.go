@@ -0,0 +1,148 @@
+package gommit
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/MenschMachine/gommit/internal/git"
+)
+
+// Scope selects which changes GitDiff collects.
+type Scope = git.DiffScope
+
+const (
+	ScopeStaged         = git.ScopeStaged
+	ScopeStagedUnstaged = git.ScopeStagedUnstaged
+	ScopeAll            = git.ScopeAll // including untracked files
+)
+
+// BinaryFile is a changed file whose content is not shown to the model.
+type BinaryFile = git.BinaryFile
+
+// FileDiff is one file's part of a diff; see Diff.Parse.
+type FileDiff = git.FileDiff
+
+// Hunk is one "@@" section of a FileDiff.
+type Hunk = git.Hunk
+
+// FileStatus is how a file changed.
+type FileStatus = git.FileStatus
+
+const (
+	StatusModified = git.StatusModified
+	StatusAdded    = git.StatusAdded
+	StatusDeleted  = git.StatusDeleted
+	StatusRenamed  = git.StatusRenamed
+	StatusCopied   = git.StatusCopied
+)
+
+// ScopeLabel describes scope for the prompt.
+func ScopeLabel(scope Scope) string {
+	switch scope {
+	case ScopeStagedUnstaged:
+		return "staged + unstaged"
+	case ScopeAll:
+		return "staged + unstaged + untracked"
+	default:
+		return "staged only"
+	}
+}
+
+// Diff is a set of changes to describe.
+type Diff struct {
+	// Root is the repository the changes belong to. Without it Generate
+	// leaves out the branch, recent commits, author and inferred scopes.
+	Root string
+	// Scope describes the changes for the prompt, e.g. "staged only".
+	Scope     string
+	Text      string
+	Binaries  []BinaryFile
+	Truncated []string
+}
+
+// Empty reports whether there is nothing to describe.
+func (d Diff) Empty() bool {
+	return strings.TrimSpace(d.Text) == "" && len(d.Binaries) == 0
+}
+
+// Parse splits the diff text into files. Binary files are only listed in
+// Binaries.
+func (d Diff) Parse() []FileDiff {
+	return git.ParseDiff(d.Text)
+}
+
+// Files lists the changed paths, text files first, without duplicates.
+func (d Diff) Files() []string {
+	seen := map[string]struct{}{}
+	var out []string
+	add := func(path string) {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			return
+		}
+		path = filepath.Clean(path)
+		if _, ok := seen[path]; ok {
+			return
+		}
+		seen[path] = struct{}{}
+		out = append(out, path)
+	}
+	for _, f := range d.Parse() {
+		add(f.Path())
+	}
+	for _, bf := range d.Binaries {
+		add(bf.Path)
+	}
+	return out
+}
+
+// DiffSource supplies the changes to describe.
+type DiffSource interface {
+	Diff(ctx context.Context) (Diff, error)
+}
+
+// GitDiff reads changes from a git repository. An empty Root means the
+// repository of the working directory; PerFileLimit caps each file's diff
+// (0 = no limit).
+type GitDiff struct {
+	Root         string
+	Scope        Scope
+	Pathspecs    []string
+	PerFileLimit int
+}
+
+func (g GitDiff) Diff(ctx context.Context) (Diff, error) {
+	root := g.Root
+	var err error
+	if root == "" {
+		root, err = git.RepoRoot()
+	} else {
+		root, err = git.RepoRootAt(root)
+	}
+	if err != nil {
+		return Diff{}, err
+	}
+	result, err := git.CollectDiffPaths(ctx, root, g.Scope, g.PerFileLimit, g.Pathspecs)
+	if err != nil {
+		return Diff{}, err
+	}
+	return Diff{
+		Root:      root,
+		Scope:     ScopeLabel(g.Scope),
+		Text:      result.Diff,
+		Binaries:  result.Binary,
+		Truncated: result.TruncatedFiles,
+	}, nil
+}
+
+// StaticDiff is a DiffSource for changes collected beforehand.
+func StaticDiff(d Diff) DiffSource {
+	return staticDiff(d)
+}
+
+type staticDiff Diff
+
+func (d staticDiff) Diff(context.Context) (Diff, error) {
+	return Diff(d), nil
+}
@@ -0,0 +1,49 @@
+package gommit
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/MenschMachine/gommit/internal/goapi"
+)
+
+// GoAPIReport summarises a change to Go code: exported API added, removed
+// or changed outside internal and main packages, go.mod requirement
+// changes, and whether only tests changed.
+type GoAPIReport = goapi.Report
+
+// GoAPI analyses the Go files and go.mod files of d. Without d.Root only
+// whether the change is test-only is known. It returns nil when there is
+// nothing to report.
+func GoAPI(ctx context.Context, d Diff) (*GoAPIReport, error) {
+	var sources []source
+	if d.Root != "" {
+		var err error
+		if sources, err = readSources(ctx, d, goapi.Wants); err != nil {
+			return nil, err
+		}
+	}
+	return goAPIOf(d, sources), nil
+}
+
+// goAPIOf compares the Go files and go.mod among sources; goapi.Analyze
+// skips the rest.
+func goAPIOf(d Diff, sources []source) *GoAPIReport {
+	var paths []string
+	for _, f := range d.Files() {
+		paths = append(paths, filepath.ToSlash(f))
+	}
+	var files []goapi.File
+	for _, src := range sources {
+		oldPath := src.oldPath
+		if oldPath == "" {
+			oldPath = src.path
+		}
+		files = append(files, goapi.File{OldPath: oldPath, NewPath: src.path, Old: src.old, New: src.new})
+	}
+	report := goapi.Analyze(paths, files)
+	if report.Empty() {
+		return nil
+	}
+	return &report
+}
@@ -0,0 +1,421 @@
+// Package gommit generates commit messages from repository changes with an
+// LLM. It is the library behind the gommit command: it never writes to
+// stdout or exits, and every step can be replaced through Options.
+//
+//	msg, err := gommit.Generate(ctx, gommit.NewOptions(
+//		gommit.WithRepo("."),
+//		gommit.WithLLM("openai", "gpt-4o-mini", ""),
+//	))
+package gommit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/MenschMachine/gommit/internal/git"
+	"github.com/MenschMachine/gommit/internal/issue"
+	"github.com/MenschMachine/gommit/internal/prompt"
+	"github.com/MenschMachine/gommit/internal/scopes"
+	"github.com/MenschMachine/gommit/internal/symbols"
+)
+
+// RecentCommitCount is how many recent subjects are shown to the model.
+const RecentCommitCount = 10
+
+// ErrNoChanges is returned when the diff source has nothing to describe.
+var ErrNoChanges = errors.New("no changes found for selected diff scope")
+
+// ErrSkipRepair may be returned by a Provider for a Repair request to stop
+// re-prompting, e.g. when a budget does not allow another request. Generate
+// then keeps the best message so far.
+var ErrSkipRepair = errors.New("style repair skipped")
+
+// Step names the part of Generate that failed.
+type Step string
+
+const (
+	StepSetup    Step = "setup"    // loading the template or creating the client
+	StepDiff     Step = "diff"     // reading the diff source
+	StepContext  Step = "context"  // branch and recent commits
+	StepPrompt   Step = "prompt"   // building the prompt
+	StepProvider Step = "provider" // the model request
+	StepIssue    Step = "issue"    // issue keys from the branch
+	StepTrailers Step = "trailers" // adding trailers
+)
+
+// Error reports which step of Generate failed.
+type Error struct {
+	Step Step
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+func stepError(step Step, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Step: step, Err: err}
+}
+
+// Options configures one Generate call. Build it with NewOptions, which
+// starts from DefaultConfig, or fill the fields directly. Nil interfaces
+// get the defaults described on each field.
+type Options struct {
+	Config Config
+
+	// Root, Scope and Pathspecs select the changes when Diff is nil. An
+	// empty Root means the repository of the working directory.
+	Root      string
+	Scope     Scope
+	Pathspecs []string
+
+	// Template supplies the commit style and, without Prompt, the prompts.
+	// Defaults to LoadTemplate(Config).
+	Template *Template
+	// Diff defaults to a GitDiff of Root, Scope and Pathspecs.
+	Diff DiffSource
+	// Prompt defaults to rendering Template.
+	Prompt PromptBuilder
+	// Provider defaults to a ClientProvider for NewClient(Config).
+	Provider Provider
+
+	// Hint is extra guidance for the model.
+	Hint string
+	// Trailers are added with git interpret-trailers, e.g. "Signed-off-by: A <a@b>".
+	Trailers []string
+	// Tag is appended to the subject as " [tag]".
+	Tag string
+	// OnDelta, if set, streams the first completion as it arrives.
+	OnDelta func(string)
+	// OnWarning, if set, is told about optional parts of the prompt, such
+	// as the changed symbols, that were left out because they failed.
+	OnWarning func(error)
+}
+
+// Option changes Options.
+type Option func(*Options)
+
+// NewOptions returns Options with the default config and opts applied.
+func NewOptions(opts ...Option) Options {
+	o := Options{Config: DefaultConfig()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithConfig replaces the config, e.g. with one from LoadConfig.
+func WithConfig(cfg Config) Option {
+	return func(o *Options) { o.Config = cfg }
+}
+
+// WithLLM selects the API (openai, openrouter, anthropic), model and base
+// URL used by the default Provider. Empty values keep the config's.
+func WithLLM(provider, model, baseURL string) Option {
+	return func(o *Options) {
+		if provider != "" {
+			o.Config.Provider = provider
+		}
+		if model != "" {
+			o.Config.Model = model
+		}
+		if baseURL != "" {
+			o.Config.BaseURL = baseURL
+		}
+	}
+}
+
+// WithStyle selects the commit style (conventional, gitmoji, ...).
+func WithStyle(style string) Option {
+	return func(o *Options) { o.Config.Style = style }
+}
+
+// WithRepo reads the changes of the repository at root.
+func WithRepo(root string) Option {
+	return func(o *Options) { o.Root = root }
+}
+
+func WithScope(scope Scope) Option {
+	return func(o *Options) { o.Scope = scope }
+}
+
+// WithPathspecs limits the diff to the given git pathspecs.
+func WithPathspecs(pathspecs ...string) Option {
+	return func(o *Options) { o.Pathspecs = append(o.Pathspecs, pathspecs...) }
+}
+
+func WithTemplate(t *Template) Option {
+	return func(o *Options) { o.Template = t }
+}
+
+func WithDiffSource(d DiffSource) Option {
+	return func(o *Options) { o.Diff = d }
+}
+
+func WithPromptBuilder(b PromptBuilder) Option {
+	return func(o *Options) { o.Prompt = b }
+}
+
+func WithProvider(p Provider) Option {
+	return func(o *Options) { o.Provider = p }
+}
+
+func WithHint(hint string) Option {
+	return func(o *Options) { o.Hint = hint }
+}
+
+func WithTrailers(trailers ...string) Option {
+	return func(o *Options) { o.Trailers = append(o.Trailers, trailers...) }
+}
+
+func WithTag(tag string) Option {
+	return func(o *Options) { o.Tag = tag }
+}
+
+// WithStream streams the first completion to onDelta.
+func WithStream(onDelta func(string)) Option {
+	return func(o *Options) { o.OnDelta = onDelta }
+}
+
+// WithWarnings reports optional prompt parts that failed to onWarning.
+func WithWarnings(onWarning func(error)) Option {
+	return func(o *Options) { o.OnWarning = onWarning }
+}
+
+// Message is a generated commit message.
+type Message struct {
+	Text string
+	// Violations lists the style rules the message still breaks after
+	// Config.LintRetries repair attempts.
+	Violations []Violation
+	// Style is the name of the commit style the message was checked against.
+	Style string
+	// Diff is what the message describes.
+	Diff Diff
+}
+
+func (m Message) String() string { return m.Text }
+
+// Subject returns the first line of the message.
+func (m Message) Subject() string {
+	subject, _, _ := strings.Cut(m.Text, "\n")
+	return subject
+}
+
+// Generate describes the changes of opts.Diff in a commit message: it asks
+// the provider, repairs style violations, and adds issue keys, trailers and
+// the tag. Failures are returned as *Error.
+func Generate(ctx context.Context, opts Options) (Message, error) {
+	r, err := prepare(ctx, opts)
+	if err != nil {
+		return Message{}, err
+	}
+	return r.generate(ctx)
+}
+
+// RenderPrompt returns the prompt Generate would send, without calling the
+// provider.
+func RenderPrompt(ctx context.Context, opts Options) (Prompt, error) {
+	r, err := prepare(ctx, opts)
+	if err != nil {
+		return Prompt{}, err
+	}
+	p, err := r.prompt.Build(r.data)
+	return p, stepError(StepPrompt, err)
+}
+
+// run is one Generate call with the defaults resolved.
+type run struct {
+	opts      Options
+	diff      Diff
+	data      PromptData
+	style     Style
+	prompt    PromptBuilder
+	issueKeys []string
+}
+
+func prepare(ctx context.Context, opts Options) (*run, error) {
+	cfg := opts.Config
+	tmpl := opts.Template
+	if tmpl == nil {
+		var err error
+		if tmpl, err = LoadTemplate(cfg); err != nil {
+			return nil, stepError(StepSetup, err)
+		}
+	}
+	// Checked up front so a bad placement fails before the request is paid for.
+	if err := issue.CheckPlacement(cfg.Issue.Placement); err != nil {
+		return nil, stepError(StepSetup, err)
+	}
+	if opts.Diff == nil {
+		opts.Diff = GitDiff{Root: opts.Root, Scope: opts.Scope, Pathspecs: opts.Pathspecs, PerFileLimit: cfg.PerFileLimit}
+	}
+	if opts.Provider == nil {
+		client, err := NewClient(ctx, cfg)
+		if err != nil {
+			return nil, stepError(StepSetup, err)
+		}
+		opts.Provider = ClientProvider{Client: client}
+	}
+
+	diff, err := opts.Diff.Diff(ctx)
+	if err != nil {
+		return nil, stepError(StepDiff, err)
+	}
+	if diff.Empty() {
+		return nil, stepError(StepDiff, ErrNoChanges)
+	}
+
+	r := &run{opts: opts, diff: diff, style: tmpl.Style}
+	r.data = PromptData{
+		Diff:      diff.Text,
+		Binaries:  diff.Binaries,
+		Truncated: diff.Truncated,
+		Scope:     diff.Scope,
+		Hint:      opts.Hint,
+	}
+	// Without a repository there is no branch, history or layout to use.
+	var sources []source
+	if diff.Root != "" {
+		branch, err := git.CurrentBranch(diff.Root)
+		if err != nil {
+			return nil, stepError(StepContext, err)
+		}
+		r.issueKeys, err = issue.Keys(branch, cfg.Issue.Pattern)
+		if err != nil {
+			return nil, stepError(StepIssue, err)
+		}
+		recent, err := git.RecentCommits(diff.Root, RecentCommitCount)
+		if err != nil {
+			return nil, stepError(StepContext, err)
+		}
+		r.data.Branch = branch
+		r.data.Author = git.Author(diff.Root)
+		r.data.RecentCommits = recent
+		if sources, err = r.readSources(ctx); err != nil {
+			return nil, err
+		}
+		// Inferred scopes are only a preference: the allowed scopes stay
+		// those of the template, which gommit lint checks too.
+		r.data.CommitScopes = scopes.Infer(diff.Root, diff.Files(), scopeOptions(cfg.Scopes))
+		if allowed := r.style.Scopes; len(allowed) > 0 {
+			r.data.CommitScopes = slices.DeleteFunc(r.data.CommitScopes, func(s string) bool { return !slices.Contains(allowed, s) })
+		}
+	}
+
+	r.data.GoAPI = goAPIOf(diff, sources)
+
+	r.prompt = opts.Prompt
+	if r.prompt == nil {
+		r.prompt = TemplatePrompt(tmpl, cfg.MaxPromptChars)
+	}
+	return r, nil
+}
+
+// optional reports an error of an optional part of the prompt to
+// Options.OnWarning, so generation goes on without it. Only cancellation is
+// returned.
+func (r *run) optional(ctx context.Context, what string, err error) error {
+	if ctx.Err() != nil {
+		return stepError(StepContext, ctx.Err())
+	}
+	if r.opts.OnWarning != nil {
+		r.opts.OnWarning(fmt.Errorf("%s left out: %w", what, err))
+	}
+	return nil
+}
+
+// readSources reads the changed text files once for the symbol summary and
+// the generated files, which it fills in, and the Go API report. Failures
+// only leave them out.
+func (r *run) readSources(ctx context.Context) ([]source, error) {
+	cfg := r.opts.Config.Symbols
+	var rules []symbols.Rule
+	if !cfg.Disable {
+		var err error
+		if rules, err = symbolRules(cfg); err != nil {
+			if err := r.optional(ctx, "changed symbols", err); err != nil {
+				return nil, err
+			}
+			cfg.Disable = true
+		}
+	}
+	// Any file may carry the generated code marker.
+	sources, err := readSources(ctx, r.diff, func(string) bool { return true })
+	if err != nil {
+		return nil, r.optional(ctx, "changed symbols and Go API", err)
+	}
+	for _, src := range sources {
+		if src.generated {
+			r.data.Generated = append(r.data.Generated, src.path)
+		}
+	}
+	if !cfg.Disable {
+		r.data.Symbols = symbolsOf(sources, rules)
+	}
+	return sources, nil
+}
+
+func (r *run) generate(ctx context.Context) (Message, error) {
+	p, err := r.prompt.Build(r.data)
+	if err != nil {
+		return Message{}, stepError(StepPrompt, err)
+	}
+	text, err := r.opts.Provider.Complete(ctx, Request{System: p.System, User: p.User, OnDelta: r.opts.OnDelta})
+	if err != nil {
+		return Message{}, stepError(StepProvider, err)
+	}
+	msg := Message{Style: r.style.Name, Diff: r.diff}
+	msg.Text, msg.Violations, err = r.repair(ctx, text)
+	if err != nil {
+		return Message{}, err
+	}
+	msg.Text, err = addIssueKeys(r.diff.Root, msg.Text, r.issueKeys, r.opts.Config.Issue, r.style)
+	if err != nil {
+		return Message{}, stepError(StepIssue, err)
+	}
+	if len(r.opts.Trailers) > 0 {
+		msg.Text, err = git.InterpretTrailers(r.diff.Root, msg.Text, r.opts.Trailers)
+		if err != nil {
+			return Message{}, stepError(StepTrailers, err)
+		}
+	}
+	msg.Text = appendTag(msg.Text, r.opts.Tag)
+	// Issue keys, trailers and the tag may break the style too, e.g. push
+	// the subject over its length.
+	msg.Violations = r.style.Validate(msg.Text)
+	return msg, nil
+}
+
+// repair fixes what it can of message locally and re-prompts the model with
+// the remaining violations up to Config.LintRetries times. The attempt with
+// the fewest violations wins.
+func (r *run) repair(ctx context.Context, message string) (string, []Violation, error) {
+	message = r.style.Fix(message)
+	violations := r.style.Validate(message)
+	data := r.data
+	for attempt := 0; attempt < r.opts.Config.LintRetries && len(violations) > 0; attempt++ {
+		data.Hint = strings.TrimSpace(r.data.Hint + "\n" + prompt.RepairHint(message, violations))
+		p, err := r.prompt.Build(data)
+		if err != nil {
+			return "", nil, stepError(StepPrompt, err)
+		}
+		candidate, err := r.opts.Provider.Complete(ctx, Request{System: p.System, User: p.User, Repair: true})
+		if errors.Is(err, ErrSkipRepair) {
+			break
+		}
+		if err != nil {
+			return "", nil, stepError(StepProvider, err)
+		}
+		candidate = r.style.Fix(candidate)
+		if remaining := r.style.Validate(candidate); len(remaining) <= len(violations) {
+			message, violations = candidate, remaining
+		}
+	}
+	return message, violations, nil
+}
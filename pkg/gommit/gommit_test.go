@@ -0,0 +1,199 @@
+package gommit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/MenschMachine/gommit/internal/config"
+)
+
+const testDiff = `diff --git a/login.go b/login.go
+--- a/login.go
++++ b/login.go
+@@ -1 +1,2 @@
+ package auth
++func Login() {}
+`
+
+func TestGenerate(t *testing.T) {
+	var requests []Request
+	answers := []string{"Added login.", "feat: add login"}
+	provider := ProviderFunc(func(ctx context.Context, req Request) (string, error) {
+		requests = append(requests, req)
+		answer := answers[0]
+		answers = answers[1:]
+		return answer, nil
+	})
+
+	opts := NewOptions(
+		WithDiffSource(StaticDiff(Diff{Scope: "staged only", Text: testDiff})),
+		WithProvider(provider),
+		WithStyle("conventional"),
+		WithTag("skip ci"),
+	)
+	opts.Config.LintRetries = 1
+	msg, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if msg.Text != "feat: add login [skip ci]" {
+		t.Errorf("Text = %q", msg.Text)
+	}
+	if len(msg.Violations) != 0 || msg.Style != "conventional" {
+		t.Errorf("Violations = %v, Style = %q", msg.Violations, msg.Style)
+	}
+	if len(requests) != 2 || requests[0].Repair || !requests[1].Repair {
+		t.Fatalf("requests = %+v, want a request and a repair", requests)
+	}
+	if !strings.Contains(requests[0].User, "+func Login() {}") {
+		t.Errorf("user prompt does not contain the diff:\n%s", requests[0].User)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	failing := errors.New("boom")
+	tests := []struct {
+		name     string
+		diff     Diff
+		provider Provider
+		step     Step
+		is       error
+	}{
+		{"no changes", Diff{}, nil, StepDiff, ErrNoChanges},
+		{"provider", Diff{Text: testDiff}, ProviderFunc(func(context.Context, Request) (string, error) {
+			return "", failing
+		}), StepProvider, failing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := tt.provider
+			if provider == nil {
+				provider = ProviderFunc(func(context.Context, Request) (string, error) { return "feat: x", nil })
+			}
+			_, err := Generate(context.Background(), NewOptions(WithDiffSource(StaticDiff(tt.diff)), WithProvider(provider)))
+			var genErr *Error
+			if !errors.As(err, &genErr) || genErr.Step != tt.step || !errors.Is(err, tt.is) {
+				t.Fatalf("err = %v, want %v at step %s", err, tt.is, tt.step)
+			}
+		})
+	}
+}
+
+func TestGenerateChecksPlacementBeforeRequest(t *testing.T) {
+	requests := 0
+	opts := NewOptions(
+		WithDiffSource(StaticDiff(Diff{Text: testDiff})),
+		WithProvider(ProviderFunc(func(context.Context, Request) (string, error) {
+			requests++
+			return "feat: x", nil
+		})),
+	)
+	opts.Config.Issue.Placement = "suffix"
+	_, err := Generate(context.Background(), opts)
+	var genErr *Error
+	if !errors.As(err, &genErr) || genErr.Step != StepSetup || requests != 0 {
+		t.Fatalf("err = %v after %d requests, want a setup error before any", err, requests)
+	}
+}
+
+func TestGenerateWarnsAboutOptionalContext(t *testing.T) {
+	root := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", root).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	var warnings []error
+	opts := NewOptions(
+		WithDiffSource(StaticDiff(Diff{Root: root, Text: testDiff})),
+		WithProvider(ProviderFunc(func(context.Context, Request) (string, error) { return "feat: add login", nil })),
+		WithWarnings(func(err error) { warnings = append(warnings, err) }),
+	)
+	// A broken rule makes the changed symbols fail.
+	opts.Config.Symbols.Rules = []config.SymbolRule{{Glob: "**/*.go", Kind: "func", Pattern: "("}}
+	msg, err := Generate(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if msg.Text != "feat: add login" || len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "changed symbols") {
+		t.Fatalf("message %q, warnings %v", msg.Text, warnings)
+	}
+}
+
+func TestGenerateSuggestsOnlyAllowedScopes(t *testing.T) {
+	root := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", root).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	diff := "diff --git a/api/api.go b/api/api.go\n@@ -1 +1 @@\n-a\n+b\n" +
+		"diff --git a/.github/ci.yml b/.github/ci.yml\n@@ -1 +1 @@\n-a\n+b\n"
+	var got []string
+	opts := NewOptions(
+		WithDiffSource(StaticDiff(Diff{Root: root, Text: diff})),
+		WithPromptBuilder(PromptBuilderFunc(func(data PromptData) (Prompt, error) {
+			got = data.CommitScopes
+			return Prompt{}, nil
+		})),
+		WithProvider(ProviderFunc(func(context.Context, Request) (string, error) { return "", nil })),
+	)
+	opts.Config.Lint.Scopes = []string{"api"}
+	opts.Config.Scopes = config.ScopesConfig{Derive: "dir", Rules: []config.ScopeRule{{Glob: ".github/**", Scope: "ci"}}}
+	if _, err := RenderPrompt(context.Background(), opts); err != nil {
+		t.Fatalf("RenderPrompt: %v", err)
+	}
+	// gommit lint would reject "ci", so it must not be suggested.
+	if !slices.Equal(got, []string{"api"}) {
+		t.Fatalf("CommitScopes = %q, want [api]", got)
+	}
+}
+
+func TestGenerateFindsGeneratedFilesByContent(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", root, "-c", "user.name=A", "-c", "user.email=a@b.c"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(body string) {
+		if err := os.WriteFile(filepath.Join(root, "api.pb.txt"), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	head := "// Code generated by protoc. DO NOT EDIT.\n" + strings.Repeat("line\n", 20)
+	write(head + "old\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+	write(head + "new\n")
+
+	var got []string
+	opts := NewOptions(
+		WithRepo(root),
+		WithScope(ScopeStagedUnstaged),
+		WithPromptBuilder(PromptBuilderFunc(func(data PromptData) (Prompt, error) {
+			got = data.Generated
+			return Prompt{}, nil
+		})),
+		WithProvider(ProviderFunc(func(context.Context, Request) (string, error) { return "", nil })),
+	)
+	if _, err := RenderPrompt(context.Background(), opts); err != nil {
+		t.Fatalf("RenderPrompt: %v", err)
+	}
+	if !slices.Equal(got, []string{"api.pb.txt"}) {
+		t.Fatalf("Generated = %q, want [api.pb.txt]", got)
+	}
+}
+
+func TestDiffFiles(t *testing.T) {
+	d := Diff{Text: testDiff, Binaries: []BinaryFile{{Path: "logo.png"}, {Path: "login.go"}}}
+	got := strings.Join(d.Files(), ",")
+	if got != "login.go,logo.png" {
+		t.Fatalf("Files() = %q", got)
+	}
+}
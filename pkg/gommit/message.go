@@ -0,0 +1,58 @@
+package gommit
+
+import (
+	"strings"
+
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/git"
+	"github.com/MenschMachine/gommit/internal/issue"
+)
+
+// addIssueKeys references keys in message according to the [issue] config,
+// unless the message already mentions all of them. A prefix goes after the
+// header style requires at the start of the subject.
+func addIssueKeys(root, message string, keys []string, cfg config.IssueConfig, style Style) (string, error) {
+	if len(keys) == 0 || issue.Mentioned(message, keys) {
+		return message, nil
+	}
+	placement := cfg.Placement
+	if placement == "" {
+		placement = issue.PlacementFooter
+	}
+	tmpl := cfg.Template
+	if tmpl == "" {
+		tmpl = issue.DefaultTemplate(placement)
+	}
+	text, err := issue.Render(tmpl, keys)
+	if err != nil {
+		return "", err
+	}
+	switch placement {
+	case issue.PlacementPrefix:
+		return issue.AddPrefix(message, text, style.HeaderLen(message)), nil
+	case issue.PlacementFooter:
+		return issue.AddFooter(message, text), nil
+	case issue.PlacementTrailer:
+		return git.InterpretTrailers(root, message, []string{strings.TrimSpace(text)})
+	default:
+		return "", issue.CheckPlacement(placement)
+	}
+}
+
+// appendTag adds " [tag]" to the subject of message unless it is already
+// there.
+func appendTag(message, tag string) string {
+	if tag == "" {
+		return message
+	}
+	subject, body, hasBody := strings.Cut(strings.TrimRight(message, "\n"), "\n")
+	suffix := " [" + tag + "]"
+	if strings.HasSuffix(subject, suffix) {
+		return strings.TrimRight(message, "\n")
+	}
+	subject = strings.TrimRight(subject, " ") + suffix
+	if hasBody {
+		return subject + "\n" + body
+	}
+	return subject
+}
@@ -0,0 +1,60 @@
+package gommit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/prompt"
+)
+
+func TestAppendTag(t *testing.T) {
+	tests := []struct {
+		message string
+		tag     string
+		want    string
+	}{
+		{"feat: add login", "", "feat: add login"},
+		{"feat: add login", "skip ci", "feat: add login [skip ci]"},
+		{"feat: add login\n", "skip ci", "feat: add login [skip ci]"},
+		{"feat: add login\n\nbody text", "skip ci", "feat: add login [skip ci]\n\nbody text"},
+		{"feat: add login\n\nline1\nline2", "WIP", "feat: add login [WIP]\n\nline1\nline2"},
+		{"feat: add login [skip ci]\n\nbody text", "skip ci", "feat: add login [skip ci]\n\nbody text"},
+	}
+	for _, tt := range tests {
+		got := appendTag(tt.message, tt.tag)
+		if got != tt.want {
+			t.Errorf("appendTag(%q, %q) = %q, want %q", tt.message, tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestAddIssueKeys(t *testing.T) {
+	tests := []struct {
+		name      string
+		message   string
+		placement string
+		want      string
+	}{
+		{"footer", "feat: add login\n\nbody", "footer", "feat: add login\n\nbody\n\nRefs: PROJ-1234"},
+		{"prefix", "add login", "prefix", "PROJ-1234: add login"},
+		{"prefix after header", "feat(api)!: add login", "prefix", "feat(api)!: PROJ-1234: add login"},
+		{"already mentioned", "PROJ-1234: add login", "footer", "PROJ-1234: add login"},
+		{"footer joins trailers", "fix: x\n\nbody\n\nSigned-off-by: A <a@b.c>", "footer", "fix: x\n\nbody\n\nSigned-off-by: A <a@b.c>\nRefs: PROJ-1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			style, _ := prompt.LookupStyle("conventional")
+			got, err := addIssueKeys("", tt.message, []string{"PROJ-1234"}, config.IssueConfig{Placement: tt.placement}, style)
+			if err != nil {
+				t.Fatalf("addIssueKeys: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("addIssueKeys() = %q, want %q", got, tt.want)
+			}
+			if tagged := appendTag(appendTag(got, "skip ci"), "skip ci"); strings.Count(tagged, "[skip ci]") != 1 {
+				t.Fatalf("expected a single tag, got %q", tagged)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package gommit
+
+import (
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/prompt"
+	"github.com/MenschMachine/gommit/internal/scopes"
+)
+
+type (
+	// Template renders the prompts of a commit style.
+	Template = prompt.Template
+	// Style holds the rules of a commit style (conventional, gitmoji, ...).
+	Style = prompt.Style
+	// Violation is a broken style rule.
+	Violation = prompt.Violation
+	// PromptData is what a prompt is built from.
+	PromptData = prompt.Data
+	// Change is one row of the change summary in PromptData.
+	Change = prompt.Change
+	// FileDetail is the detail level a file's diff was reduced to.
+	FileDetail = prompt.FileDetail
+)
+
+// Prompt is a rendered request.
+type Prompt struct {
+	System string
+	User   string
+	// Files report how much of each file's diff the user prompt holds when
+	// it was reduced to fit the budget.
+	Files []FileDetail
+}
+
+// PromptBuilder turns the collected changes and context into a prompt.
+type PromptBuilder interface {
+	Build(data PromptData) (Prompt, error)
+}
+
+// PromptBuilderFunc adapts a function to PromptBuilder.
+type PromptBuilderFunc func(data PromptData) (Prompt, error)
+
+func (f PromptBuilderFunc) Build(data PromptData) (Prompt, error) { return f(data) }
+
+// TemplatePrompt renders t, trimming the user prompt to maxChars
+// (0 = no limit).
+func TemplatePrompt(t *Template, maxChars int) PromptBuilder {
+	return PromptBuilderFunc(func(data PromptData) (Prompt, error) {
+		system, user, files, err := t.RenderDetail(data, maxChars)
+		return Prompt{System: system, User: user, Files: files}, err
+	})
+}
+
+// LoadTemplate loads the template of cfg.Style with the configured
+// template overrides and [lint] rules applied.
+func LoadTemplate(cfg Config) (*Template, error) {
+	systemTemplateFile, err := config.ExpandHome(cfg.SystemTemplateFile)
+	if err != nil {
+		return nil, err
+	}
+	userTemplateFile, err := config.ExpandHome(cfg.UserTemplateFile)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := prompt.LoadTemplate(
+		cfg.Style,
+		prompt.TemplateSource{Inline: cfg.SystemTemplate, File: systemTemplateFile},
+		prompt.TemplateSource{Inline: cfg.UserTemplate, File: userTemplateFile},
+	)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.Style = applyLintRules(tmpl.Style, cfg.Lint)
+	tmpl.Important = cfg.ImportantFiles
+	if cfg.Scopes.Derive == "" && len(cfg.Scopes.Rules) > 0 {
+		// Without a derive mode the rules name every valid scope.
+		tmpl.Style.Scopes = mergeScopes(tmpl.Style.Scopes, scopes.Names(scopeOptions(cfg.Scopes).Rules))
+	}
+	return tmpl, nil
+}
+
+// applyLintRules overrides the built-in rules of style with those set in
+// the [lint] config section.
+func applyLintRules(style Style, rules config.LintConfig) Style {
+	if len(rules.Types) > 0 {
+		style.Types = rules.Types
+	}
+	if len(rules.Scopes) > 0 {
+		style.Scopes = rules.Scopes
+	}
+	if rules.RequireScope {
+		style.RequireScope = true
+	}
+	if rules.SubjectMax > 0 {
+		style.SubjectMax = rules.SubjectMax
+	}
+	if rules.BodyWrap > 0 {
+		style.BodyWrap = rules.BodyWrap
+	}
+	if len(rules.RequiredTrailers) > 0 {
+		style.RequiredTrailers = rules.RequiredTrailers
+	}
+	return style
+}
+
+func scopeOptions(cfg config.ScopesConfig) scopes.Options {
+	opts := scopes.Options{Derive: cfg.Derive}
+	for _, rule := range cfg.Rules {
+		opts.Rules = append(opts.Rules, scopes.Rule{Glob: rule.Glob, Scope: rule.Scope})
+	}
+	return opts
+}
+
+func mergeScopes(lists ...[]string) []string {
+	seen := map[string]struct{}{}
+	var out []string
+	for _, list := range lists {
+		for _, item := range list {
+			if _, ok := seen[item]; ok {
+				continue
+			}
+			seen[item] = struct{}{}
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,91 @@
+package gommit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/llm"
+)
+
+type (
+	// Client talks to an OpenAI-compatible chat completions API.
+	Client = llm.Client
+	// Usage counts the tokens of one or more completions.
+	Usage = llm.Usage
+)
+
+// Request is one completion asked of a Provider.
+type Request struct {
+	System string
+	User   string
+	// Repair is set on follow-up requests that ask the model to fix style
+	// violations of a previous answer.
+	Repair bool
+	// OnDelta, if set, receives the answer as it streams in.
+	OnDelta func(string)
+}
+
+// Provider answers prompts. Wrap one to add caching, budgets or logging.
+type Provider interface {
+	Complete(ctx context.Context, req Request) (string, error)
+}
+
+// ProviderFunc adapts a function to Provider.
+type ProviderFunc func(ctx context.Context, req Request) (string, error)
+
+func (f ProviderFunc) Complete(ctx context.Context, req Request) (string, error) { return f(ctx, req) }
+
+// ClientProvider is a Provider backed by a Client.
+type ClientProvider struct {
+	Client *Client
+}
+
+func (p ClientProvider) Complete(ctx context.Context, req Request) (string, error) {
+	if req.OnDelta != nil {
+		return p.Client.ChatCompletionStream(ctx, req.System, req.User, req.OnDelta)
+	}
+	return p.Client.ChatCompletion(ctx, req.System, req.User)
+}
+
+// ProviderName returns the normalized provider of cfg, defaulting to openai.
+func ProviderName(cfg Config) string {
+	provider := strings.ToLower(strings.TrimSpace(cfg.Provider))
+	if provider == "" {
+		return "openai"
+	}
+	return provider
+}
+
+// NewClient builds a client for the provider, model and API key of cfg.
+// ctx bounds the wait for a locked keyring.
+func NewClient(ctx context.Context, cfg Config) (*Client, error) {
+	provider := ProviderName(cfg)
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = config.DefaultBaseURL(provider)
+	}
+	if provider == "anthropic" && cfg.BaseURL == "" {
+		return nil, fmt.Errorf("anthropic requires an OpenAI-compatible base URL; set --base-url or config base_url")
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("model is required; set --model or config model")
+	}
+
+	apiKey, err := config.ResolveAPIKey(ctx, cfg, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{}
+	if provider == "openrouter" {
+		if cfg.OpenRouterRef != "" {
+			headers["HTTP-Referer"] = cfg.OpenRouterRef
+		}
+		if cfg.OpenRouterTitle != "" {
+			headers["X-Title"] = cfg.OpenRouterTitle
+		}
+	}
+	return llm.NewClient(cfg.BaseURL, apiKey, cfg.Model, headers, cfg.Timeout), nil
+}
@@ -0,0 +1,135 @@
+package gommit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/git"
+	"github.com/MenschMachine/gommit/internal/prompt"
+	"github.com/MenschMachine/gommit/internal/symbols"
+)
+
+// FileSymbols lists the functions, types and methods a diff adds, removes
+// or modifies in one file.
+type FileSymbols = symbols.File
+
+// maxSymbolSource is the largest file Symbols and GoAPI look into.
+const maxSymbolSource = 1 << 20
+
+// Symbols reports the declarations d adds, removes or modifies. The old and
+// new content of each file is read from the repository at d.Root, the new
+// one from the working tree when git has not stored it. A diff without a
+// repository, or with symbols disabled in cfg, yields none.
+func Symbols(ctx context.Context, d Diff, cfg Config) ([]FileSymbols, error) {
+	if d.Root == "" || cfg.Symbols.Disable {
+		return nil, nil
+	}
+	rules, err := symbolRules(cfg.Symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	sources, err := readSources(ctx, d, func(path string) bool { return symbols.Supported(path, rules) })
+	if err != nil {
+		return nil, err
+	}
+	return symbolsOf(sources, rules), nil
+}
+
+// symbolsOf summarizes the sources rules support.
+func symbolsOf(sources []source, rules []symbols.Rule) []FileSymbols {
+	var out []FileSymbols
+	for _, src := range sources {
+		if !symbols.Supported(src.path, rules) && (src.oldPath == "" || !symbols.Supported(src.oldPath, rules)) {
+			continue
+		}
+		if changes := symbols.Diff(src.path, src.old, src.new, rules); len(changes) > 0 {
+			out = append(out, FileSymbols{Path: src.path, Changes: changes})
+		}
+	}
+	return out
+}
+
+// source is a changed file's content before and after the change, nil on
+// a side where it does not exist.
+type source struct {
+	oldPath, path string
+	old, new      []byte
+	// generated is set when the new content carries the generated code
+	// marker.
+	generated bool
+}
+
+// readSources returns the old and new content of the changed text files
+// want selects, reading blobs with one git process. The new content comes
+// from the working tree when git has not stored it. Files larger than
+// maxSymbolSource come without their content.
+func readSources(ctx context.Context, d Diff, want func(path string) bool) ([]source, error) {
+	// A file changed both in the index and the working tree appears twice;
+	// compare the oldest side with the newest.
+	type sides struct{ oldPath, path, oldHash, newHash string }
+	var files []*sides
+	byPath := map[string]*sides{}
+	var names []string
+	for _, f := range d.Parse() {
+		path := f.Path()
+		if path == "" || f.Binary || !(want(path) || (f.OldPath != "" && want(f.OldPath))) {
+			continue
+		}
+		for _, hash := range []string{f.OldHash, f.NewHash} {
+			if !git.NullHash(hash) {
+				names = append(names, hash)
+			}
+		}
+		if s, ok := byPath[path]; ok {
+			s.newHash = f.NewHash
+			continue
+		}
+		s := &sides{oldPath: f.OldPath, path: path, oldHash: f.OldHash, newHash: f.NewHash}
+		byPath[path] = s
+		files = append(files, s)
+	}
+	blobs, err := git.ReadBlobs(ctx, d.Root, names)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []source
+	for _, s := range files {
+		src := source{oldPath: s.oldPath, path: s.path}
+		if !git.NullHash(s.oldHash) {
+			src.old = blobs[s.oldHash]
+		}
+		if !git.NullHash(s.newHash) {
+			var ok bool
+			if src.new, ok = blobs[s.newHash]; !ok {
+				if src.new, err = os.ReadFile(filepath.Join(d.Root, s.path)); err != nil {
+					continue
+				}
+			}
+		}
+		src.generated = prompt.IsGenerated(src.new)
+		if len(src.old) > maxSymbolSource || len(src.new) > maxSymbolSource {
+			src.old, src.new = nil, nil
+		}
+		out = append(out, src)
+	}
+	return out, nil
+}
+
+// symbolRules puts the configured rules before the built-in ones.
+func symbolRules(cfg config.SymbolsConfig) ([]symbols.Rule, error) {
+	var rules []symbols.Rule
+	for _, r := range cfg.Rules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("symbols rule for %s: %w", r.Glob, err)
+		}
+		rules = append(rules, symbols.Rule{Glob: r.Glob, Kind: r.Kind, Pattern: re})
+	}
+	return append(rules, symbols.DefaultRules...), nil
+}
@@ -0,0 +1,8 @@
+#!/bin/sh
+# Generates the shell completions packaged by GoReleaser.
+set -e
+rm -rf completions
+mkdir completions
+for sh in bash zsh fish; do
+	go run . completion "$sh" > "completions/gommit.$sh"
+done
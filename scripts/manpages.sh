@@ -0,0 +1,6 @@
+#!/bin/sh
+# Generates the man page packaged by GoReleaser.
+set -e
+rm -rf manpages
+mkdir manpages
+go run -ldflags "-X main.version=${1:-dev}" . man | gzip -c -9 > manpages/gommit.1.gz
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+
+	"github.com/MenschMachine/gommit/internal/cli"
+	"github.com/MenschMachine/gommit/internal/git"
+	"github.com/MenschMachine/gommit/internal/rpc"
+	"github.com/MenschMachine/gommit/pkg/gommit"
+)
+
+// rpcServer answers `gommit serve` requests. Config, template and client are
+// loaded once; the repository can be given per request.
+type rpcServer struct {
+	cfg  gommit.Config
+	gen  *generator
+	root string
+
+	// genMu serializes generations; they share the usage meter.
+	genMu sync.Mutex
+}
+
+type rpcTarget struct {
+	Root      string   `json:"root"`
+	Scope     string   `json:"scope"`
+	Pathspecs []string `json:"pathspecs"`
+}
+
+type rpcGenerateParams struct {
+	rpcTarget
+	Hint     string   `json:"hint"`
+	NoCache  bool     `json:"noCache"`
+	Trailers []string `json:"trailers"`
+	Tag      string   `json:"tag"`
+}
+
+type rpcCommitParams struct {
+	rpcTarget
+	Message  string `json:"message"`
+	NoVerify bool   `json:"noVerify"`
+	GPGSign  string `json:"gpgSign"`
+	Author   string `json:"author"`
+}
+
+type rpcDiff struct {
+	Diff      string   `json:"diff"`
+	Files     []string `json:"files"`
+	Binaries  []string `json:"binaries"`
+	Truncated []string `json:"truncated_files"`
+}
+
+// serverOptions are the flags shared by `gommit serve` and `gommit mcp`.
+type serverOptions struct {
+	configPath, provider, model, baseURL, style string
+}
+
+func (o *serverOptions) flags() []*cli.Flag {
+	return []*cli.Flag{
+		configOption(&o.configPath),
+		providerOption(&o.provider),
+		modelOption(&o.model),
+		baseURLOption(&o.baseURL),
+		styleOption(&o.style),
+	}
+}
+
+func serveCommand() *cli.Command {
+	var stdio bool
+	var opts serverOptions
+	return &cli.Command{
+		Name:     "serve",
+		Synopsis: []string{"--stdio [options]"},
+		Summary:  "run a JSON-RPC server for editor integrations",
+		Description: "Runs a JSON-RPC 2.0 server (one message per line) for editor integrations.\n" +
+			"Methods: collectDiff, generate, streamGenerate, commit, cancel, exit.",
+		Flags: append([]*cli.Flag{
+			cli.Bool(&stdio, "stdio", "", "speak JSON-RPC on stdin/stdout"),
+		}, opts.flags()...),
+		Run: func(args []string) error {
+			if !stdio || len(args) > 0 {
+				return cli.ErrUsage
+			}
+			runServe(opts)
+			return nil
+		},
+	}
+}
+
+func runServe(opts serverOptions) {
+	s := newRPCServer(opts)
+	srv := rpc.NewServer()
+	srv.Handle("collectDiff", s.collectDiff)
+	srv.Handle("generate", s.generate)
+	srv.Handle("streamGenerate", s.streamGenerate)
+	srv.Handle("commit", s.commit)
+	serveStdio(srv)
+}
+
+// newRPCServer loads config, template and client once for a long-running
+// server.
+func newRPCServer(opts serverOptions) *rpcServer {
+	cfg, err := gommit.LoadConfig(opts.configPath)
+	if err != nil {
+		fatal(err.Error())
+	}
+	if opts.provider != "" {
+		cfg.Provider = opts.provider
+	}
+	if opts.model != "" {
+		cfg.Model = opts.model
+	}
+	if opts.baseURL != "" {
+		cfg.BaseURL = opts.baseURL
+	}
+	if opts.style != "" {
+		cfg.Style = opts.style
+	}
+	tmpl, err := gommit.LoadTemplate(cfg)
+	if err != nil {
+		fatal(err.Error())
+	}
+	client, err := gommit.NewClient(context.Background(), cfg)
+	if err != nil {
+		fatal(err.Error())
+	}
+	meter := newUsageMeter(cfg)
+	client.OnUsage = meter.record
+	historyStore, err := openHistory()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gommit: message history disabled:", err)
+	}
+	responseCache, err := openCache(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gommit: response cache disabled:", err)
+	}
+	// Not fatal: clients may pass "root" with every request.
+	root, _ := git.RepoRoot()
+
+	return &rpcServer{
+		cfg:  cfg,
+		gen:  &generator{cfg: cfg, tmpl: tmpl, client: client, meter: meter, cache: responseCache, history: historyStore},
+		root: root,
+	}
+}
+
+func serveStdio(srv *rpc.Server) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := srv.Serve(ctx, os.Stdin, os.Stdout); err != nil {
+		fatal(err.Error())
+	}
+}
+
+func (s *rpcServer) collectDiff(ctx context.Context, raw json.RawMessage, notify rpc.Notify) (any, error) {
+	var params rpcTarget
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	c, err := s.collect(ctx, params, false)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return c.rpcDiff(), nil
+}
+
+func (s *rpcServer) generate(ctx context.Context, raw json.RawMessage, notify rpc.Notify) (any, error) {
+	return s.runGenerate(ctx, raw, nil)
+}
+
+// streamGenerate sends "progress" notifications with the request id and
+// either a stage or a chunk of the message text as it is generated.
+func (s *rpcServer) streamGenerate(ctx context.Context, raw json.RawMessage, notify rpc.Notify) (any, error) {
+	id := rpc.RequestID(ctx)
+	progress := func(kind, value string) {
+		notify("progress", map[string]any{"id": id, kind: value})
+	}
+	return s.runGenerate(ctx, raw, progress)
+}
+
+func (s *rpcServer) runGenerate(ctx context.Context, raw json.RawMessage, progress func(kind, value string)) (any, error) {
+	stream := progress != nil
+	if !stream {
+		progress = func(string, string) {}
+	}
+	var params rpcGenerateParams
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	progress("stage", "collecting_diff")
+	c, err := s.collect(ctx, params.rpcTarget, true)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	req := generateRequest{
+		Hint:      params.Hint,
+		ReadCache: !params.NoCache,
+		Trailers:  params.Trailers,
+		Tag:       params.Tag,
+	}
+	if stream {
+		req.OnDelta = func(delta string) { progress("delta", delta) }
+	}
+	progress("stage", "generating")
+	res, err := s.generateMessage(ctx, c, req)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	progress("stage", "done")
+	return res, nil
+}
+
+func (s *rpcServer) commit(ctx context.Context, raw json.RawMessage, notify rpc.Notify) (any, error) {
+	var params rpcCommitParams
+	if err := decodeParams(raw, &params); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(params.Message) == "" {
+		return nil, &rpc.Error{Code: rpc.InvalidParams, Message: "message is required"}
+	}
+	opts := commitOptions{NoVerify: params.NoVerify, GPGSign: params.GPGSign, Author: params.Author, Quiet: true}
+	if err := s.commitTo(params.rpcTarget, params.Message, opts); err != nil {
+		return nil, rpcError(err)
+	}
+	return map[string]bool{"committed": true}, nil
+}
+
+// collection is the diff of one request.
+type collection struct {
+	root  string
+	scope git.DiffScope
+	diff  gommit.Diff
+}
+
+func (c collection) rpcDiff() rpcDiff {
+	binaries := []string{}
+	for _, b := range c.diff.Binaries {
+		binaries = append(binaries, b.Path)
+	}
+	files := c.diff.Files()
+	if files == nil {
+		files = []string{}
+	}
+	truncated := c.diff.Truncated
+	if truncated == nil {
+		truncated = []string{}
+	}
+	return rpcDiff{Diff: c.diff.Text, Files: files, Binaries: binaries, Truncated: truncated}
+}
+
+// collect reads the diff for t. With requireChanges an empty diff is an
+// error.
+func (s *rpcServer) collect(ctx context.Context, t rpcTarget, requireChanges bool) (collection, error) {
+	root, scope, err := s.resolve(t)
+	if err != nil {
+		return collection{}, err
+	}
+	diff, err := gommit.GitDiff{Root: root, Scope: scope, Pathspecs: t.Pathspecs, PerFileLimit: s.cfg.PerFileLimit}.Diff(ctx)
+	if err != nil {
+		return collection{}, withCode(errCodeGit, err)
+	}
+	if requireChanges && diff.Empty() {
+		return collection{}, withCode(errCodeNoChanges, gommit.ErrNoChanges)
+	}
+	return collection{root: root, scope: scope, diff: diff}, nil
+}
+
+func (s *rpcServer) generateMessage(ctx context.Context, c collection, req generateRequest) (jsonResult, error) {
+	s.genMu.Lock()
+	defer s.genMu.Unlock()
+	out, err := s.gen.generate(ctx, c.diff, req)
+	if err != nil {
+		return jsonResult{}, err
+	}
+	res, err := newJSONResult(c.root, out.Message, c.diff, out.Violations)
+	if err != nil {
+		return jsonResult{}, withCode(errCodeGit, err)
+	}
+	s.gen.meter.fill(&res)
+	res.Style = out.Style
+	res.Cached = out.Cached
+	return res, nil
+}
+
+// commitTo commits message to the repository of t: the changes of its scope,
+// limited to its pathspecs like the diff.
+func (s *rpcServer) commitTo(t rpcTarget, message string, opts commitOptions) error {
+	root, scope, err := s.resolve(t)
+	if err != nil {
+		return err
+	}
+	opts.Quiet = true
+	opts.Pathspecs = t.Pathspecs
+	if err := commitMessage(root, message, scope, opts); err != nil {
+		return withCode(errCodeCommit, err)
+	}
+	return nil
+}
+
+// resolve picks the repository and diff scope of a request, defaulting to
+// the repository the server was started in and the staged changes.
+func (s *rpcServer) resolve(t rpcTarget) (string, git.DiffScope, error) {
+	root := s.root
+	if t.Root != "" {
+		var err error
+		root, err = git.RepoRootAt(t.Root)
+		if err != nil {
+			return "", 0, rpcError(withCode(errCodeGit, err))
+		}
+	}
+	if root == "" {
+		return "", 0, rpcError(withCode(errCodeGit, errors.New("not in a git repository; pass \"root\"")))
+	}
+	switch t.Scope {
+	case "", "staged":
+		return root, git.ScopeStaged, nil
+	case "unstaged":
+		return root, git.ScopeStagedUnstaged, nil
+	case "all":
+		return root, git.ScopeAll, nil
+	default:
+		return "", 0, &rpc.Error{Code: rpc.InvalidParams, Message: fmt.Sprintf("unknown scope %q (staged, unstaged, all)", t.Scope)}
+	}
+}
+
+func decodeParams(raw json.RawMessage, v any) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &rpc.Error{Code: rpc.InvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// rpcError reports err with the stable --output json code in its data.
+func rpcError(err error) error {
+	var rpcErr *rpc.Error
+	if errors.As(err, &rpcErr) || errors.Is(err, context.Canceled) {
+		return err
+	}
+	return &rpc.Error{Code: rpc.ServerError, Message: err.Error(), Data: map[string]string{"code": errorCode(err)}}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/MenschMachine/gommit/internal/cli"
+	"github.com/MenschMachine/gommit/internal/prompt"
+)
+
+func templateCommand() *cli.Command {
+	return &cli.Command{
+		Name:     "template",
+		Synopsis: []string{"list", "show <name>"},
+		Summary:  "list or print the built-in prompt templates",
+		Args: func(prev []string) []string {
+			switch {
+			case len(prev) == 0:
+				return []string{"list", "show"}
+			case len(prev) == 1 && prev[0] == "show":
+				return prompt.BuiltinTemplates()
+			}
+			return nil
+		},
+		Run: runTemplate,
+	}
+}
+
+func runTemplate(args []string) error {
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		for _, name := range prompt.BuiltinTemplates() {
+			fmt.Println(name)
+		}
+	case len(args) == 2 && args[0] == "show":
+		src, err := prompt.BuiltinTemplateSource(args[1])
+		if err != nil {
+			fatal(err.Error())
+		}
+		fmt.Print(src)
+	default:
+		return cli.ErrUsage
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/git"
+)
+
+// recentAuthorLimit bounds how much history is searched for co-authors
+// that are not in the config alias table.
+const recentAuthorLimit = 1000
+
+var (
+	identityPattern   = regexp.MustCompile(`^[^<>]+ <[^<>\s]+@[^<>\s]+>$`)
+	trailerKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+)
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// buildTrailers turns --co-author, --trailer and --signoff into "Key: value"
+// trailers in the order git should add them.
+func buildTrailers(root string, cfg config.Config, coAuthors, trailers []string, signoff bool) ([]string, error) {
+	var out []string
+	for _, alias := range coAuthors {
+		identity, err := resolveCoAuthor(root, cfg.CoAuthors, alias)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, "Co-authored-by: "+identity)
+	}
+	for _, trailer := range trailers {
+		key, value, ok := strings.Cut(trailer, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || !trailerKeyPattern.MatchString(key) || value == "" {
+			return nil, fmt.Errorf("invalid --trailer %q; expected key=value", trailer)
+		}
+		out = append(out, key+": "+value)
+	}
+	if signoff {
+		author := git.Author(root)
+		if author == "" {
+			return nil, fmt.Errorf("--signoff requires user.name and user.email in git config")
+		}
+		out = append(out, "Signed-off-by: "+author)
+	}
+	return out, nil
+}
+
+// resolveCoAuthor maps alias to "Name <email>" using the config alias table,
+// then a literal identity, then a unique match among recent commit authors.
+func resolveCoAuthor(root string, aliases map[string]string, alias string) (string, error) {
+	alias = strings.TrimSpace(alias)
+	if alias == "" {
+		// It would match every recent author.
+		return "", fmt.Errorf("empty --co-author")
+	}
+	if identity, ok := aliases[alias]; ok {
+		return identity, nil
+	}
+	if identityPattern.MatchString(alias) {
+		return alias, nil
+	}
+	authors, err := git.RecentAuthors(root, recentAuthorLimit)
+	if err != nil {
+		return "", err
+	}
+	needle := strings.ToLower(alias)
+	var matches []string
+	for _, author := range authors {
+		if strings.Contains(strings.ToLower(author), needle) {
+			matches = append(matches, author)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("unknown co-author %q; add it to [co_authors] in the config", alias)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("co-author %q is ambiguous: %s", alias, strings.Join(matches, "; "))
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/MenschMachine/gommit/internal/cli"
+	"github.com/MenschMachine/gommit/internal/config"
+	"github.com/MenschMachine/gommit/internal/llm"
+	"github.com/MenschMachine/gommit/internal/usage"
+	"github.com/MenschMachine/gommit/pkg/gommit"
+)
+
+// usageMeter records every completion in the usage ledger and totals the
+// tokens used since the last reset for display.
+type usageMeter struct {
+	ledger   *usage.Ledger
+	provider string
+	model    string
+	prices   map[string]config.Price
+	total    llm.Usage
+}
+
+func newUsageMeter(cfg config.Config) *usageMeter {
+	m := &usageMeter{provider: gommit.ProviderName(cfg), model: cfg.Model, prices: cfg.Prices}
+	dir, err := config.StateDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gommit: usage ledger disabled:", err)
+		return m
+	}
+	m.ledger = usage.Open(dir)
+	return m
+}
+
+func (m *usageMeter) record(u llm.Usage) {
+	m.total = m.total.Add(u)
+	if m.ledger == nil {
+		return
+	}
+	if err := m.ledger.Add(time.Now(), m.provider, m.model, u); err != nil {
+		fmt.Fprintln(os.Stderr, "gommit: could not record usage:", err)
+	}
+}
+
+func (m *usageMeter) reset() {
+	m.total = llm.Usage{}
+}
+
+// summary describes the tokens used since the last reset, or returns ""
+// when nothing was used.
+func (m *usageMeter) summary() string {
+	if m.total.IsZero() {
+		return ""
+	}
+	line := fmt.Sprintf("Tokens: %d prompt", m.total.PromptTokens)
+	if m.total.CachedTokens > 0 {
+		line += fmt.Sprintf(" (%d cached)", m.total.CachedTokens)
+	}
+	line += fmt.Sprintf(", %d completion", m.total.CompletionTokens)
+	if price, ok := usage.LookupPrice(m.prices, m.provider, m.model); ok {
+		line += fmt.Sprintf(", $%.4f", usage.Cost(m.total, price))
+	}
+	return line
+}
+
+func usageCommand() *cli.Command {
+	var since, configPath string
+	return &cli.Command{
+		Name:        "usage",
+		Synopsis:    []string{"[options]"},
+		Summary:     "show token usage and cost",
+		Description: "Shows token usage and cost per provider and model.",
+		Flags: []*cli.Flag{
+			cli.String(&since, "since", "", "when", "30d", "first day to include (e.g. 30d, 2w, 2024-01-31)"),
+			configOption(&configPath),
+		},
+		Run: func(args []string) error {
+			if len(args) > 0 {
+				return cli.ErrUsage
+			}
+			runUsage(since, configPath)
+			return nil
+		},
+	}
+}
+
+func runUsage(since, configPath string) {
+	cfg, err := gommit.LoadConfig(configPath)
+	if err != nil {
+		fatal(err.Error())
+	}
+	day, err := usage.ParseSince(since, time.Now())
+	if err != nil {
+		fatal(err.Error())
+	}
+	dir, err := config.StateDir()
+	if err != nil {
+		fatal(err.Error())
+	}
+	records, err := usage.Open(dir).Since(day)
+	if err != nil {
+		fatal(err.Error())
+	}
+	if len(records) == 0 {
+		fmt.Println("No usage recorded since", day)
+		return
+	}
+
+	fmt.Printf("Usage since %s:\n\n", day)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROVIDER\tMODEL\tREQUESTS\tPROMPT\tCACHED\tCOMPLETION\tCOST\t")
+	var total llm.Usage
+	var totalCost float64
+	requests := 0
+	unpriced := false
+	for _, r := range usage.Summarize(records) {
+		cost := "-"
+		if price, ok := usage.LookupPrice(cfg.Prices, r.Provider, r.Model); ok {
+			c := usage.Cost(r.Usage, price)
+			totalCost += c
+			cost = fmt.Sprintf("$%.4f", c)
+		} else {
+			unpriced = true
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t\n", r.Provider, r.Model, r.Requests, r.PromptTokens, r.CachedTokens, r.CompletionTokens, cost)
+		total = total.Add(r.Usage)
+		requests += r.Requests
+	}
+	fmt.Fprintf(w, "total\t\t%d\t%d\t%d\t%d\t$%.4f\t\n", requests, total.PromptTokens, total.CachedTokens, total.CompletionTokens, totalCost)
+	if err := w.Flush(); err != nil {
+		fatal(err.Error())
+	}
+	if unpriced {
+		fmt.Println()
+		fmt.Println("Models without a [prices] entry are not included in the total cost.")
+	}
+}